@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"p1/pkg/config"
+)
+
+const PROGRAM = "p1"
+
+// command is a subcommand of the binary, e.g. "p1 serve".
+type command struct {
+	name    string
+	args    string // Shown after the name in the usage
+	summary string
+	run     func(args []string) int // Returns the exit code
+}
+
+// commands are listed in the usage in this order. Without a command the
+// server and the TUI run together. They are set in init as the help
+// command refers to them.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "[--daemon] [flags]", "run the server without the TUI, in the background with --daemon", runServe},
+		{"status", "[flags]", "show the server running in the data dir", runStatus},
+		{"stop", "[flags]", "stop the server running in the data dir", runStop},
+		{"tui", "[flags]", "run the TUI against a running server", func(args []string) int {
+			newFlags := func() *flag.FlagSet { return commandFlags("tui", "[flags]") }
+			return runApp(newFlags, args, func(cfg *config.Config) {
+				cfg.WithServer = false
+				cfg.WithTui = true
+			})
+		}},
+		{"services", "list|register|remove", "manage the services of a running server", servicesResource.run},
+		{"projects", "list|register|remove", "manage the projects of a running server", projectsResource.run},
+		{"brokers", "list|register|remove", "manage the brokers of a running server", brokersResource.run},
+		{"metrics", "[flags]", "print the metrics of a running server", runMetrics},
+		{"broadcast", "[flags] <message>", "send a message to every client of a running server", runBroadcast},
+		{"config", "validate [file]", "check a config file", runConfig},
+		{"help", "", "show this help", func(args []string) int {
+			usage(os.Stdout)
+			return 0
+		}},
+	}
+}
+
+// runCommand runs the command called name and returns its exit code.
+func runCommand(name string, args []string) int {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(args)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", PROGRAM, name)
+	usage(os.Stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s [flags]\n       %s <command> [arguments]\n\n", PROGRAM, PROGRAM)
+	fmt.Fprintln(w, "Without a command the server and the TUI run together.")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := newTable(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", PROGRAM)
+	fmt.Fprintf(w, "Commands talking to a server exit with %d on errors, %d on usage errors, %d if no\n", EXIT_ERROR, EXIT_USAGE, EXIT_UNAVAILABLE)
+	fmt.Fprintf(w, "server is reachable, %d if it did not answer in time and %d for unknown IDs.\n", EXIT_TIMEOUT, EXIT_NOT_FOUND)
+}
+
+// commandFlags returns the flag set of a subcommand, named like it is
+// called. Its usage shows args after the name. Parsing does not print
+// anything, loadConfig reports errors and prints the usage for -h.
+func commandFlags(name string, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(PROGRAM+" "+name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s %s %s\n\nFlags:\n", PROGRAM, name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// loadConfig loads the configuration with the flags of a command. Errors in
+// the flags are usage errors. For -h it prints the usage and returns
+// flag.ErrHelp, which output.fail turns into a zero exit code.
+func loadConfig(flags *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg, err := config.Load(flags, args)
+	if errors.Is(err, flag.ErrHelp) {
+		flags.SetOutput(os.Stderr)
+		flags.Usage()
+		return nil, err
+	}
+	// the flags are parsed last, after the config file was read
+	if err != nil && flags.Parsed() {
+		return nil, usageError("%s", err)
+	}
+	return cfg, err
+}
+
+// runConfig runs "config validate", which checks the config file given in
+// args, or the default one, and prints what is wrong with it.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintf(os.Stderr, "usage: %s config validate [file]\n", PROGRAM)
+		return 2
+	}
+	path := config.DefaultConfigFile(nil)
+	if len(args) > 1 {
+		path = args[1]
+	}
+	problems, err := config.Validate(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	fmt.Printf("%s is valid\n", path)
+	return 0
+}
+
+// newTable returns a writer that aligns tab separated columns.
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+}
+
+// splitPairs turns key=value arguments into a map.
+func splitPairs(pairs []string) (map[string]string, error) {
+	result := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// listFlag is a flag that may be given several times.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
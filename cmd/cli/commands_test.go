@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOutputLoadFlagErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		format string
+		exit   int
+	}{
+		{name: "bad flag before the output", args: []string{"--bogus", "--output", "json"}, format: OUTPUT_JSON, exit: EXIT_USAGE},
+		{name: "bad flag after the output", args: []string{"--output", "yaml", "--bogus"}, format: OUTPUT_YAML, exit: EXIT_USAGE},
+		{name: "bad value", args: []string{"--ping-interval", "soon"}, format: OUTPUT_TABLE, exit: EXIT_USAGE},
+		{name: "unknown output", args: []string{"--output", "xml"}, format: "xml", exit: EXIT_USAGE},
+	}
+	// no config file is read
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := commandFlags("test", "[flags]")
+			out := addOutputFlags(flags)
+			_, err := out.load(flags, tt.args)
+			var cmdErr *commandError
+			if !errors.As(err, &cmdErr) || cmdErr.exit != tt.exit {
+				t.Fatalf("error = %v, want exit code %d", err, tt.exit)
+			}
+			if out.format != tt.format {
+				t.Errorf("format = %q, want %q", out.format, tt.format)
+			}
+		})
+	}
+}
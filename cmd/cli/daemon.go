@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"time"
+
+	"p1/pkg/config"
+	"p1/pkg/discovery"
+)
+
+// ENV_DAEMONIZED is set for the background process started by serve --daemon.
+const ENV_DAEMONIZED = "P1_DAEMONIZED"
+
+const (
+	DAEMON_START_TIMEOUT = 10 * time.Second // How long serve --daemon waits for the server to announce itself
+	STOP_TIMEOUT         = 15 * time.Second // How long stop waits for the server to shut down
+	DAEMON_POLL_INTERVAL = 100 * time.Millisecond
+)
+
+// runServe runs the server without the TUI. With --daemon it starts itself
+// again in the background, detached from the terminal, and returns once
+// that server announced itself in the data dir.
+func runServe(args []string) int {
+	daemon := false
+	newFlags := func() *flag.FlagSet {
+		flags := commandFlags("serve", "[--daemon] [flags]")
+		flags.BoolVar(&daemon, "daemon", false, "run in the background, see the status and stop commands")
+		return flags
+	}
+	adjust := func(cfg *config.Config) {
+		cfg.WithServer = true
+		cfg.WithTui = false
+	}
+
+	cfg, err := loadConfig(newFlags(), args)
+	if err != nil {
+		return (&output{format: OUTPUT_TABLE}).fail(err)
+	}
+	if !daemon || os.Getenv(ENV_DAEMONIZED) != "" {
+		return runApp(newFlags, args, adjust)
+	}
+	return startDaemon(cfg, args)
+}
+
+// startDaemon starts "serve args" in the background and waits until it
+// announced itself in the data dir of cfg.
+func startDaemon(cfg *config.Config, args []string) int {
+	if info, err := discovery.Find(cfg.DataDir); err == nil {
+		fmt.Fprintf(os.Stderr, "%s: a server is already running in %s with pid %d at %s\n", PROGRAM, cfg.DataDir, info.PID, info.Address)
+		return EXIT_ERROR
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", PROGRAM, err)
+		return EXIT_ERROR
+	}
+	cmd := exec.Command(executable, append([]string{"serve"}, args...)...)
+	cmd.Env = append(os.Environ(), ENV_DAEMONIZED+"=1")
+	cmd.SysProcAttr = detached()
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to start the daemon: %s\n", PROGRAM, err)
+		return EXIT_ERROR
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ticker := time.NewTicker(DAEMON_POLL_INTERVAL)
+	defer ticker.Stop()
+	deadline := time.After(DAEMON_START_TIMEOUT)
+	for {
+		select {
+		case err := <-exited:
+			fmt.Fprintf(os.Stderr, "%s: the daemon exited while starting (%v), see %s\n", PROGRAM, err, cfg.LogFile)
+			return EXIT_ERROR
+		case <-deadline:
+			fmt.Fprintf(os.Stderr, "%s: the daemon with pid %d did not announce itself within %s, see %s\n", PROGRAM, cmd.Process.Pid, DAEMON_START_TIMEOUT, cfg.LogFile)
+			return EXIT_TIMEOUT
+		case <-ticker.C:
+		}
+		if info, err := discovery.Find(cfg.DataDir); err == nil && info.PID == cmd.Process.Pid {
+			fmt.Printf("server %s running at %s with pid %d\n", info.ID, info.WSLink, info.PID)
+			return 0
+		}
+	}
+}
+
+// runStatus prints the server announced in the data dir. It exits with
+// EXIT_UNAVAILABLE if none is running.
+func runStatus(args []string) int {
+	flags := commandFlags("status", "[flags]")
+	out := addOutputFlags(flags)
+	cfg, err := out.load(flags, args)
+	if err != nil {
+		return out.fail(err)
+	}
+
+	info, err := runningServer(cfg.DataDir)
+	if err != nil {
+		return out.fail(err)
+	}
+	err = out.list(os.Stdout, info, func(w io.Writer) {
+		tw := newTable(w)
+		fmt.Fprintf(tw, "ID\t%s\n", info.ID)
+		fmt.Fprintf(tw, "ADDRESS\t%s\n", info.Address)
+		fmt.Fprintf(tw, "PORT\t%d\n", info.Port)
+		fmt.Fprintf(tw, "LINK\t%s\n", info.WSLink)
+		fmt.Fprintf(tw, "PID\t%d\n", info.PID)
+		fmt.Fprintf(tw, "STARTED\t%s (%s ago)\n", info.StartedAt.Local().Format(time.DateTime), time.Since(info.StartedAt).Round(time.Second))
+		tw.Flush()
+	})
+	if err != nil {
+		return out.fail(err)
+	}
+	return 0
+}
+
+// runStop asks the server announced in the data dir to shut down and waits
+// until it is gone.
+func runStop(args []string) int {
+	flags := commandFlags("stop", "[flags]")
+	out := addOutputFlags(flags)
+	cfg, err := out.load(flags, args)
+	if err != nil {
+		return out.fail(err)
+	}
+
+	info, err := runningServer(cfg.DataDir)
+	if err != nil {
+		return out.fail(err)
+	}
+	process, err := os.FindProcess(info.PID)
+	if err == nil {
+		err = terminate(process, cfg.DataDir, info)
+	}
+	if err != nil {
+		return out.fail(fmt.Errorf("failed to stop pid %d: %w", info.PID, err))
+	}
+
+	// the server removes its discovery file when it shuts down
+	ticker := time.NewTicker(DAEMON_POLL_INTERVAL)
+	defer ticker.Stop()
+	deadline := time.After(STOP_TIMEOUT)
+	for {
+		select {
+		case <-deadline:
+			return out.fail(timeoutError(fmt.Errorf("server with pid %d did not stop within %s", info.PID, STOP_TIMEOUT)))
+		case <-ticker.C:
+		}
+		current, err := discovery.Read(cfg.DataDir)
+		if errors.Is(err, fs.ErrNotExist) || err == nil && current.ID != info.ID {
+			break
+		}
+	}
+	err = out.list(os.Stdout, info, func(w io.Writer) {
+		fmt.Fprintf(w, "stopped server %s with pid %d\n", info.ID, info.PID)
+	})
+	if err != nil {
+		return out.fail(err)
+	}
+	return 0
+}
+
+// runningServer returns the server announced in dir if it accepts
+// connections.
+func runningServer(dir string) (*discovery.ServerInfo, error) {
+	info, err := discovery.Find(dir)
+	if err != nil {
+		return nil, unavailableError(err)
+	}
+	return info, nil
+}
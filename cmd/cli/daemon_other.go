@@ -0,0 +1,32 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"p1/pkg/discovery"
+)
+
+func detached() *syscall.SysProcAttr {
+	return nil
+}
+
+// terminate kills the server, there is no signal asking it to shut down
+// here. The discovery file and pidfile it would have removed from dir are
+// removed for it.
+func terminate(process *os.Process, dir string, info *discovery.ServerInfo) error {
+	if err := process.Kill(); err != nil {
+		return err
+	}
+	process.Wait()
+	if err := discovery.Remove(dir, info.ID); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, discovery.PID_FILE)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
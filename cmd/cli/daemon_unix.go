@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"p1/pkg/discovery"
+)
+
+// detached starts the daemon in a session of its own, so it keeps running
+// when the terminal it was started from is closed.
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminate asks the server to shut down, it removes its discovery file
+// and pidfile in dir on the way.
+func terminate(process *os.Process, dir string, info *discovery.ServerInfo) error {
+	return process.Signal(syscall.SIGTERM)
+}
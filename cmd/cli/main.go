@@ -1,16 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"p1/pkg/client"
 	"p1/pkg/config"
+	"p1/pkg/discovery"
+	"p1/pkg/keymap"
+	"p1/pkg/logs"
+	"p1/pkg/models"
 	"p1/pkg/server"
 	"p1/pkg/tui"
+	"p1/pkg/tui/theme"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -19,18 +34,43 @@ import (
 
 func main() {
 	// Load .env file
-	if err := godotenv.Load(); err != nil {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		slog.Error("Error loading .env file", "error", err)
 	}
 
-	log, err := os.Create("output.log")
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+	newFlags := func() *flag.FlagSet { return flag.NewFlagSet(PROGRAM, flag.ExitOnError) }
+	os.Exit(runApp(newFlags, os.Args[1:], nil))
+}
+
+// runApp runs the server and the TUI as far as the configuration enables
+// them, adjust may change it after it was loaded with the flags newFlags
+// returns. It returns once both are done, or exits on a signal.
+func runApp(newFlags func() *flag.FlagSet, args []string, adjust func(cfg *config.Config)) int {
+	load := func() (*config.Config, error) {
+		cfg, err := loadConfig(newFlags(), args)
+		if err == nil && adjust != nil {
+			adjust(cfg)
+		}
+		return cfg, err
+	}
+	cfg, err := load()
 	if err != nil {
-		panic(err)
+		return (&output{format: OUTPUT_TABLE}).fail(err)
 	}
-	defer log.Close()
-	slog.SetDefault(slog.New(slog.NewTextHandler(log, &slog.HandlerOptions{})))
 
-	config := config.New()
+	// a daemon has no terminal to log to
+	daemonized := os.Getenv(ENV_DAEMONIZED) != ""
+	logHub := logs.NewHub()
+	logHub.Skip("p1/pkg/client")
+	levels, logCloser, err := setupLogging(cfg, logHub, cfg.WithTui || daemonized)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logCloser.Close()
 
 	var wg sync.WaitGroup
 	sigChan := make(chan os.Signal, 1)
@@ -38,11 +78,37 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	var srv *server.Server
-	var cl *client.Client
-	if config.WithServer {
+	var cl *client.Pool
+	var program atomic.Pointer[tea.Program] // Set once the TUI runs
+	// attaching to a running server replaces the embedded one, so does a
+	// daemon serving the data dir when the TUI runs
+	embed := cfg.WithServer && cfg.Connect == ""
+	if embed && cfg.WithTui {
+		if info, err := discovery.Find(cfg.DataDir); err == nil {
+			slog.Info("Attaching to the running server", "id", info.ID, "address", info.Address, "pid", info.PID)
+			embed = false
+		}
+	}
+	if embed {
 		wg.Add(1)
+		peers, err := splitLinks(cfg.Peers)
+		if err != nil {
+			slog.Error("Error parsing peers", "error", err)
+			os.Exit(1)
+		}
 		serverOptions := server.ServerOptions{
-			Port: config.ServerPort,
+			Host:    cfg.ServerHost,
+			Port:    cfg.ServerPort,
+			DataDir: cfg.DataDir,
+			PIDFile: daemonized,
+			Peers:   peers,
+			Logs:    logHub,
+
+			MetricsInterval: cfg.MetricsInterval,
+			HealthInterval:  cfg.HealthInterval,
+			HealthTimeout:   cfg.HealthTimeout,
+			HealthChecks:    cfg.HealthChecks,
+			AuthTokens:      cfg.AuthTokens,
 		}
 		srv = server.New(serverOptions)
 
@@ -55,6 +121,34 @@ func main() {
 		}()
 	}
 
+	// Reload the configuration on SIGHUP and whenever the config file changes
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	fileChanged := config.Watch(context.Background(), cfg.ConfigFile, config.WATCH_INTERVAL)
+	go func() {
+		current := cfg
+		for {
+			select {
+			case <-reloadChan:
+				slog.Info("Received reload signal")
+			case <-fileChanged:
+				slog.Info("Config file changed", "file", current.ConfigFile)
+			}
+			current = reloadConfig(current, load, levels, srv, program.Load())
+		}
+	}()
+
+	// The client pool exists before the TUI and the shutdown handler start,
+	// both of them use it
+	if cfg.WithTui {
+		cl = client.NewPool(client.ClientOptions{
+			PingInterval: cfg.PingInterval,
+			PongTimeout:  cfg.PongTimeout,
+			Name:         cfg.Name,
+			AuthToken:    cfg.ClientAuthToken(),
+		})
+	}
+
 	// Unified shutdown handler
 	go func() {
 		select {
@@ -76,26 +170,67 @@ func main() {
 		os.Exit(0)
 	}()
 
-	if config.WithTui {
+	if cfg.WithTui {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer close(tuiDone)
 
-			cl = client.NewClient(srv.WSLink)
-			err := cl.Init()
+			if srv != nil {
+				// the embedded server starts next to the TUI
+				<-srv.Listening()
+			}
+			servers, err := resolveServers(cfg, srv)
 			if err != nil {
-				slog.Error("Error initializing client", "error", err.Error())
+				slog.Error("Error finding a server to connect to", "error", err.Error())
 				os.Exit(1)
 			}
-			err = cl.Start()
+
+			store, err := config.NewServerStore(cfg.ConfigDir)
 			if err != nil {
-				slog.Error("Error starting client", "error", err.Error())
-				os.Exit(1)
+				slog.Error("Error loading saved servers", "error", err.Error())
 			}
+			servers = appendSaved(servers, store.Servers())
 
-			model := tui.NewModel(lipgloss.DefaultRenderer())
-			if _, err := tea.NewProgram(model, tea.WithAltScreen()).Run(); err != nil {
+			for _, server := range servers {
+				slog.Info("Connecting to server", "name", server.Name, "link", server.URL)
+				if err := cl.Add(server); err != nil {
+					slog.Error("Error connecting to server", "error", err.Error())
+				}
+			}
+			if !cl.Stats().Connected {
+				// the pool keeps dialing them, the TUI shows them as offline until then
+				slog.Warn("No server could be reached yet, retrying in the background")
+			}
+
+			if err := keymap.LoadFile(cfg.ConfigDir, keymap.File{Preset: cfg.KeymapPreset, Bindings: cfg.KeyBindings}); err != nil {
+				slog.Error("Error loading keymap", "error", err.Error())
+				fmt.Fprintln(os.Stderr, err)
+			}
+			for _, conflict := range keymap.Default.Conflicts() {
+				slog.Warn("Conflicting key binding", "conflict", conflict.String())
+				fmt.Fprintln(os.Stderr, "keymap:", conflict)
+			}
+
+			themes, err := theme.Definitions(cfg.ConfigDir)
+			if err != nil {
+				slog.Error("Error loading themes", "error", err.Error())
+				fmt.Fprintln(os.Stderr, err)
+			}
+			if !slices.ContainsFunc(themes, func(def theme.Definition) bool { return def.Name == cfg.Theme }) {
+				slog.Warn("Unknown theme, using the default", "theme", cfg.Theme)
+				fmt.Fprintf(os.Stderr, "unknown theme %q, using the default\n", cfg.Theme)
+			}
+
+			model := tui.NewModel(lipgloss.DefaultRenderer(), cl, store, tui.Options{
+				Themes:  themes,
+				Theme:   cfg.Theme,
+				Mouse:   cfg.WithMouse,
+				LogFile: cfg.LogFile,
+			})
+			p := tea.NewProgram(model, tea.WithAltScreen())
+			program.Store(p)
+			if _, err := p.Run(); err != nil {
 				slog.Error("Error running TUI", "error", err)
 				return
 			}
@@ -103,4 +238,203 @@ func main() {
 	}
 
 	wg.Wait()
+	return 0
+}
+
+// reloadConfig reads the configuration again with load and applies what changed
+// compared to current to the parts that are running: the log levels, the
+// server and the TUI, each of them nil if it is not running. Settings that
+// need a restart are reported. It returns the configuration to compare the
+// next reload with, current if reading failed.
+func reloadConfig(current *config.Config, load func() (*config.Config, error), levels *logs.LevelsVar, srv *server.Server, program *tea.Program) *config.Config {
+	notify := func(severity models.Severity, message string) {
+		if program != nil {
+			program.Send(models.NewNotification(severity, message))
+		}
+	}
+
+	next, err := load()
+	if err != nil {
+		slog.Error("Error reloading configuration, keeping the current one", "error", err)
+		notify(models.SeverityError, "Configuration not reloaded: "+err.Error())
+		return current
+	}
+	live, restart := config.Changes(current, next)
+	if len(live) == 0 && len(restart) == 0 {
+		slog.Info("Configuration unchanged")
+		return next
+	}
+
+	if slices.Contains(live, "log.level") {
+		parsed, err := logs.ParseLevels(next.LogLevel)
+		if err != nil {
+			slog.Error("Error parsing log level, keeping the current one", "error", err)
+		} else {
+			levels.Set(parsed)
+		}
+	}
+	if program != nil {
+		if msg, changed := tuiChanges(next, live); changed {
+			program.Send(msg)
+		}
+	}
+	for _, key := range restart {
+		slog.Warn("Setting changed, restart to apply it", "setting", key)
+	}
+	slog.Info("Configuration reloaded", "applied", strings.Join(live, ","), "restart", strings.Join(restart, ","))
+
+	if srv != nil {
+		srv.Reconfigure(server.Settings{
+			MetricsInterval: next.MetricsInterval,
+			HealthInterval:  next.HealthInterval,
+			HealthTimeout:   next.HealthTimeout,
+			HealthChecks:    next.HealthChecks,
+			AuthTokens:      next.AuthTokens,
+		})
+		// the clients, the TUI among them, are told by the server
+		srv.ConfigReloaded(live, restart)
+		return next
+	}
+	if len(live) > 0 {
+		notify(models.SeverityInfo, "Configuration reloaded: "+strings.Join(live, ", "))
+	}
+	if len(restart) > 0 {
+		notify(models.SeverityWarning, "Restart to apply: "+strings.Join(restart, ", "))
+	}
+	return next
+}
+
+// tuiChanges builds the message applying the TUI settings among the live
+// changes, false if none of them changed. The TUI keeps what it was switched
+// to at runtime for the others.
+func tuiChanges(next *config.Config, live []string) (tui.ReloadMsg, bool) {
+	msg := tui.ReloadMsg{ConfigDir: next.ConfigDir}
+	if slices.Contains(live, "tui.theme") {
+		msg.Theme = &next.Theme
+	}
+	if slices.Contains(live, "tui.mouse") {
+		msg.Mouse = &next.WithMouse
+	}
+	if slices.Contains(live, "keymap.preset") || slices.Contains(live, config.KEY_BINDINGS_TABLE) {
+		msg.Keymap = &keymap.File{Preset: next.KeymapPreset, Bindings: next.KeyBindings}
+	}
+	return msg, msg.Theme != nil || msg.Mouse != nil || msg.Keymap != nil
+}
+
+// setupLogging points slog at the destinations in cfg, passing every record
+// through hub so that clients can follow the logs. The returned levels can
+// be changed while logging. When the terminal is taken, by the TUI or the
+// output of a command, the automatic output is the log file.
+func setupLogging(cfg *config.Config, hub *logs.Hub, terminal bool) (*logs.LevelsVar, io.Closer, error) {
+	parsed, err := logs.ParseLevels(cfg.LogLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	levels := logs.NewLevelsVar(parsed)
+
+	output := cfg.LogOutput
+	if output == config.LOG_OUTPUT_AUTO {
+		output = config.LOG_OUTPUT_STDERR
+		if terminal {
+			output = config.LOG_OUTPUT_FILE
+		}
+	}
+	if cfg.WithTui && output != config.LOG_OUTPUT_FILE {
+		// the TUI owns the terminal, anything on stderr would garble it
+		fmt.Fprintf(os.Stderr, "logging to %s while the TUI runs\n", cfg.LogFile)
+		output = config.LOG_OUTPUT_FILE
+	}
+
+	options := logs.Options{
+		Level:  levels,
+		Format: cfg.LogFormat,
+		Rotate: logs.RotateOptions{
+			MaxSize:    int64(cfg.LogMaxSize) << 20,
+			MaxAge:     cfg.LogMaxAge,
+			MaxBackups: cfg.LogMaxBackups,
+		},
+	}
+	switch output {
+	case config.LOG_OUTPUT_FILE:
+		options.File = cfg.LogFile
+	case config.LOG_OUTPUT_STDERR:
+		options.Stderr = true
+	case config.LOG_OUTPUT_BOTH:
+		options.File = cfg.LogFile
+		options.Stderr = true
+	default:
+		return nil, nil, fmt.Errorf("unknown log output %q, use auto, file, stderr or both", cfg.LogOutput)
+	}
+
+	handler, closer, err := logs.Open(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.SetDefault(slog.New(logs.Filter(hub.Handler(handler), levels)))
+	return levels, closer, nil
+}
+
+// resolveServers picks the servers the TUI talks to: the comma separated
+// --connect addresses win, then the embedded server, then whatever server
+// announced itself in the data directory.
+func resolveServers(config *config.Config, srv *server.Server) ([]*models.Server, error) {
+	servers := []*models.Server{}
+	if config.Connect != "" {
+		links, err := splitLinks(config.Connect)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			server := models.NewServer(serverName(link), link)
+			servers = append(servers, &server)
+		}
+		return servers, nil
+	}
+	if srv != nil {
+		server := models.NewServer("local", srv.WSLink)
+		return append(servers, &server), nil
+	}
+	info, err := discovery.Find(config.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("server is disabled and %w", err)
+	}
+	server := models.NewServer("local", info.WSLink)
+	return append(servers, &server), nil
+}
+
+// appendSaved adds the servers saved from the TUI, skipping those that are
+// already connected through another setting.
+func appendSaved(servers []*models.Server, saved []*models.Server) []*models.Server {
+	for _, server := range saved {
+		if !slices.ContainsFunc(servers, func(s *models.Server) bool { return s.URL == server.URL }) {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// serverName derives a display name from a websocket link.
+func serverName(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return u.Host
+}
+
+// splitLinks turns a comma separated list of addresses into websocket links.
+func splitLinks(addresses string) ([]string, error) {
+	links := []string{}
+	for _, address := range strings.Split(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		link, err := discovery.NormalizeLink(address)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, nil
 }
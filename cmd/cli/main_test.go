@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"p1/pkg/config"
+)
+
+func TestTuiChanges(t *testing.T) {
+	next := &config.Config{ConfigDir: "/etc/p1", Theme: "nord", WithMouse: true, KeymapPreset: "vim"}
+	tests := []struct {
+		name                 string
+		live                 []string
+		changed              bool
+		theme, mouse, keymap bool // Which settings the message carries
+	}{
+		{name: "none", live: nil},
+		{name: "other settings", live: []string{"log.level", "server.health_interval"}},
+		{name: "theme", live: []string{"log.level", "tui.theme"}, changed: true, theme: true},
+		{name: "mouse", live: []string{"tui.mouse"}, changed: true, mouse: true},
+		{name: "keymap preset", live: []string{"keymap.preset"}, changed: true, keymap: true},
+		{name: "key bindings", live: []string{config.KEY_BINDINGS_TABLE}, changed: true, keymap: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, changed := tuiChanges(next, tt.live)
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if (msg.Theme != nil) != tt.theme || (msg.Mouse != nil) != tt.mouse || (msg.Keymap != nil) != tt.keymap {
+				t.Errorf("message = %+v, want theme %v, mouse %v, keymap %v", msg, tt.theme, tt.mouse, tt.keymap)
+			}
+			if msg.Theme != nil && *msg.Theme != "nord" {
+				t.Errorf("theme = %q, want nord", *msg.Theme)
+			}
+			if msg.Keymap != nil && msg.Keymap.Preset != "vim" {
+				t.Errorf("keymap preset = %q, want vim", msg.Keymap.Preset)
+			}
+		})
+	}
+}
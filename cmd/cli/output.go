@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"p1/pkg/config"
+)
+
+const (
+	OUTPUT_TABLE    = "table"    // Aligned columns for people
+	OUTPUT_JSON     = "json"     // Indented JSON, one compact object per line when streaming
+	OUTPUT_YAML     = "yaml"     // YAML, one document per entry when streaming
+	OUTPUT_TEMPLATE = "template" // A Go template from --template, run for every entry
+)
+
+// Exit codes of the commands. Failures print a structured error body in
+// the json and yaml outputs.
+const (
+	EXIT_ERROR       = 1 // Anything not listed below
+	EXIT_USAGE       = 2 // Wrong flags or arguments
+	EXIT_UNAVAILABLE = 3 // No server to connect to
+	EXIT_TIMEOUT     = 4 // The server did not answer in time
+	EXIT_NOT_FOUND   = 5 // An ID the server does not know
+)
+
+// commandError is an error with the exit code and the code shown in a
+// structured error body.
+type commandError struct {
+	code string // e.g. "timeout", stable for scripts
+	exit int
+	err  error
+}
+
+func (e *commandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
+
+func usageError(format string, args ...any) error {
+	return &commandError{code: "usage", exit: EXIT_USAGE, err: fmt.Errorf(format, args...)}
+}
+
+func unavailableError(err error) error {
+	return &commandError{code: "unavailable", exit: EXIT_UNAVAILABLE, err: err}
+}
+
+func timeoutError(err error) error {
+	return &commandError{code: "timeout", exit: EXIT_TIMEOUT, err: err}
+}
+
+func notFoundError(format string, args ...any) error {
+	return &commandError{code: "not_found", exit: EXIT_NOT_FOUND, err: fmt.Errorf(format, args...)}
+}
+
+// output writes what a command prints in the format chosen with --output.
+type output struct {
+	format   string
+	template string
+	parsed   *template.Template
+}
+
+// addOutputFlags adds --output and --template to flags.
+func addOutputFlags(flags *flag.FlagSet) *output {
+	o := &output{}
+	flags.StringVar(&o.format, "output", OUTPUT_TABLE, "output format: table, json, yaml or template")
+	flags.StringVar(&o.template, "template", "", "Go template run for every entry with --output template, fields are named like in json, e.g. '{{.id}} {{.name}}'")
+	return o
+}
+
+// check validates the flags, it has to be called after they were parsed.
+func (o *output) check() error {
+	switch o.format {
+	case OUTPUT_TABLE, OUTPUT_JSON, OUTPUT_YAML:
+		return nil
+	case OUTPUT_TEMPLATE:
+		if o.template == "" {
+			return usageError("--output template needs --template")
+		}
+		parsed, err := template.New("output").Funcs(template.FuncMap{"json": toJSON}).Parse(o.template)
+		if err != nil {
+			return usageError("invalid template: %s", err)
+		}
+		o.parsed = parsed
+		return nil
+	}
+	return usageError("unknown output %q, use table, json, yaml or template", o.format)
+}
+
+// load loads the configuration with loadConfig and checks the output
+// flags. Parsing stops at the first bad flag, so --output is looked up in
+// args to report that error in the chosen format as well.
+func (o *output) load(flags *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg, err := loadConfig(flags, args)
+	if err != nil {
+		if format, ok := config.FlagValue(args, "output"); ok {
+			o.format = format
+		}
+		return nil, err
+	}
+	return cfg, o.check()
+}
+
+// list writes entries, a slice of wire types, as a whole. table writes them
+// in the table output.
+func (o *output) list(w io.Writer, entries any, table func(w io.Writer)) error {
+	switch o.format {
+	case OUTPUT_JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case OUTPUT_YAML:
+		data, err := toYAML(entries)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case OUTPUT_TEMPLATE:
+		items, err := generic(entries)
+		if err != nil {
+			return err
+		}
+		list, ok := items.([]any)
+		if !ok {
+			return o.execute(w, items)
+		}
+		for _, item := range list {
+			if err := o.execute(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	table(w)
+	return nil
+}
+
+// stream writes one entry of a stream, such as a metrics sample. The json
+// output writes it on a single line so every line can be parsed by itself.
+func (o *output) stream(w io.Writer, entry any, table func(w io.Writer)) error {
+	switch o.format {
+	case OUTPUT_JSON:
+		return json.NewEncoder(w).Encode(entry)
+	case OUTPUT_YAML:
+		data, err := toYAML(entry)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "---\n%s", data)
+		return err
+	case OUTPUT_TEMPLATE:
+		item, err := generic(entry)
+		if err != nil {
+			return err
+		}
+		return o.execute(w, item)
+	}
+	table(w)
+	return nil
+}
+
+// execute runs the template for one entry and ends it with a newline.
+func (o *output) execute(w io.Writer, item any) error {
+	var b bytes.Buffer
+	if err := o.parsed.Execute(&b, item); err != nil {
+		return fmt.Errorf("template: %w", err)
+	}
+	if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// fail prints err, as an error body in the json and yaml outputs, and
+// returns the exit code of the failed command.
+func (o *output) fail(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	code, exit := "error", EXIT_ERROR
+	var cmdErr *commandError
+	if errors.As(err, &cmdErr) {
+		code, exit = cmdErr.code, cmdErr.exit
+	}
+
+	body := map[string]any{"error": map[string]any{"code": code, "message": err.Error()}}
+	switch o.format {
+	case OUTPUT_JSON:
+		json.NewEncoder(os.Stderr).Encode(body)
+	case OUTPUT_YAML:
+		data, _ := toYAML(body)
+		os.Stderr.Write(data)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %s\n", PROGRAM, err)
+		if exit == EXIT_USAGE {
+			fmt.Fprintf(os.Stderr, "Run '%s <command> -h' for the flags.\n", PROGRAM)
+		}
+	}
+	return exit
+}
+
+// generic turns a wire type into maps, slices and scalars keyed by the
+// JSON field names, which is what templates see.
+func generic(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var result any
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return strings.TrimSpace(string(data)), err
+}
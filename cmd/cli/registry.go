@@ -0,0 +1,382 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+
+	"github.com/google/uuid"
+)
+
+// resource is an entry type of the server's registry that the services,
+// projects and brokers commands list, register and remove.
+type resource struct {
+	name     string // Plural, the name of the command
+	singular string
+	list     messages.MessageType
+	register messages.MessageType
+	remove   messages.MessageType
+	ids      func(state *states.ClientState) []string
+	// entries returns the entries with the given IDs, all of them if ids is
+	// nil, as a slice of the wire type
+	entries func(state *states.ClientState, ids []string) any
+	print   func(w io.Writer, state *states.ClientState)
+	// define adds the flags of the register command and returns the
+	// function that builds the entry with the given ID and name from them
+	define func(flags *flag.FlagSet) func(id string, name string) (any, error)
+	// fields are the JSON fields the register command sets, a registration
+	// was applied once the listed entry has the values that were sent
+	fields []string
+	// owner returns the server that owns the entry with id, nil if every
+	// entry belongs to the server the command talks to
+	owner func(state *states.ClientState, id string) string
+}
+
+var servicesResource = &resource{
+	name:     "services",
+	singular: "service",
+	list:     messages.TypeListServices,
+	register: messages.TypeRegisterService,
+	remove:   messages.TypeRemoveService,
+	ids: func(state *states.ClientState) []string {
+		ids := []string{}
+		for _, svc := range state.Services {
+			ids = append(ids, svc.ID)
+		}
+		return ids
+	},
+	entries: func(state *states.ClientState, ids []string) any {
+		entries := []*models.Service{}
+		for _, svc := range state.Services {
+			if ids == nil || slices.Contains(ids, svc.ID) {
+				entries = append(entries, svc)
+			}
+		}
+		return entries
+	},
+	print: func(w io.Writer, state *states.ClientState) {
+		tw := newTable(w)
+		fmt.Fprintln(tw, "ID\tNAME\tENDPOINT\tHEALTH")
+		for _, svc := range state.Services {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", svc.ID, svc.Name, svc.Endpoint, svc.Health)
+		}
+		tw.Flush()
+	},
+	define: func(flags *flag.FlagSet) func(id string, name string) (any, error) {
+		endpoint := flags.String("endpoint", "", "address the service is reached at, health checks probe it (required)")
+		description := flags.String("description", "", "what the service does")
+		metadata := &listFlag{}
+		flags.Var(metadata, "meta", "metadata as key=value, may be repeated")
+		return func(id string, name string) (any, error) {
+			if *endpoint == "" {
+				return nil, fmt.Errorf("--endpoint is required")
+			}
+			pairs, err := splitPairs(*metadata)
+			if err != nil {
+				return nil, err
+			}
+			return &models.Service{ID: id, Name: name, Endpoint: *endpoint, Description: *description, Metadata: pairs}, nil
+		}
+	},
+	fields: []string{"id", "name", "endpoint", "description", "metadata"},
+	owner: func(state *states.ClientState, id string) string {
+		for _, svc := range state.Services {
+			if svc.ID == id {
+				return svc.Owner
+			}
+		}
+		return ""
+	},
+}
+
+var projectsResource = &resource{
+	name:     "projects",
+	singular: "project",
+	list:     messages.TypeListProjects,
+	register: messages.TypeRegisterProjects,
+	remove:   messages.TypeRemoveProjects,
+	ids: func(state *states.ClientState) []string {
+		ids := []string{}
+		for _, project := range state.Projects {
+			ids = append(ids, project.ID)
+		}
+		return ids
+	},
+	entries: func(state *states.ClientState, ids []string) any {
+		entries := []*models.Project{}
+		for _, project := range state.Projects {
+			if ids == nil || slices.Contains(ids, project.ID) {
+				entries = append(entries, project)
+			}
+		}
+		return entries
+	},
+	print: func(w io.Writer, state *states.ClientState) {
+		tw := newTable(w)
+		fmt.Fprintln(tw, "ID\tNAME\tSERVICES\tDESCRIPTION")
+		for _, project := range state.Projects {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", project.ID, project.Name, len(project.Services), project.Description)
+		}
+		tw.Flush()
+	},
+	define: func(flags *flag.FlagSet) func(id string, name string) (any, error) {
+		description := flags.String("description", "", "what the project is about")
+		services := &listFlag{}
+		flags.Var(services, "service", "ID of a service of the project, may be repeated")
+		metadata := &listFlag{}
+		flags.Var(metadata, "meta", "metadata as key=value, may be repeated")
+		return func(id string, name string) (any, error) {
+			pairs, err := splitPairs(*metadata)
+			if err != nil {
+				return nil, err
+			}
+			project := &models.Project{ID: id, Name: name, Description: *description, Metadata: pairs, Services: []string(*services)}
+			if project.Services == nil {
+				project.Services = []string{}
+			}
+			return project, nil
+		}
+	},
+	fields: []string{"id", "name", "description", "metadata", "services"},
+}
+
+var brokersResource = &resource{
+	name:     "brokers",
+	singular: "broker",
+	list:     messages.TypeListBrokers,
+	register: messages.TypeRegisterBroker,
+	remove:   messages.TypeRemoveBroker,
+	ids: func(state *states.ClientState) []string {
+		ids := []string{}
+		for _, broker := range state.Brokers {
+			ids = append(ids, broker.ID)
+		}
+		return ids
+	},
+	entries: func(state *states.ClientState, ids []string) any {
+		entries := []*models.Broker{}
+		for _, broker := range state.Brokers {
+			if ids == nil || slices.Contains(ids, broker.ID) {
+				entries = append(entries, broker)
+			}
+		}
+		return entries
+	},
+	print: func(w io.Writer, state *states.ClientState) {
+		tw := newTable(w)
+		fmt.Fprintln(tw, "ID\tNAME\tURL")
+		for _, broker := range state.Brokers {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", broker.ID, broker.Name, broker.URL)
+		}
+		tw.Flush()
+	},
+	define: func(flags *flag.FlagSet) func(id string, name string) (any, error) {
+		link := flags.String("url", "", "absolute URL of the broker (required)")
+		return func(id string, name string) (any, error) {
+			if *link == "" {
+				return nil, fmt.Errorf("--url is required")
+			}
+			if u, err := url.Parse(*link); err != nil || !u.IsAbs() {
+				return nil, fmt.Errorf("--url must be an absolute URL")
+			}
+			return &models.Broker{ID: id, Name: name, URL: *link}, nil
+		}
+	},
+	fields: []string{"id", "name", "url"},
+}
+
+// run runs "<name> list|register|remove".
+func (res *resource) run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s %s list|register|remove [flags]\n", PROGRAM, res.name)
+		return 2
+	}
+	switch args[0] {
+	case "list":
+		return res.runList(args[1:])
+	case "register":
+		return res.runRegister(args[1:])
+	case "remove":
+		return res.runRemove(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "%s %s: unknown command %q, use list, register or remove\n", PROGRAM, res.name, args[0])
+	return 2
+}
+
+// runList prints the entries the server knows, those of its peers included.
+func (res *resource) runList(args []string) int {
+	flags := commandFlags(res.name+" list", "[flags]")
+	out := addOutputFlags(flags)
+	cfg, err := out.load(flags, args)
+	if err != nil {
+		return out.fail(err)
+	}
+	r, err := connect(cfg)
+	if err != nil {
+		return out.fail(err)
+	}
+	defer r.close()
+
+	if _, err := r.wait(res.list, nil, REQUEST_TIMEOUT); err != nil {
+		return out.fail(err)
+	}
+	state := r.client.Pull()
+	err = out.list(os.Stdout, res.entries(state, nil), func(w io.Writer) {
+		res.print(w, state)
+	})
+	if err != nil {
+		return out.fail(err)
+	}
+	return 0
+}
+
+// runRegister creates an entry named by the arguments, or updates the one
+// with --id. Once the server lists it, the table output prints its ID, the
+// others the entry.
+func (res *resource) runRegister(args []string) int {
+	flags := commandFlags(res.name+" register", "[flags] <name>")
+	out := addOutputFlags(flags)
+	id := flags.String("id", "", fmt.Sprintf("ID of the %s to update, a new one is created if empty", res.singular))
+	build := res.define(flags)
+	cfg, err := out.load(flags, args)
+	if err != nil {
+		return out.fail(err)
+	}
+	name := strings.Join(flags.Args(), " ")
+	if name == "" {
+		return out.fail(usageError("a name is required"))
+	}
+	if *id == "" {
+		*id = uuid.NewString()
+	}
+	entry, err := build(*id, name)
+	if err != nil {
+		return out.fail(usageError("%s", err))
+	}
+
+	r, err := connect(cfg)
+	if err != nil {
+		return out.fail(err)
+	}
+	defer r.close()
+
+	// the list the client asks for on connecting must not be taken for the
+	// one the server sends after the change
+	if _, err := r.wait(res.list, nil, REQUEST_TIMEOUT); err != nil {
+		return out.fail(err)
+	}
+	if err := r.send(&messages.Message{Type: res.register, Payload: entry}); err != nil {
+		return out.fail(err)
+	}
+	// lists sent for other reasons, e.g. after a health check, may already
+	// hold the entry when it is updated, so the values have to match as well
+	_, err = r.wait(res.list, func(_ *messages.Message, state *states.ClientState) bool {
+		return res.applied(state, *id, entry)
+	}, REQUEST_TIMEOUT)
+	if err != nil {
+		return out.fail(fmt.Errorf("%s was not registered: %w", res.singular, err))
+	}
+	err = out.list(os.Stdout, res.entries(r.client.Pull(), []string{*id}), func(w io.Writer) {
+		fmt.Fprintln(w, *id)
+	})
+	if err != nil {
+		return out.fail(err)
+	}
+	return 0
+}
+
+// applied reports whether the listed entry with id has the values of the
+// sent entry in every field the register command sets.
+func (res *resource) applied(state *states.ClientState, id string, sent any) bool {
+	listed, err := generic(res.entries(state, []string{id}))
+	if err != nil {
+		return false
+	}
+	items, ok := listed.([]any)
+	if !ok || len(items) == 0 {
+		return false
+	}
+	want, err := generic(sent)
+	if err != nil {
+		return false
+	}
+	got, gotOk := items[0].(map[string]any)
+	wanted, wantOk := want.(map[string]any)
+	if !gotOk || !wantOk {
+		return false
+	}
+	for _, field := range res.fields {
+		if !reflect.DeepEqual(got[field], wanted[field]) {
+			return false
+		}
+	}
+	return true
+}
+
+// runRemove removes the entries with the IDs given as arguments and waits
+// until the server no longer lists them. The outputs other than table print
+// the removed entries.
+func (res *resource) runRemove(args []string) int {
+	flags := commandFlags(res.name+" remove", "[flags] <id>...")
+	out := addOutputFlags(flags)
+	cfg, err := out.load(flags, args)
+	if err != nil {
+		return out.fail(err)
+	}
+	ids := flags.Args()
+	if len(ids) == 0 {
+		return out.fail(usageError("at least one ID is required"))
+	}
+
+	r, err := connect(cfg)
+	if err != nil {
+		return out.fail(err)
+	}
+	defer r.close()
+
+	listed, err := r.wait(res.list, nil, REQUEST_TIMEOUT)
+	if err != nil {
+		return out.fail(err)
+	}
+	state := r.client.Pull()
+	known := res.ids(state)
+	for _, id := range ids {
+		if !slices.Contains(known, id) {
+			return out.fail(notFoundError("unknown %s %s", res.singular, id))
+		}
+		// a server only removes its own entries, the ones of its peers
+		// would stay and the command wait in vain
+		if res.owner == nil || listed.Sender == "" {
+			continue
+		}
+		if owner := res.owner(state, id); owner != "" && owner != listed.Sender {
+			return out.fail(fmt.Errorf("%s %s belongs to the peer %s, remove it there", res.singular, id, owner))
+		}
+	}
+	removed := res.entries(state, ids)
+	for _, id := range ids {
+		if err := r.send(&messages.Message{Type: res.remove, Payload: id}); err != nil {
+			return out.fail(err)
+		}
+	}
+	_, err = r.wait(res.list, func(_ *messages.Message, state *states.ClientState) bool {
+		remaining := res.ids(state)
+		return !slices.ContainsFunc(ids, func(id string) bool { return slices.Contains(remaining, id) })
+	}, REQUEST_TIMEOUT)
+	if err != nil {
+		return out.fail(fmt.Errorf("%s was not removed: %w", res.singular, err))
+	}
+	if err := out.list(os.Stdout, removed, func(io.Writer) {}); err != nil {
+		return out.fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"p1/pkg/models"
+	"p1/pkg/states"
+)
+
+func TestResourceApplied(t *testing.T) {
+	listed := &models.Service{ID: "a", Name: "api", Endpoint: "localhost:80", Metadata: map[string]string{"env": "dev"}, Health: "up", Owner: "server"}
+	state := &states.ClientState{Services: []*models.Service{listed}}
+
+	tests := []struct {
+		name string
+		id   string
+		sent *models.Service
+		want bool
+	}{
+		{name: "same values", id: "a", sent: &models.Service{ID: "a", Name: "api", Endpoint: "localhost:80", Metadata: map[string]string{"env": "dev"}}, want: true},
+		{name: "not applied yet", id: "a", sent: &models.Service{ID: "a", Name: "api", Endpoint: "localhost:81", Metadata: map[string]string{"env": "dev"}}},
+		{name: "other metadata", id: "a", sent: &models.Service{ID: "a", Name: "api", Endpoint: "localhost:80", Metadata: map[string]string{}}},
+		{name: "not listed", id: "b", sent: &models.Service{ID: "b", Name: "api", Endpoint: "localhost:80"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := servicesResource.applied(state, tt.id, tt.sent); got != tt.want {
+				t.Errorf("applied = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
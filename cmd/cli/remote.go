@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"p1/pkg/client"
+	"p1/pkg/config"
+	"p1/pkg/discovery"
+	"p1/pkg/logs"
+	"p1/pkg/messages"
+	"p1/pkg/states"
+
+	"github.com/google/uuid"
+)
+
+const REQUEST_TIMEOUT = 10 * time.Second // How long a command waits for an answer of the server
+
+// remote is the connection of a command to a running server.
+type remote struct {
+	client      *client.Client
+	messages    chan *messages.Message // Every message after it was processed
+	done        chan struct{}
+	closer      io.Closer       // Of the log file
+	interrupted context.Context // Done once the command is interrupted
+	stop        context.CancelFunc
+}
+
+// errInterrupted is returned by wait when the command was interrupted.
+var errInterrupted = errors.New("interrupted")
+
+// connect connects to the server cfg names: the first --connect address,
+// or the one announced in the data directory.
+func connect(cfg *config.Config) (*remote, error) {
+	// the terminal shows the output of the command, logs go to the file
+	_, closer, err := setupLogging(cfg, logs.NewHub(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	link := ""
+	if cfg.Connect != "" {
+		links, err := splitLinks(cfg.Connect)
+		if err != nil {
+			closer.Close()
+			return nil, err
+		}
+		link = links[0]
+	} else {
+		info, err := discovery.Find(cfg.DataDir)
+		if err != nil {
+			closer.Close()
+			return nil, unavailableError(fmt.Errorf("no server given with --%s and %w", config.FLAG_CONNECT, err))
+		}
+		link = info.WSLink
+	}
+
+	r := &remote{
+		messages: make(chan *messages.Message, 64),
+		done:     make(chan struct{}),
+		closer:   closer,
+	}
+	r.interrupted, r.stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	r.client = client.NewClient(link, client.ClientOptions{
+		PingInterval: cfg.PingInterval,
+		PongTimeout:  cfg.PongTimeout,
+		Name:         cfg.Name,
+		AuthToken:    cfg.ClientAuthToken(),
+		OnMessage: func(msg *messages.Message) {
+			select {
+			case r.messages <- msg:
+			case <-r.done:
+			}
+		},
+	})
+	if err := r.client.Init(); err != nil {
+		r.stop()
+		closer.Close()
+		return nil, unavailableError(fmt.Errorf("failed to connect to %s: %w", link, err))
+	}
+	if err := r.client.Start(); err != nil {
+		r.stop()
+		closer.Close()
+		return nil, unavailableError(err)
+	}
+	return r, nil
+}
+
+func (r *remote) close() {
+	r.stop()
+	close(r.done)
+	r.client.Stop()
+	r.closer.Close()
+}
+
+// wait returns the first message of type t that match accepts, match may be
+// nil to take any. It fails if none arrives within timeout or the command
+// is interrupted.
+func (r *remote) wait(t messages.MessageType, match func(msg *messages.Message, state *states.ClientState) bool, timeout time.Duration) (*messages.Message, error) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg := <-r.messages:
+			if msg.Type == t && (match == nil || match(msg, r.client.Pull())) {
+				return msg, nil
+			}
+		case <-deadline:
+			return nil, timeoutError(fmt.Errorf("no answer from the server within %s", timeout))
+		case <-r.interrupted.Done():
+			return nil, errInterrupted
+		}
+	}
+}
+
+func (r *remote) send(msg *messages.Message) error {
+	return r.client.SendMessage(msg)
+}
+
+// runMetrics prints a sample of the server's metrics, or with --watch one
+// sample every metrics interval until interrupted. The table shows usage,
+// which needs two samples, so its first one takes up to twice the metrics
+// interval of the server. The other outputs print the samples as the server
+// sent them.
+func runMetrics(args []string) int {
+	flags := commandFlags("metrics", "[flags]")
+	out := addOutputFlags(flags)
+	watch := flags.Bool("watch", false, "print a sample every metrics interval until interrupted")
+	cfg, err := out.load(flags, args)
+	if err != nil {
+		return out.fail(err)
+	}
+	r, err := connect(cfg)
+	if err != nil {
+		return out.fail(err)
+	}
+	defer r.close()
+
+	for samples := 0; ; samples++ {
+		msg, err := r.wait(messages.TypeMetrics, nil, cfg.MetricsInterval+REQUEST_TIMEOUT)
+		if errors.Is(err, errInterrupted) && *watch {
+			return 0
+		}
+		if err != nil {
+			return out.fail(err)
+		}
+		var sample messages.ServerMetrics
+		if err := msg.DecodePayload(&sample); err != nil {
+			return out.fail(err)
+		}
+		if samples == 0 && out.format == OUTPUT_TABLE {
+			// the first sample has no usage yet
+			continue
+		}
+
+		metrics := r.client.Pull().Metrics[r.client.Origin()]
+		if !*watch {
+			err := out.list(os.Stdout, sample, func(w io.Writer) {
+				tw := newTable(w)
+				fmt.Fprintf(tw, "CPU\t%.1f%%\n", metrics.CPU)
+				fmt.Fprintf(tw, "RAM\t%.1f%%\n", metrics.RAM)
+				fmt.Fprintf(tw, "DISK\t%.1f%%\n", metrics.Disk)
+				fmt.Fprintf(tw, "NETWORK\t%.1f KiB/s\n", metrics.Network)
+				tw.Flush()
+			})
+			if err != nil {
+				return out.fail(err)
+			}
+			return 0
+		}
+		err = out.stream(os.Stdout, sample, func(w io.Writer) {
+			if samples == 1 {
+				fmt.Fprintf(w, "%-8s  %6s  %6s  %6s  %12s\n", "TIME", "CPU", "RAM", "DISK", "NETWORK")
+			}
+			fmt.Fprintf(w, "%-8s  %5.1f%%  %5.1f%%  %5.1f%%  %6.1f KiB/s\n", time.Now().Format(time.TimeOnly), metrics.CPU, metrics.RAM, metrics.Disk, metrics.Network)
+		})
+		if err != nil {
+			return out.fail(err)
+		}
+	}
+}
+
+// runBroadcast sends the arguments as a message to every client of the
+// server and waits until the server relayed it. The outputs other than
+// table print the relayed message.
+func runBroadcast(args []string) int {
+	flags := commandFlags("broadcast", "[flags] <message>")
+	out := addOutputFlags(flags)
+	cfg, err := out.load(flags, args)
+	if err != nil {
+		return out.fail(err)
+	}
+	text := strings.Join(flags.Args(), " ")
+	if text == "" {
+		return out.fail(usageError("a message is required"))
+	}
+	r, err := connect(cfg)
+	if err != nil {
+		return out.fail(err)
+	}
+	defer r.close()
+
+	chat := messages.Chat{ID: uuid.New().String(), Text: text}
+	if err := r.send(&messages.Message{Type: messages.TypeBroadcast, Payload: chat}); err != nil {
+		return out.fail(err)
+	}
+	var relayed messages.Chat
+	_, err = r.wait(messages.TypeBroadcast, func(msg *messages.Message, _ *states.ClientState) bool {
+		return msg.DecodePayload(&relayed) == nil && relayed.ID == chat.ID
+	}, REQUEST_TIMEOUT)
+	if err != nil {
+		return out.fail(err)
+	}
+	if err := out.list(os.Stdout, relayed, func(io.Writer) {}); err != nil {
+		return out.fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlPair is a field of an object, objects keep the order of the fields
+// of the wire type.
+type yamlPair struct {
+	key   string
+	value any
+}
+
+// toYAML writes v as YAML with the field names and order of its JSON
+// encoding.
+func toYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := decodeOrdered(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	switch node := node.(type) {
+	case []yamlPair:
+		if len(node) == 0 {
+			b.WriteString("{}\n")
+		}
+		writePairs(&b, node, 0, "")
+	case []any:
+		if len(node) == 0 {
+			b.WriteString("[]\n")
+		}
+		writeItems(&b, node, 0)
+	default:
+		b.WriteString(yamlScalar(node) + "\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// decodeOrdered reads the next value, objects become []yamlPair.
+func decodeOrdered(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		pairs := []yamlPair{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, yamlPair{key: key.(string), value: value})
+		}
+		_, err := decoder.Token()
+		return pairs, err
+	case json.Delim('['):
+		items := []any{}
+		for decoder.More() {
+			item, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := decoder.Token()
+		return items, err
+	}
+	return token, nil
+}
+
+// writePairs writes the fields of an object at indent. The first one is
+// prefixed with first instead, which puts it on the line of a list dash.
+func writePairs(b *strings.Builder, pairs []yamlPair, indent int, first string) {
+	for i, pair := range pairs {
+		if i == 0 && first != "" {
+			b.WriteString(first)
+		} else {
+			b.WriteString(strings.Repeat(" ", indent))
+		}
+		b.WriteString(yamlScalar(pair.key) + ":")
+		writeValue(b, pair.value, indent+2)
+	}
+}
+
+func writeItems(b *strings.Builder, items []any, indent int) {
+	for _, item := range items {
+		dash := strings.Repeat(" ", indent) + "- "
+		if pairs, ok := item.([]yamlPair); ok && len(pairs) > 0 {
+			writePairs(b, pairs, indent+2, dash)
+			continue
+		}
+		b.WriteString(strings.TrimSuffix(dash, " "))
+		writeValue(b, item, indent+2)
+	}
+}
+
+// writeValue writes the value of a field or list item, after its key or
+// dash, and ends the line.
+func writeValue(b *strings.Builder, value any, indent int) {
+	switch value := value.(type) {
+	case []yamlPair:
+		if len(value) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writePairs(b, value, indent, "")
+	case []any:
+		if len(value) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeItems(b, value, indent)
+	default:
+		b.WriteString(" " + yamlScalar(value) + "\n")
+	}
+}
+
+// yamlScalar formats a string, number, boolean or null. Strings that YAML
+// would read as something else are quoted.
+func yamlScalar(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		if needsQuotes(value) {
+			return strconv.Quote(value)
+		}
+		return value
+	}
+	return fmt.Sprint(value)
+}
+
+func needsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "y", "n", "on", "off", "null", "~", ".inf", ".nan":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	// YAML 1.1 reads 0x10 and 0o17 as numbers too
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	// a colon ending the string would start a mapping
+	return strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s, "\n\r\t\\")
+}
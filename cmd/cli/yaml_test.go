@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"p1/pkg/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestToYAML(t *testing.T) {
+	type entry struct {
+		Name  string  `json:"name"`
+		Value any     `json:"value"`
+		Note  *string `json:"note"`
+	}
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{
+			name: "scalars",
+			value: []entry{
+				{Name: "yes", Value: "yes"},
+				{Name: "Off", Value: "Off"},
+				{Name: "y", Value: "y"},
+				{Name: "null", Value: "null"},
+				{Name: "tilde", Value: "~"},
+				{Name: "nil", Value: nil},
+				{Name: "bool", Value: true},
+				{Name: "int", Value: 8080},
+				{Name: "float", Value: 1.5},
+				{Name: "numeric string", Value: "8080"},
+				{Name: "hex string", Value: "0x10"},
+				{Name: "inf", Value: ".inf"},
+				{Name: "empty", Value: ""},
+				{Name: "padded", Value: " a "},
+			},
+		},
+		{
+			name: "colons",
+			value: []entry{
+				{Name: "address", Value: "localhost:8080"},
+				{Name: "link", Value: "ws://localhost:8080/ws"},
+				{Name: "key value", Value: "key: value"},
+				{Name: "trailing", Value: "key:"},
+				{Name: "leading", Value: ":key"},
+				{Name: "comment", Value: "a #b"},
+				{Name: "hash", Value: "a#b"},
+			},
+		},
+		{
+			name: "dashes",
+			value: []entry{
+				{Name: "dash", Value: "-"},
+				{Name: "flag", Value: "--watch"},
+				{Name: "negative", Value: "-1"},
+				{Name: "inner", Value: "a-b"},
+				{Name: "list item", Value: "- item"},
+			},
+		},
+		{
+			name: "multiline",
+			value: []entry{
+				{Name: "lines", Value: "first\nsecond\n"},
+				{Name: "tab", Value: "a\tb"},
+				{Name: "quotes", Value: `say "hi"`},
+				{Name: "backslash", Value: `C:\data`},
+			},
+		},
+		{
+			name: "nested",
+			value: []*models.Service{
+				{ID: "a", Name: "api", Endpoint: "localhost:80", Metadata: map[string]string{"env": "dev", "team: x": "yes"}, Health: "up", Conflicts: []string{"p1", "p2"}},
+				{ID: "b", Name: "-b", Metadata: map[string]string{}},
+			},
+		},
+		{name: "empty-list", value: []string{}},
+		{name: "empty-object", value: struct{}{}},
+		{name: "scalar", value: "null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toYAML(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.name+".yaml")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("toYAML =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
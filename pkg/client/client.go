@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"p1/pkg/config"
+	"p1/pkg/logs"
 	"p1/pkg/messages"
 	"p1/pkg/models"
 	"p1/pkg/states"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,27 +22,102 @@ import (
 
 const (
 	DEFAULT_TIMEOUT         = 10 * time.Second
-	MAX_RECONNECT_ATTEMPTS  = 5
 	INITIAL_RECONNECT_DELAY = 1 * time.Second
+	MAX_RECONNECT_DELAY     = 30 * time.Second // Cap of the backoff between reconnect attempts
+	MAX_OUTBOX_SIZE         = 256              // Outgoing messages queued while disconnected
+	MAX_CHAT_HISTORY        = 500              // Broadcasts kept for the scrollback
+	MAX_LOG_HISTORY         = 1000             // Log entries of the server kept while following them
 )
 
+type ClientOptions struct {
+	PingInterval time.Duration             // How often the server is pinged
+	PongTimeout  time.Duration             // How long to wait for a pong before the peer is considered dead
+	Origin       string                    // Tag put on everything received, defaults to the link
+	Name         string                    // Shown to other clients, e.g. in the broadcast screen
+	AuthToken    string                    // Presented to servers that require one
+	OnChange     func()                    // Called after the state was updated
+	OnNotify     func(models.Notification) // Called for server events worth telling the user
+	OnMessage    func(*messages.Message)   // Called after a message from the server was processed
+}
+
 type Client struct {
-	cid   string
-	link  string
-	state *states.ClientState
-	conn  *websocket.Conn
+	cid     string
+	name    string
+	auth    string // Auth token presented in the handshake
+	link    string
+	origin  string
+	state   *states.ClientState
+	stateMu sync.RWMutex // Protects state, written by the reader goroutine
+	conn    *websocket.Conn
+
+	onChange    func()
+	onNotify    func(models.Notification)
+	onMessage   func(*messages.Message)
+	lastMetrics *metricsSample // Previous raw sample, needed for rates
+
+	mu        sync.Mutex          // Serializes writes and protects the session fields
+	sessionID string              // Assigned by the server, sent back on reconnect
+	token     string              // Issued with the session, proves it is ours
+	lastSeq   uint64              // Highest sequence number received
+	outbox    []*messages.Message // Messages sent while disconnected
+	connected bool                // A session is attached and writes go out directly
+	following bool                // The server's logs are followed
+	stopped   bool
+	quit      chan struct{} // Closed by Stop, cuts the wait between reconnect attempts short
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	pingSent     time.Time     // When the outstanding ping was written
+	latency      time.Duration // Round-trip time of the last answered ping
+	reconnects   int           // Successful reconnects since start
+	lastMessage  time.Time     // When anything was last received
+	version      string        // Version the server reported in the handshake
 }
 
-func NewClient(mainServerLink string) *Client {
+func NewClient(mainServerLink string, options ClientOptions) *Client {
+	if options.PingInterval <= 0 {
+		options.PingInterval = config.DEFAULT_PING_INTERVAL
+	}
+	if options.PongTimeout <= 0 {
+		options.PongTimeout = config.DEFAULT_PONG_TIMEOUT
+	}
+	if options.Origin == "" {
+		options.Origin = mainServerLink
+	}
+	if options.OnChange == nil {
+		options.OnChange = func() {}
+	}
+	if options.OnNotify == nil {
+		options.OnNotify = func(models.Notification) {}
+	}
+	if options.OnMessage == nil {
+		options.OnMessage = func(*messages.Message) {}
+	}
+
 	cid := uuid.New().String()
 	c := &Client{
-		cid:  cid,
-		link: mainServerLink,
+		cid:    cid,
+		name:   options.Name,
+		auth:   options.AuthToken,
+		link:   mainServerLink,
+		origin: options.Origin,
 		state: &states.ClientState{
 			Projects: []*models.Project{},
 			Servers:  []*models.Server{},
 			Brokers:  []*models.Broker{},
+			Services: []*models.Service{},
+			Metrics:  map[string]*models.Metrics{},
+			Clients:  []*models.Participant{},
+			Chat:     []*models.ChatMessage{},
+			Logs:     []*logs.Entry{},
 		},
+		outbox:       []*messages.Message{},
+		quit:         make(chan struct{}),
+		pingInterval: options.PingInterval,
+		pongTimeout:  options.PongTimeout,
+		onChange:     options.OnChange,
+		onNotify:     options.OnNotify,
+		onMessage:    options.OnMessage,
 	}
 	return c
 }
@@ -46,101 +127,449 @@ func (c *Client) Init() error {
 		HandshakeTimeout: DEFAULT_TIMEOUT,
 	}
 
+	c.mu.Lock()
 	headers := http.Header{}
 	headers.Add("X-Client-Id", c.cid)
+	if c.name != "" {
+		headers.Add("X-Client-Name", c.name)
+	}
+	if c.auth != "" {
+		headers.Add("Authorization", "Bearer "+c.auth)
+	}
+	if c.sessionID != "" {
+		headers.Add("X-Session-Id", c.sessionID)
+		headers.Add("X-Session-Token", c.token)
+		headers.Add("X-Last-Seq", strconv.FormatUint(c.lastSeq, 10))
+	}
+	c.mu.Unlock()
 
 	conn, resp, err := dialer.Dial(c.link, headers)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("failed to Dial: the server did not accept the auth token")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to Dial %v", err.Error())
 	}
 	if resp.StatusCode != http.StatusSwitchingProtocols {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
+
+	// Every pong or message pushes the read deadline out again. If the peer
+	// stops answering, the read fails and the reader goroutine reconnects.
+	conn.SetReadDeadline(time.Now().Add(c.pingInterval + c.pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		c.mu.Lock()
+		if !c.pingSent.IsZero() {
+			c.latency = time.Since(c.pingSent)
+			c.pingSent = time.Time{}
+		}
+		c.mu.Unlock()
+		return conn.SetReadDeadline(time.Now().Add(c.pingInterval + c.pongTimeout))
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		// stopped while dialing
+		conn.Close()
+		return fmt.Errorf("client stopped")
+	}
 	c.conn = conn
 	return nil
 }
 
 func (c *Client) Stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.stopped {
+		c.stopped = true
+		close(c.quit)
+	}
+	c.connected = false
 	if c.conn == nil {
 		return fmt.Errorf("there is no active connection")
 	}
 	return c.conn.Close()
 }
 
+// reconnect dials the server until it answers or the client is stopped,
+// doubling the delay between the attempts up to MAX_RECONNECT_DELAY.
 func (c *Client) reconnect() error {
 	delay := INITIAL_RECONNECT_DELAY
-	attempts := 0
 
-	for attempts < MAX_RECONNECT_ATTEMPTS {
-		slog.Info("attempting to reconnect", "attempt", attempts+1)
+	for attempt := 1; ; attempt++ {
+		c.mu.Lock()
+		stopped, sessionID := c.stopped, c.sessionID
+		c.mu.Unlock()
+		if stopped {
+			return fmt.Errorf("client stopped")
+		}
+		slog.Info("attempting to reconnect", "attempt", attempt, "session", sessionID)
 		err := c.Init()
 		if err == nil {
 			slog.Info("reconnected successfully")
+			c.mu.Lock()
+			c.reconnects++
+			c.mu.Unlock()
 			return nil
 		}
 
-		attempts++
-		slog.Error("reconnection failed", "error", err, "attempt", attempts)
-		time.Sleep(delay)
-		delay *= 2 // exponential backoff
+		slog.Error("reconnection failed", "error", err, "attempt", attempt, "retry", delay)
+		select {
+		case <-c.quit:
+			return fmt.Errorf("client stopped")
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, MAX_RECONNECT_DELAY) // exponential backoff
 	}
+}
+
+func (c *Client) currentConn() *websocket.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+func (c *Client) isStopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
 
-	return fmt.Errorf("failed to reconnect after %d attempts", MAX_RECONNECT_ATTEMPTS)
+// keepalive pings the server every pingInterval until done is closed.
+// A ping that is still unanswered when the next one is due counts as missed;
+// the read deadline set in Init turns missed pongs into a disconnect.
+func (c *Client) keepalive(done <-chan struct{}) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		c.mu.Lock()
+		if c.conn == nil || !c.connected {
+			c.mu.Unlock()
+			continue
+		}
+		if !c.pingSent.IsZero() {
+			slog.Warn("missed pong", "since", time.Since(c.pingSent))
+		}
+		c.pingSent = time.Now()
+		err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.pongTimeout))
+		c.mu.Unlock()
+		if err != nil {
+			slog.Error("failed to send ping", "error", err)
+		}
+	}
+}
+
+// Stats returns the current connection health.
+func (c *Client) Stats() models.ConnectionStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return models.ConnectionStatus{
+		Connected:   c.connected,
+		Latency:     c.latency,
+		Reconnects:  c.reconnects,
+		LastMessage: c.lastMessage,
+		Version:     c.version,
+	}
+}
+
+// disconnected marks the session as detached so that outgoing messages are
+// queued until the next resume.
+func (c *Client) disconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = false
+	c.pingSent = time.Time{}
+}
+
+// resumed handles the server's answer to the handshake. Queued messages are
+// flushed once the session is attached again; if the server could not replay
+// what was missed, the full state is requested again.
+func (c *Client) resumed(info messages.SessionInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sessionID == "" {
+		// first connect, nothing to resume but the initial state is needed
+		info.Resync = true
+	} else if c.sessionID != info.SessionID {
+		slog.Warn("server started a new session", "old", c.sessionID, "new", info.SessionID)
+		info.Resync = true
+	}
+	c.sessionID = info.SessionID
+	c.token = info.Token
+	c.version = info.Version
+	if !info.Resumed {
+		c.lastSeq = info.Seq
+	}
+	c.connected = true
+
+	pending := c.outbox
+	c.outbox = []*messages.Message{}
+	if info.Resync {
+		slog.Info("requesting full state", "session", info.SessionID)
+		pending = append(syncRequests(), pending...)
+		if c.following {
+			// a new session does not know it is being followed
+			c.clearLogs()
+			pending = append(pending, &messages.Message{Type: messages.TypeFollowLogs, Payload: true})
+		}
+	}
+
+	for i, msg := range pending {
+		if err := c.conn.WriteJSON(msg); err != nil {
+			c.connected = false
+			c.outbox = append(c.outbox, pending[i:]...)
+			return err
+		}
+	}
+	if len(pending) > 0 {
+		slog.Info("flushed queued messages", "count", len(pending))
+	}
+	return nil
+}
+
+// syncRequests returns the messages needed to rebuild the client state from scratch.
+func syncRequests() []*messages.Message {
+	return []*messages.Message{
+		{Type: messages.TypeListServices},
+		{Type: messages.TypeListBrokers},
+		{Type: messages.TypeListProjects},
+		{Type: messages.TypeListClients},
+	}
 }
 
 func (c *Client) processMessage(msg *messages.Message) error {
+	if msg.Seq > 0 {
+		c.mu.Lock()
+		if msg.Seq <= c.lastSeq {
+			// already seen before the reconnect
+			c.mu.Unlock()
+			return nil
+		}
+		c.lastSeq = msg.Seq
+		c.mu.Unlock()
+	}
+
 	switch msg.Type {
+	case messages.TypeSession:
+		var info messages.SessionInfo
+		if err := msg.DecodePayload(&info); err != nil {
+			return err
+		}
+		return c.resumed(info)
 	case messages.TypeMetrics:
-		if metrics, ok := msg.Payload.(*models.Metrics); ok {
-			c.state.Metrics = metrics
+		var sample metricsSample
+		if err := msg.DecodePayload(&sample); err != nil {
+			return err
+		}
+		if sample.At.IsZero() {
+			// older servers do not time their samples
+			sample.At = time.Now()
 		}
+		c.stateMu.Lock()
+		c.state.Metrics[c.origin] = sample.toMetrics(c.lastMetrics, c.origin)
+		c.lastMetrics = &sample
+		c.stateMu.Unlock()
 	case messages.TypeListServices:
-		if servers, ok := msg.Payload.([]*models.Server); ok {
-			c.state.Servers = servers
+		var services []*models.Service
+		if err := msg.DecodePayload(&services); err != nil {
+			return err
+		}
+		for _, svc := range services {
+			svc.Origin = c.origin
 		}
+		c.stateMu.Lock()
+		previous := c.state.Services
+		c.state.Services = services
+		c.stateMu.Unlock()
+		c.healthChanges(previous, services)
 	case messages.TypeListBrokers:
-		if brokers, ok := msg.Payload.([]*models.Broker); ok {
-			c.state.Brokers = brokers
+		var brokers []*models.Broker
+		if err := msg.DecodePayload(&brokers); err != nil {
+			return err
+		}
+		for _, broker := range brokers {
+			broker.Origin = c.origin
+		}
+		c.stateMu.Lock()
+		c.state.Brokers = brokers
+		c.stateMu.Unlock()
+	case messages.TypeListProjects:
+		var projects []*models.Project
+		if err := msg.DecodePayload(&projects); err != nil {
+			return err
+		}
+		for _, project := range projects {
+			project.Origin = c.origin
+		}
+		c.stateMu.Lock()
+		c.state.Projects = projects
+		c.stateMu.Unlock()
+	case messages.TypeListClients:
+		var clients []messages.ClientInfo
+		if err := msg.DecodePayload(&clients); err != nil {
+			return err
+		}
+		participants := make([]*models.Participant, 0, len(clients))
+		for _, client := range clients {
+			participants = append(participants, c.participant(client))
+		}
+		c.stateMu.Lock()
+		c.state.Clients = participants
+		c.stateMu.Unlock()
+	case messages.TypeBroadcast:
+		var chat messages.Chat
+		if err := msg.DecodePayload(&chat); err != nil {
+			return err
+		}
+		message := &models.ChatMessage{
+			ID:     chat.ID,
+			Text:   chat.Text,
+			From:   *c.participant(chat.From),
+			At:     chat.At,
+			Origin: c.origin,
 		}
+		c.stateMu.Lock()
+		c.state.Chat = append(c.state.Chat, message)
+		if len(c.state.Chat) > MAX_CHAT_HISTORY {
+			c.state.Chat = c.state.Chat[len(c.state.Chat)-MAX_CHAT_HISTORY:]
+		}
+		c.stateMu.Unlock()
+		if !message.From.Self {
+			c.notify(models.SeverityInfo, fmt.Sprintf("%s: %s", message.From.DisplayName(), chat.Text))
+		}
+	case messages.TypeLogs:
+		var entries []logs.Entry
+		if err := msg.DecodePayload(&entries); err != nil {
+			return err
+		}
+		c.stateMu.Lock()
+		for _, entry := range entries {
+			entry.Origin = c.origin
+			c.state.Logs = append(c.state.Logs, &entry)
+		}
+		if len(c.state.Logs) > MAX_LOG_HISTORY {
+			c.state.Logs = c.state.Logs[len(c.state.Logs)-MAX_LOG_HISTORY:]
+		}
+		c.stateMu.Unlock()
+	case messages.TypeConfigReloaded:
+		var reload messages.ConfigReload
+		if err := msg.DecodePayload(&reload); err != nil {
+			return err
+		}
+		if len(reload.Applied) > 0 {
+			c.notify(models.SeverityInfo, "Configuration reloaded: "+strings.Join(reload.Applied, ", "))
+		}
+		if len(reload.Restart) > 0 {
+			c.notify(models.SeverityWarning, "Restart the server to apply: "+strings.Join(reload.Restart, ", "))
+		}
+		return nil
+	case messages.TypeClientJoined:
+		var info messages.ClientInfo
+		if err := msg.DecodePayload(&info); err != nil {
+			return err
+		}
+		c.notify(models.SeverityInfo, fmt.Sprintf("%s joined", c.participant(info).DisplayName()))
+		return nil
+	default:
+		return nil
 	}
+	c.onChange()
 	return nil
 }
 
+// notify raises a notification tagged with the client's origin.
+func (c *Client) notify(severity models.Severity, message string) {
+	notification := models.NewNotification(severity, message)
+	notification.Origin = c.origin
+	c.onNotify(notification)
+}
+
+// healthChanges raises a notification for every known service that went
+// down or came back up. Services seen for the first time are not reported.
+func (c *Client) healthChanges(previous []*models.Service, services []*models.Service) {
+	health := make(map[string]string, len(previous))
+	for _, svc := range previous {
+		health[svc.ID] = svc.Health
+	}
+	for _, svc := range services {
+		before, ok := health[svc.ID]
+		switch {
+		case !ok || before == svc.Health:
+		case svc.Health == "down":
+			c.notify(models.SeverityWarning, fmt.Sprintf("Service %s went down", svc.Name))
+		case before == "down" && svc.Health == "up":
+			c.notify(models.SeveritySuccess, fmt.Sprintf("Service %s is up again", svc.Name))
+		}
+	}
+}
+
+// participant converts client to its model, tagged with the client's origin.
+func (c *Client) participant(client messages.ClientInfo) *models.Participant {
+	return &models.Participant{
+		SessionID: client.SessionID,
+		ClientID:  client.ClientID,
+		Name:      client.Name,
+		Origin:    c.origin,
+		Self:      client.ClientID == c.cid,
+	}
+}
+
 func (c *Client) Start() error {
-	if c.conn == nil {
+	if c.currentConn() == nil {
 		return fmt.Errorf("no active connection")
 	}
 
+	// closed once the client was stopped
+	done := make(chan struct{})
+	go c.keepalive(done)
+
 	go func() {
+		defer close(done)
 		for {
-			messageType, message, err := c.conn.ReadMessage()
+			conn := c.currentConn()
+			messageType, message, err := conn.ReadMessage()
 			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					slog.Error("websocket error", "error", err)
-					if reconnectErr := c.reconnect(); reconnectErr != nil {
-						slog.Error("failed to reconnect", "error", reconnectErr)
-						return
-					}
-					continue
+				c.disconnected()
+				if c.isStopped() {
+					return
 				}
-				return
+				slog.Error("websocket error", "error", err)
+				if reconnectErr := c.reconnect(); reconnectErr != nil {
+					// only fails once the client was stopped
+					return
+				}
+				continue
 			}
 
+			c.mu.Lock()
+			c.lastMessage = time.Now()
+			c.mu.Unlock()
+			conn.SetReadDeadline(time.Now().Add(c.pingInterval + c.pongTimeout))
+
 			switch messageType {
 			case websocket.TextMessage:
-				slog.Info("received text message", "message", string(message))
 				var msg messages.Message
 				if err := json.Unmarshal(message, &msg); err != nil {
 					slog.Error("failed to unmarshal message", "error", err)
 					continue
 				}
+				slog.Debug("received text message", "type", msg.Type)
 
 				if err := c.processMessage(&msg); err != nil {
 					slog.Error("failed to process message", "error", err)
 					continue
 				}
+				c.onMessage(&msg)
 			case websocket.BinaryMessage:
-				slog.Info("received binary message", "size", len(message))
+				slog.Debug("received binary message", "size", len(message))
 
 			}
 		}
@@ -149,10 +578,67 @@ func (c *Client) Start() error {
 	return nil
 }
 
+// Pull returns a snapshot of the client state. The slices are copied, the
+// entries are shared.
 func (c *Client) Pull() *states.ClientState {
-	return c.state
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	metrics := make(map[string]*models.Metrics, len(c.state.Metrics))
+	for origin, m := range c.state.Metrics {
+		metrics[origin] = m
+	}
+	return &states.ClientState{
+		Projects: slices.Clone(c.state.Projects),
+		Servers:  slices.Clone(c.state.Servers),
+		Brokers:  slices.Clone(c.state.Brokers),
+		Services: slices.Clone(c.state.Services),
+		Metrics:  metrics,
+		Clients:  slices.Clone(c.state.Clients),
+		Chat:     slices.Clone(c.state.Chat),
+		Logs:     slices.Clone(c.state.Logs),
+	}
 }
 
+// clearLogs drops the log entries received so far, the server sends its
+// recent ones again whenever following starts.
+func (c *Client) clearLogs() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.state.Logs = []*logs.Entry{}
+}
+
+// Origin returns the tag put on everything this client receives.
+func (c *Client) Origin() string {
+	return c.origin
+}
+
+// SendMessage writes msg to the server, or queues it while the connection is
+// down so it can be flushed once the session has been resumed.
 func (c *Client) SendMessage(msg *messages.Message) error {
-	return c.conn.WriteJSON(msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if msg.Sender == "" {
+		msg.Sender = c.cid
+	}
+	if msg.Type == messages.TypeFollowLogs {
+		c.following, _ = msg.Payload.(bool)
+		c.clearLogs()
+	}
+
+	if !c.connected {
+		if len(c.outbox) >= MAX_OUTBOX_SIZE {
+			return fmt.Errorf("outbox full, dropping %s message", msg.Type)
+		}
+		c.outbox = append(c.outbox, msg)
+		return nil
+	}
+
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.connected = false
+		c.outbox = append(c.outbox, msg)
+		slog.Error("write failed, message queued", "type", msg.Type, "error", err)
+	}
+	return nil
 }
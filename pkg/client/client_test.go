@@ -0,0 +1,29 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectUntilStopped(t *testing.T) {
+	cl := NewClient(unreachable, ClientOptions{})
+	done := make(chan error, 1)
+	go func() { done <- cl.reconnect() }()
+
+	// it keeps trying while the server is unreachable
+	select {
+	case err := <-done:
+		t.Fatalf("reconnect gave up before the client was stopped: %v", err)
+	case <-time.After(INITIAL_RECONNECT_DELAY * 3 / 2):
+	}
+
+	cl.Stop()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("reconnect to an unreachable server succeeded")
+		}
+	case <-time.After(time.Second):
+		t.Error("reconnect kept waiting after Stop")
+	}
+}
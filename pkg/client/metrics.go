@@ -0,0 +1,116 @@
+package client
+
+import (
+	"p1/pkg/models"
+	"time"
+)
+
+// metricsSample mirrors the raw counters the server sends with every METRICS
+// message. Percentages and rates are derived from two consecutive samples.
+type metricsSample struct {
+	CPU *struct {
+		cpuTimes
+		Cores []cpuTimes `json:"cores"`
+	} `json:"cpu"`
+	Memory *struct {
+		MemTotal uint64 `json:"mem_total"`
+		MemFree  uint64 `json:"mem_free"`
+	} `json:"memory"`
+	Storage *struct {
+		Disks []struct {
+			Total uint64 `json:"total"`
+			Used  uint64 `json:"used"`
+		} `json:"disks"`
+	} `json:"storage"`
+	Network    *networkCounters  `json:"network"`
+	Interfaces []networkCounters `json:"interfaces"`
+	// At is when the server took the sample. Rates are timed by it, samples
+	// replayed after a reconnect arrive back to back.
+	At time.Time `json:"at"`
+}
+
+type cpuTimes struct {
+	Idle  uint64 `json:"idle"`
+	Total uint64 `json:"total"`
+}
+
+// usage returns the share of non-idle time since prev in percent.
+func (c cpuTimes) usage(prev cpuTimes) float64 {
+	if c.Total <= prev.Total || c.Idle < prev.Idle {
+		return 0
+	}
+	total := float64(c.Total - prev.Total)
+	idle := float64(c.Idle - prev.Idle)
+	return 100 * (total - idle) / total
+}
+
+type networkCounters struct {
+	ID      string `json:"id"`
+	RxBytes uint64 `json:"rx_bytes"`
+	TxBytes uint64 `json:"tx_bytes"`
+}
+
+// rate returns the receive and transmit rates since prev in KiB/s.
+func (n networkCounters) rate(prev networkCounters, elapsed float64) (float64, float64) {
+	if elapsed <= 0 || n.RxBytes < prev.RxBytes || n.TxBytes < prev.TxBytes {
+		return 0, 0
+	}
+	return float64(n.RxBytes-prev.RxBytes) / 1024 / elapsed,
+		float64(n.TxBytes-prev.TxBytes) / 1024 / elapsed
+}
+
+// toMetrics converts the sample into percentages, using prev (which may be
+// nil) for the values that need a delta.
+func (s *metricsSample) toMetrics(prev *metricsSample, origin string) *models.Metrics {
+	m := &models.Metrics{
+		Cores:      []float64{},
+		Interfaces: []models.InterfaceRate{},
+		Origin:     origin,
+	}
+
+	if s.CPU != nil && prev != nil && prev.CPU != nil {
+		m.CPU = s.CPU.usage(prev.CPU.cpuTimes)
+		for i, core := range s.CPU.Cores {
+			usage := 0.0
+			if i < len(prev.CPU.Cores) {
+				usage = core.usage(prev.CPU.Cores[i])
+			}
+			m.Cores = append(m.Cores, usage)
+		}
+	}
+
+	if s.Memory != nil && s.Memory.MemTotal > 0 {
+		used := s.Memory.MemTotal - s.Memory.MemFree
+		m.RAM = 100 * float64(used) / float64(s.Memory.MemTotal)
+	}
+
+	if s.Storage != nil {
+		var total, used uint64
+		for _, disk := range s.Storage.Disks {
+			total += disk.Total
+			used += disk.Used
+		}
+		if total > 0 {
+			m.Disk = 100 * float64(used) / float64(total)
+		}
+	}
+
+	if prev != nil {
+		elapsed := s.At.Sub(prev.At).Seconds()
+		if s.Network != nil && prev.Network != nil {
+			rx, tx := s.Network.rate(*prev.Network, elapsed)
+			m.Network = rx + tx
+		}
+		for _, iface := range s.Interfaces {
+			for _, before := range prev.Interfaces {
+				if before.ID == iface.ID {
+					rx, tx := iface.rate(before, elapsed)
+					m.Interfaces = append(m.Interfaces, models.InterfaceRate{ID: iface.ID, Rx: rx, Tx: tx})
+					break
+				}
+			}
+		}
+	}
+
+	return m
+}
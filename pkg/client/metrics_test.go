@@ -0,0 +1,69 @@
+package client
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func networkSample(at time.Time, rx, tx uint64) *metricsSample {
+	return &metricsSample{
+		Network:    &networkCounters{RxBytes: rx, TxBytes: tx},
+		Interfaces: []networkCounters{{ID: "eth0", RxBytes: rx, TxBytes: tx}},
+		At:         at,
+	}
+}
+
+func TestToMetricsNetworkRate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		prev *metricsSample
+		cur  *metricsSample
+		want float64 // KiB/s, received and transmitted
+	}{
+		{name: "no previous sample", cur: networkSample(start, 4096, 0), want: 0},
+		{name: "timed by the server", prev: networkSample(start, 0, 0), cur: networkSample(start.Add(2*time.Second), 4096, 2048), want: 3},
+		{name: "same time", prev: networkSample(start, 0, 0), cur: networkSample(start, 4096, 0), want: 0},
+		{name: "counters reset", prev: networkSample(start, 4096, 0), cur: networkSample(start.Add(time.Second), 1024, 0), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.cur.toMetrics(tt.prev, "origin")
+			if math.Abs(m.Network-tt.want) > 1e-9 {
+				t.Errorf("network = %v KiB/s, want %v", m.Network, tt.want)
+			}
+			if tt.prev != nil && len(m.Interfaces) != 1 {
+				t.Fatalf("got %d interface rates, want 1", len(m.Interfaces))
+			}
+		})
+	}
+}
+
+func TestToMetricsUsage(t *testing.T) {
+	prev := &metricsSample{}
+	prev.CPU = &struct {
+		cpuTimes
+		Cores []cpuTimes `json:"cores"`
+	}{cpuTimes: cpuTimes{Idle: 100, Total: 200}, Cores: []cpuTimes{{Idle: 50, Total: 100}}}
+	cur := &metricsSample{}
+	cur.CPU = &struct {
+		cpuTimes
+		Cores []cpuTimes `json:"cores"`
+	}{cpuTimes: cpuTimes{Idle: 150, Total: 400}, Cores: []cpuTimes{{Idle: 50, Total: 200}}}
+	cur.Memory = &struct {
+		MemTotal uint64 `json:"mem_total"`
+		MemFree  uint64 `json:"mem_free"`
+	}{MemTotal: 1000, MemFree: 250}
+
+	m := cur.toMetrics(prev, "origin")
+	if m.CPU != 75 {
+		t.Errorf("cpu = %v, want 75", m.CPU)
+	}
+	if len(m.Cores) != 1 || m.Cores[0] != 100 {
+		t.Errorf("cores = %v, want [100]", m.Cores)
+	}
+	if m.RAM != 75 {
+		t.Errorf("ram = %v, want 75", m.RAM)
+	}
+}
@@ -0,0 +1,302 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"p1/pkg/logs"
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+	"slices"
+	"sync"
+)
+
+// Pool keeps one Client per p1 server and merges their states into a single
+// view. Everything in the merged state is tagged with the ID of the server
+// it came from.
+type Pool struct {
+	mu      sync.RWMutex
+	servers []*models.Server   // Servers in the order they were added
+	clients map[string]*Client // Clients by server ID
+	options ClientOptions
+	changes chan struct{}
+	events  chan models.Notification
+	active  string // Server the merged state is limited to, empty for all
+}
+
+// MAX_PENDING_NOTIFICATIONS is how many notifications wait for the reader
+// before new ones are dropped.
+const MAX_PENDING_NOTIFICATIONS = 64
+
+func NewPool(options ClientOptions) *Pool {
+	return &Pool{
+		servers: []*models.Server{},
+		clients: make(map[string]*Client),
+		options: options,
+		changes: make(chan struct{}, 1),
+		events:  make(chan models.Notification, MAX_PENDING_NOTIFICATIONS),
+	}
+}
+
+// Add connects to server and starts merging its state. A server that
+// cannot be reached is kept in the pool as offline, so it is listed with
+// the others, and is dialed again in the background with the backoff of a
+// reconnect until it answers or is removed.
+func (p *Pool) Add(server *models.Server) error {
+	options := p.options
+	options.Origin = server.ID
+	options.OnChange = p.notify
+	options.OnNotify = p.publish
+	cl := NewClient(server.URL, options)
+
+	p.mu.Lock()
+	if _, ok := p.clients[server.ID]; ok {
+		p.mu.Unlock()
+		return fmt.Errorf("server %s is already connected", server.Name)
+	}
+	p.servers = append(p.servers, server)
+	p.clients[server.ID] = cl
+	p.mu.Unlock()
+	defer p.notify()
+
+	if err := cl.Init(); err != nil {
+		slog.Warn("added offline server to pool", "server", server.Name, "url", server.URL, "error", err)
+		go p.retry(server, cl)
+		return fmt.Errorf("failed to connect to %s: %w", server.Name, err)
+	}
+	if cl.isStopped() {
+		// removed while connecting
+		cl.Stop()
+		return fmt.Errorf("server %s was removed while connecting", server.Name)
+	}
+	if err := cl.Start(); err != nil {
+		return fmt.Errorf("failed to start client for %s: %w", server.Name, err)
+	}
+	slog.Info("added server to pool", "server", server.Name, "url", server.URL)
+	return nil
+}
+
+// retry connects the offline client of server once the server answers.
+func (p *Pool) retry(server *models.Server, cl *Client) {
+	if err := cl.reconnect(); err != nil {
+		// removed from the pool
+		return
+	}
+	if err := cl.Start(); err != nil {
+		slog.Error("failed to start client", "server", server.Name, "error", err)
+		return
+	}
+	slog.Info("offline server is reachable again", "server", server.Name, "url", server.URL)
+	p.notify()
+}
+
+// Remove disconnects from the server with id and drops its entries from the merged state.
+func (p *Pool) Remove(id string) error {
+	p.mu.Lock()
+	cl, ok := p.clients[id]
+	if !ok {
+		p.mu.Unlock()
+		return fmt.Errorf("unknown server %s", id)
+	}
+	delete(p.clients, id)
+	if p.active == id {
+		p.active = ""
+	}
+	p.servers = slices.DeleteFunc(p.servers, func(s *models.Server) bool {
+		return s.ID == id
+	})
+	p.mu.Unlock()
+
+	p.notify()
+	return stopClient(cl)
+}
+
+// Client returns the client connected to the server with id.
+func (p *Pool) Client(id string) *Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.clients[id]
+}
+
+// Servers returns the servers the pool is connected to.
+func (p *Pool) Servers() []*models.Server {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return slices.Clone(p.servers)
+}
+
+// SetActive limits the merged state to the server with id. An empty id
+// shows every server again.
+func (p *Pool) SetActive(id string) error {
+	p.mu.Lock()
+	if _, ok := p.clients[id]; id != "" && !ok {
+		p.mu.Unlock()
+		return fmt.Errorf("unknown server %s", id)
+	}
+	p.active = id
+	p.mu.Unlock()
+
+	p.notify()
+	return nil
+}
+
+// Active returns the server the merged state is limited to, or an empty string.
+func (p *Pool) Active() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.active
+}
+
+// Pull merges the state of every connected server, or only of the active
+// one if SetActive was called. Servers always lists every server.
+func (p *Pool) Pull() *states.ClientState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	merged := &states.ClientState{
+		Projects: []*models.Project{},
+		Servers:  slices.Clone(p.servers),
+		Brokers:  []*models.Broker{},
+		Services: []*models.Service{},
+		Metrics:  map[string]*models.Metrics{},
+		Clients:  []*models.Participant{},
+		Chat:     []*models.ChatMessage{},
+		Logs:     []*logs.Entry{},
+		Active:   p.active,
+	}
+	seen := map[string]bool{}
+	for _, server := range p.servers {
+		if p.active != "" && server.ID != p.active {
+			continue
+		}
+		state := p.clients[server.ID].Pull()
+		merged.Projects = append(merged.Projects, state.Projects...)
+		merged.Brokers = append(merged.Brokers, state.Brokers...)
+		merged.Services = append(merged.Services, state.Services...)
+		for origin, m := range state.Metrics {
+			merged.Metrics[origin] = m
+		}
+		merged.Clients = append(merged.Clients, state.Clients...)
+		merged.Logs = append(merged.Logs, state.Logs...)
+		// broadcasts sent to every server arrive once through each of them
+		for _, message := range state.Chat {
+			if !seen[message.ID] {
+				seen[message.ID] = true
+				merged.Chat = append(merged.Chat, message)
+			}
+		}
+	}
+	slices.SortStableFunc(merged.Chat, func(a, b *models.ChatMessage) int {
+		return a.At.Compare(b.At)
+	})
+	slices.SortStableFunc(merged.Logs, func(a, b *logs.Entry) int {
+		return a.Time.Compare(b.Time)
+	})
+	return merged
+}
+
+// Stats summarizes the connection health of all servers. The pool counts as
+// connected while at least one server is reachable, and reports the worst
+// latency among them.
+func (p *Pool) Stats() models.ConnectionStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := models.ConnectionStatus{}
+	for _, cl := range p.clients {
+		stats := cl.Stats()
+		status.Connected = status.Connected || stats.Connected
+		status.Reconnects += stats.Reconnects
+		if stats.Connected && stats.Latency > status.Latency {
+			status.Latency = stats.Latency
+		}
+		if stats.LastMessage.After(status.LastMessage) {
+			status.LastMessage = stats.LastMessage
+		}
+	}
+	return status
+}
+
+// ServerStats returns the connection health of each server by server ID.
+func (p *Pool) ServerStats() map[string]models.ConnectionStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make(map[string]models.ConnectionStatus, len(p.clients))
+	for id, cl := range p.clients {
+		stats[id] = cl.Stats()
+	}
+	return stats
+}
+
+// SendMessage sends msg to the server with id, or to every server if id is empty.
+func (p *Pool) SendMessage(id string, msg *messages.Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if id != "" {
+		cl, ok := p.clients[id]
+		if !ok {
+			return fmt.Errorf("unknown server %s", id)
+		}
+		return cl.SendMessage(msg)
+	}
+
+	var errs []error
+	for _, cl := range p.clients {
+		copied := *msg
+		errs = append(errs, cl.SendMessage(&copied))
+	}
+	return errors.Join(errs...)
+}
+
+// Stop disconnects from every server.
+func (p *Pool) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for _, cl := range p.clients {
+		errs = append(errs, stopClient(cl))
+	}
+	return errors.Join(errs...)
+}
+
+// stopClient stops cl. Offline clients have no connection to close, which
+// is not an error.
+func stopClient(cl *Client) error {
+	offline := cl.currentConn() == nil
+	err := cl.Stop()
+	if offline {
+		return nil
+	}
+	return err
+}
+
+// Changes delivers a signal whenever the merged state may have changed.
+// Signals are coalesced, so a slow reader only sees the latest change.
+func (p *Pool) Changes() <-chan struct{} {
+	return p.changes
+}
+
+func (p *Pool) notify() {
+	select {
+	case p.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Notifications delivers the server events the clients report, like
+// services going down or broadcasts.
+func (p *Pool) Notifications() <-chan models.Notification {
+	return p.events
+}
+
+func (p *Pool) publish(notification models.Notification) {
+	select {
+	case p.events <- notification:
+	default:
+		slog.Warn("dropped notification", "message", notification.Message)
+	}
+}
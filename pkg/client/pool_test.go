@@ -0,0 +1,108 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"p1/pkg/messages"
+	"p1/pkg/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// unreachable is a link nothing listens on.
+const unreachable = "ws://127.0.0.1:1/ws"
+
+func TestPoolKeepsOfflineServers(t *testing.T) {
+	pool := NewPool(ClientOptions{})
+	server := &models.Server{ID: "offline", Name: "offline", URL: unreachable}
+	if err := pool.Add(server); err == nil {
+		t.Fatal("adding an unreachable server did not fail")
+	}
+
+	servers := pool.Servers()
+	if len(servers) != 1 || servers[0].ID != server.ID {
+		t.Fatalf("servers = %v, want the offline server", servers)
+	}
+	if stats := pool.ServerStats()[server.ID]; stats.Connected {
+		t.Error("offline server is reported as connected")
+	}
+	if pool.Stats().Connected {
+		t.Error("pool without a reachable server is reported as connected")
+	}
+	if err := pool.Remove(server.ID); err != nil {
+		t.Errorf("removing the offline server: %v", err)
+	}
+	if len(pool.Servers()) != 0 {
+		t.Error("offline server was not removed")
+	}
+}
+
+func TestPoolAddsServerOnce(t *testing.T) {
+	pool := NewPool(ClientOptions{})
+	defer pool.Stop()
+	server := &models.Server{ID: "s", Name: "s", URL: unreachable}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Add(server)
+		}()
+	}
+	wg.Wait()
+
+	if n := len(pool.Servers()); n != 1 {
+		t.Errorf("server was added %d times, want once", n)
+	}
+}
+
+func TestPoolRetriesOfflineServers(t *testing.T) {
+	// reserve a port, the server only starts listening on it later
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	pool := NewPool(ClientOptions{})
+	defer pool.Stop()
+	server := &models.Server{ID: "late", Name: "late", URL: "ws://" + address + "/ws"}
+	if err := pool.Add(server); err == nil {
+		t.Fatal("adding a server that is not listening yet did not fail")
+	}
+
+	upgrader := websocket.Upgrader{}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(messages.Message{Type: messages.TypeSession, Payload: messages.SessionInfo{SessionID: "s", Token: "t"}})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})}
+	ln, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	deadline := time.Now().Add(3 * INITIAL_RECONNECT_DELAY)
+	for !pool.ServerStats()[server.ID].Connected {
+		if time.Now().After(deadline) {
+			t.Fatal("offline server was not connected once it listened")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
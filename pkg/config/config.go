@@ -3,55 +3,350 @@ package config
 import (
 	"flag"
 	"os"
+	"os/user"
+	"p1/pkg/discovery"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	WithTui    bool
-	WithServer bool
-	ServerPort string
+	WithTui      bool
+	WithServer   bool
+	ServerHost   string
+	ServerPort   string
+	Connect      string // Address of a running server the TUI attaches to
+	DataDir      string // Where a running server announces itself and holds its lock
+	ConfigDir    string // Where user settings such as saved servers are kept
+	Peers        string // Comma separated addresses of servers to federate with
+	Theme        string // Name of a built-in theme or of a file in the themes directory
+	WithMouse    bool   // Whether the TUI reacts to the mouse
+	Name         string // Shown to other clients in the broadcast screen
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	AuthToken    string   // Presented to the servers the client connects to
+	AuthTokens   []string // Tokens the server accepts from clients, none to accept every client
+
+	ConfigFile      string              // Config file read before the environment, it may not exist
+	MetricsInterval time.Duration       // How often the server sends metrics to its clients
+	HealthInterval  time.Duration       // How often the server checks the registered services
+	HealthTimeout   time.Duration       // How long a health check waits for a service to answer
+	HealthChecks    map[string]string   // Addresses probed instead of the endpoint, by service name
+	KeymapPreset    string              // Keymap preset, keymap.json may override it
+	KeyBindings     map[string][]string // Keys by binding ID, keymap.json may override them
+
+	LogLevel      string        // Default level and per-package ones, e.g. "info,client=debug"
+	LogFormat     string        // text or json
+	LogOutput     string        // One of the LOG_OUTPUT_* destinations
+	LogFile       string        // Path of the log file
+	LogMaxSize    int           // Megabytes a log file may grow to before it is rotated, 0 for no limit
+	LogMaxAge     time.Duration // How long a log file is written to before it is rotated, 0 for no limit
+	LogMaxBackups int           // Rotated log files kept
 }
 
+const (
+	LOG_OUTPUT_AUTO   = "auto"   // The file while the TUI runs, stderr without it
+	LOG_OUTPUT_FILE   = "file"   // The log file only
+	LOG_OUTPUT_STDERR = "stderr" // Stderr only
+	LOG_OUTPUT_BOTH   = "both"   // The log file and stderr
+)
+
+// Keepalive defaults, the client falls back to them when created without a
+// configuration.
+const (
+	DEFAULT_PING_INTERVAL = 15 * time.Second // Time between keepalive pings
+	DEFAULT_PONG_TIMEOUT  = 10 * time.Second // Time to wait for a pong before reconnecting
+)
+
 const ENV_TUI = "TUI"
 const ENV_SERVER = "SERVER"
+const ENV_HOST = "HOST"
 const ENV_PORT = "PORT"
+const ENV_CONNECT = "CONNECT"
+const ENV_DATA_DIR = "DATA_DIR"
+const ENV_CONFIG_DIR = "CONFIG_DIR"
+const ENV_PEERS = "PEERS"
+const ENV_THEME = "THEME"
+const ENV_MOUSE = "MOUSE"
+const ENV_CLIENT_NAME = "CLIENT_NAME"
+const ENV_PING_INTERVAL = "PING_INTERVAL"
+const ENV_PONG_TIMEOUT = "PONG_TIMEOUT"
+const ENV_AUTH_TOKEN = "AUTH_TOKEN"
+const ENV_AUTH_TOKENS = "AUTH_TOKENS"
+const ENV_CONFIG_FILE = "CONFIG_FILE"
+const ENV_METRICS_INTERVAL = "METRICS_INTERVAL"
+const ENV_HEALTH_INTERVAL = "HEALTH_INTERVAL"
+const ENV_HEALTH_TIMEOUT = "HEALTH_TIMEOUT"
+const ENV_LOG_LEVEL = "LOG_LEVEL"
+const ENV_LOG_FORMAT = "LOG_FORMAT"
+const ENV_LOG_OUTPUT = "LOG_OUTPUT"
+const ENV_LOG_FILE = "LOG_FILE"
+const ENV_LOG_MAX_SIZE = "LOG_MAX_SIZE"
+const ENV_LOG_MAX_AGE = "LOG_MAX_AGE"
+const ENV_LOG_MAX_BACKUPS = "LOG_MAX_BACKUPS"
 
 const FLAG_NO_TUI = "no-tui"
 const FLAG_NO_SERVER = "no-server"
+const FLAG_HOST = "host"
 const FLAG_PORT = "port"
+const FLAG_CONNECT = "connect"
+const FLAG_DATA_DIR = "data-dir"
+const FLAG_CONFIG_DIR = "config-dir"
+const FLAG_PEERS = "peers"
+const FLAG_THEME = "theme"
+const FLAG_NO_MOUSE = "no-mouse"
+const FLAG_NAME = "name"
+const FLAG_PING_INTERVAL = "ping-interval"
+const FLAG_PONG_TIMEOUT = "pong-timeout"
+const FLAG_CONFIG = "config"
+const FLAG_METRICS_INTERVAL = "metrics-interval"
+const FLAG_HEALTH_INTERVAL = "health-interval"
+const FLAG_HEALTH_TIMEOUT = "health-timeout"
+const FLAG_LOG_LEVEL = "log-level"
+const FLAG_LOG_FORMAT = "log-format"
+const FLAG_LOG_OUTPUT = "log-output"
+const FLAG_LOG_FILE = "log-file"
+const FLAG_LOG_MAX_SIZE = "log-max-size"
+const FLAG_LOG_MAX_AGE = "log-max-age"
+const FLAG_LOG_MAX_BACKUPS = "log-max-backups"
+
+// New builds the configuration from the defaults, the config file, the
+// environment and the command line flags, each overriding the ones before.
+func New() (*Config, error) {
+	return Load(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:])
+}
 
-func New() *Config {
+// Load builds the configuration like New, parsing args with flags. The
+// configuration flags are added to flags, which may already hold those of a
+// subcommand. It can be called again with a new flag set to pick up changes
+// to the config file and the environment.
+func Load(flags *flag.FlagSet, args []string) (*Config, error) {
 	cfg := &Config{
-		WithTui:    true,
-		WithServer: true,
-		ServerPort: "0",
+		WithTui:      true,
+		WithServer:   true,
+		ServerHost:   "localhost",
+		ServerPort:   "0",
+		DataDir:      discovery.StateDir(),
+		ConfigDir:    ConfigDir(),
+		Theme:        "default",
+		WithMouse:    true,
+		Name:         defaultName(),
+		PingInterval: DEFAULT_PING_INTERVAL,
+		PongTimeout:  DEFAULT_PONG_TIMEOUT,
+
+		MetricsInterval: 5 * time.Second,
+		HealthInterval:  10 * time.Second,
+		HealthTimeout:   3 * time.Second,
+		HealthChecks:    map[string]string{},
+		KeyBindings:     map[string][]string{},
+
+		LogLevel:      "info",
+		LogFormat:     "text",
+		LogOutput:     LOG_OUTPUT_AUTO,
+		LogFile:       filepath.Join(discovery.StateDir(), "p1.log"),
+		LogMaxSize:    10,
+		LogMaxBackups: 5,
 	}
 
-	// Environment variables take precedence over defaults
+	// The config file takes precedence over defaults
+	path, explicit := configFile(args)
+	cfg.ConfigFile = path
+	if err := cfg.loadFile(path, explicit); err != nil {
+		return nil, err
+	}
+
+	// Environment variables take precedence over the config file
 	if v := os.Getenv(ENV_TUI); v != "" {
 		cfg.WithTui = parseBool(v)
 	}
 	if v := os.Getenv(ENV_SERVER); v != "" {
 		cfg.WithServer = parseBool(v)
 	}
+	if v := os.Getenv(ENV_HOST); v != "" {
+		cfg.ServerHost = v
+	}
 	if v := os.Getenv(ENV_PORT); v != "" {
 		cfg.ServerPort = v
 	}
+	if v := os.Getenv(ENV_CONNECT); v != "" {
+		cfg.Connect = v
+	}
+	if v := os.Getenv(ENV_DATA_DIR); v != "" {
+		cfg.DataDir = v
+	}
+	if v := os.Getenv(ENV_CONFIG_DIR); v != "" {
+		cfg.ConfigDir = v
+	}
+	if v := os.Getenv(ENV_PEERS); v != "" {
+		cfg.Peers = v
+	}
+	if v := os.Getenv(ENV_THEME); v != "" {
+		cfg.Theme = v
+	}
+	if v := os.Getenv(ENV_MOUSE); v != "" {
+		cfg.WithMouse = parseBool(v)
+	}
+	if v := os.Getenv(ENV_CLIENT_NAME); v != "" {
+		cfg.Name = v
+	}
+	if v := os.Getenv(ENV_PING_INTERVAL); v != "" {
+		cfg.PingInterval = parseDuration(v, cfg.PingInterval)
+	}
+	if v := os.Getenv(ENV_PONG_TIMEOUT); v != "" {
+		cfg.PongTimeout = parseDuration(v, cfg.PongTimeout)
+	}
+	if v := os.Getenv(ENV_AUTH_TOKEN); v != "" {
+		cfg.AuthToken = v
+	}
+	if v := os.Getenv(ENV_AUTH_TOKENS); v != "" {
+		cfg.AuthTokens = strings.Split(v, ",")
+	}
+	if v := os.Getenv(ENV_METRICS_INTERVAL); v != "" {
+		cfg.MetricsInterval = parseDuration(v, cfg.MetricsInterval)
+	}
+	if v := os.Getenv(ENV_HEALTH_INTERVAL); v != "" {
+		cfg.HealthInterval = parseDuration(v, cfg.HealthInterval)
+	}
+	if v := os.Getenv(ENV_HEALTH_TIMEOUT); v != "" {
+		cfg.HealthTimeout = parseDuration(v, cfg.HealthTimeout)
+	}
+	if v := os.Getenv(ENV_LOG_LEVEL); v != "" {
+		cfg.LogLevel = v
+	}
+	if v := os.Getenv(ENV_LOG_FORMAT); v != "" {
+		cfg.LogFormat = v
+	}
+	if v := os.Getenv(ENV_LOG_OUTPUT); v != "" {
+		cfg.LogOutput = v
+	}
+	if v := os.Getenv(ENV_LOG_FILE); v != "" {
+		cfg.LogFile = v
+	}
+	if v := os.Getenv(ENV_LOG_MAX_SIZE); v != "" {
+		cfg.LogMaxSize = parseInt(v, cfg.LogMaxSize)
+	}
+	if v := os.Getenv(ENV_LOG_MAX_AGE); v != "" {
+		cfg.LogMaxAge = parseDuration(v, cfg.LogMaxAge)
+	}
+	if v := os.Getenv(ENV_LOG_MAX_BACKUPS); v != "" {
+		cfg.LogMaxBackups = parseInt(v, cfg.LogMaxBackups)
+	}
 
 	// Command line flags take precedence over environment variables
-	flag.BoolVar(&cfg.WithTui, FLAG_NO_TUI, !cfg.WithTui, "disable TUI")
-	flag.BoolVar(&cfg.WithServer, FLAG_NO_SERVER, !cfg.WithServer, "disable server")
-	flag.StringVar(&cfg.ServerPort, FLAG_PORT, cfg.ServerPort, "server port")
-	flag.Parse()
+	flags.BoolVar(&cfg.WithTui, FLAG_NO_TUI, !cfg.WithTui, "disable TUI")
+	flags.BoolVar(&cfg.WithServer, FLAG_NO_SERVER, !cfg.WithServer, "disable server")
+	flags.StringVar(&cfg.ServerHost, FLAG_HOST, cfg.ServerHost, "server host to listen on")
+	flags.StringVar(&cfg.ServerPort, FLAG_PORT, cfg.ServerPort, "server port")
+	flags.StringVar(&cfg.Connect, FLAG_CONNECT, cfg.Connect, "connect the TUI to a running server at this address")
+	flags.StringVar(&cfg.DataDir, FLAG_DATA_DIR, cfg.DataDir, "data directory of the discovery file, pidfile and lock, one server runs per directory")
+	flags.StringVar(&cfg.ConfigDir, FLAG_CONFIG_DIR, cfg.ConfigDir, "directory for user settings such as saved servers")
+	flags.StringVar(&cfg.Peers, FLAG_PEERS, cfg.Peers, "comma separated addresses of peer servers to federate with")
+	flags.StringVar(&cfg.Theme, FLAG_THEME, cfg.Theme, "color theme, built-in or from the themes directory in the config directory")
+	flags.BoolVar(&cfg.WithMouse, FLAG_NO_MOUSE, !cfg.WithMouse, "disable mouse support in the TUI")
+	flags.StringVar(&cfg.Name, FLAG_NAME, cfg.Name, "name shown to other clients in the broadcast screen")
+	flags.DurationVar(&cfg.PingInterval, FLAG_PING_INTERVAL, cfg.PingInterval, "interval between keepalive pings")
+	flags.DurationVar(&cfg.PongTimeout, FLAG_PONG_TIMEOUT, cfg.PongTimeout, "time to wait for a pong before reconnecting")
+	flags.String(FLAG_CONFIG, path, "config file, read before the environment and the other flags")
+	flags.DurationVar(&cfg.MetricsInterval, FLAG_METRICS_INTERVAL, cfg.MetricsInterval, "interval between the metrics the server sends")
+	flags.DurationVar(&cfg.HealthInterval, FLAG_HEALTH_INTERVAL, cfg.HealthInterval, "interval between health checks of the registered services")
+	flags.DurationVar(&cfg.HealthTimeout, FLAG_HEALTH_TIMEOUT, cfg.HealthTimeout, "time a health check waits for a service to answer")
+	flags.StringVar(&cfg.LogLevel, FLAG_LOG_LEVEL, cfg.LogLevel, "log level, optionally followed by per-package levels, e.g. info,client=debug")
+	flags.StringVar(&cfg.LogFormat, FLAG_LOG_FORMAT, cfg.LogFormat, "log format, text or json")
+	flags.StringVar(&cfg.LogOutput, FLAG_LOG_OUTPUT, cfg.LogOutput, "where logs go: auto, file, stderr or both")
+	flags.StringVar(&cfg.LogFile, FLAG_LOG_FILE, cfg.LogFile, "path of the log file")
+	flags.IntVar(&cfg.LogMaxSize, FLAG_LOG_MAX_SIZE, cfg.LogMaxSize, "megabytes a log file may grow to before it is rotated, 0 for no limit")
+	flags.DurationVar(&cfg.LogMaxAge, FLAG_LOG_MAX_AGE, cfg.LogMaxAge, "how long a log file is written to before it is rotated, 0 for no limit")
+	flags.IntVar(&cfg.LogMaxBackups, FLAG_LOG_MAX_BACKUPS, cfg.LogMaxBackups, "number of rotated log files kept")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
 
 	// Invert the "no-" flags
 	cfg.WithTui = !cfg.WithTui
 	cfg.WithServer = !cfg.WithServer
+	cfg.WithMouse = !cfg.WithMouse
 
-	return cfg
+	return cfg, nil
+}
+
+// configFile finds the config file to load: the one passed with --config,
+// then the one in the environment, then the default one. The file has to be
+// read before the flags are parsed, so args are searched by hand.
+func configFile(args []string) (string, bool) {
+	if v, ok := FlagValue(args, FLAG_CONFIG); ok {
+		return v, true
+	}
+	if v := os.Getenv(ENV_CONFIG_FILE); v != "" {
+		return v, true
+	}
+	return DefaultConfigFile(args), false
+}
+
+// FlagValue returns the value given to the flag called name in args, in any
+// of the forms the flag package accepts, and whether it was given. It finds
+// flags before, or without, parsing them.
+func FlagValue(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		key, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || key != name {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// ClientAuthToken is the token the client presents: its own, or else the
+// first one the server accepts, so that a client sharing the config file
+// with its server gets in.
+func (cfg *Config) ClientAuthToken() string {
+	if cfg.AuthToken == "" && len(cfg.AuthTokens) > 0 {
+		return cfg.AuthTokens[0]
+	}
+	return cfg.AuthToken
+}
+
+// defaultName is user@host, or whichever of the two is known.
+func defaultName() string {
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		if name == "" {
+			return host
+		}
+		name += "@" + host
+	}
+	return name
 }
 
 func parseBool(v string) bool {
 	b, _ := strconv.ParseBool(v)
 	return b
 }
+
+func parseInt(v string, fallback int) int {
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+	return i
+}
+
+func parseDuration(v string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlagValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		value string
+		found bool
+	}{
+		{name: "missing", args: []string{"--id", "x"}},
+		{name: "separate value", args: []string{"--output", "json"}, value: "json", found: true},
+		{name: "single dash", args: []string{"-output", "yaml"}, value: "yaml", found: true},
+		{name: "equals", args: []string{"--bogus", "--output=json"}, value: "json", found: true},
+		{name: "after other flags", args: []string{"--id", "x", "--output", "json", "name"}, value: "json", found: true},
+		{name: "after the terminator", args: []string{"--", "--output", "json"}},
+		{name: "no value", args: []string{"--output"}},
+		{name: "other flag", args: []string{"--outputs", "json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, found := FlagValue(tt.args, "output")
+			if value != tt.value || found != tt.found {
+				t.Errorf("FlagValue = %q, %v, want %q, %v", value, found, tt.value, tt.found)
+			}
+		})
+	}
+}
+
+func TestDefaultConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{name: "default", want: filepath.Join(home, "p1", CONFIG_FILE)},
+		{name: "environment", env: "/env", want: filepath.Join("/env", CONFIG_FILE)},
+		{name: "flag", args: []string{"--config-dir", "/flag"}, want: filepath.Join("/flag", CONFIG_FILE)},
+		{name: "flag over the environment", env: "/env", args: []string{"--port", "80", "-config-dir=/flag"}, want: filepath.Join("/flag", CONFIG_FILE)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ENV_CONFIG_DIR, tt.env)
+			if got := DefaultConfigFile(tt.args); got != tt.want {
+				t.Errorf("DefaultConfigFile = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ENV_CONFIG_DIR, "")
+	t.Setenv(ENV_CONFIG_FILE, "")
+	if err := os.WriteFile(filepath.Join(dir, CONFIG_FILE), []byte("[tui]\ntheme = \"nord\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--config-dir", dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConfigDir != dir || cfg.ConfigFile != filepath.Join(dir, CONFIG_FILE) {
+		t.Errorf("config dir %q and file %q, want both in %q", cfg.ConfigDir, cfg.ConfigFile, dir)
+	}
+	if cfg.Theme != "nord" {
+		t.Errorf("theme = %q, want the one of the config file in the config dir", cfg.Theme)
+	}
+}
+
+func TestReloadHealthChecksAndAuthTokens(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ENV_CONFIG_FILE, "")
+	t.Setenv(ENV_AUTH_TOKEN, "")
+	t.Setenv(ENV_AUTH_TOKENS, "")
+	path := filepath.Join(dir, CONFIG_FILE)
+	load := func(data string) *Config {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--config", path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	old := load("")
+	new := load(`[server]
+auth_tokens = ["secret"]
+
+[server.health_checks]
+"api" = "http://localhost:8080/healthz"
+
+[client]
+auth_token = "secret"
+`)
+	if want := map[string]string{"api": "http://localhost:8080/healthz"}; !reflect.DeepEqual(new.HealthChecks, want) {
+		t.Errorf("health checks = %v, want %v", new.HealthChecks, want)
+	}
+	live, restart := Changes(old, new)
+	if want := []string{HEALTH_CHECKS_TABLE, "server.auth_tokens"}; !reflect.DeepEqual(live, want) {
+		t.Errorf("live = %v, want %v", live, want)
+	}
+	if want := []string{"client.auth_token"}; !reflect.DeepEqual(restart, want) {
+		t.Errorf("restart = %v, want %v", restart, want)
+	}
+}
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"p1/pkg/logs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CONFIG_FILE = "config.toml"
+
+// Problem is something wrong with the config file.
+type Problem struct {
+	File    string
+	Line    int    // 0 if the problem is not tied to a line
+	Key     string // Empty for syntax errors
+	Message string
+}
+
+func (p *Problem) Error() string {
+	location := p.File
+	if p.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, p.Line)
+	}
+	if p.Key != "" {
+		return fmt.Sprintf("%s: %s: %s", location, p.Key, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, p.Message)
+}
+
+type settingKind string
+
+const (
+	kindString   settingKind = "a string"
+	kindBool     settingKind = "a boolean"
+	kindInt      settingKind = "an integer"
+	kindDuration settingKind = "a duration like \"15s\""
+	kindStrings  settingKind = "an array of strings"
+	kindPort     settingKind = "a port number"
+)
+
+// setting is a key of the config file and the Config field it sets.
+type setting struct {
+	kind  settingKind
+	check func(value any) error // Optional check beyond the type
+	apply func(cfg *Config, value any)
+}
+
+// settings are the keys the config file knows, e.g.
+//
+//	[server]
+//	port = 28080
+//	peers = ["ws://10.0.0.2:28080/ws"]
+//
+//	[log]
+//	level = "info,client=debug"
+var settings = map[string]setting{
+	"server.enabled":          {kind: kindBool, apply: func(cfg *Config, v any) { cfg.WithServer = v.(bool) }},
+	"server.host":             {kind: kindString, apply: func(cfg *Config, v any) { cfg.ServerHost = v.(string) }},
+	"server.port":             {kind: kindPort, apply: func(cfg *Config, v any) { cfg.ServerPort = v.(string) }},
+	"server.data_dir":         {kind: kindString, apply: func(cfg *Config, v any) { cfg.DataDir = v.(string) }},
+	"server.peers":            {kind: kindStrings, apply: func(cfg *Config, v any) { cfg.Peers = strings.Join(v.([]string), ",") }},
+	"server.metrics_interval": {kind: kindDuration, check: positive, apply: func(cfg *Config, v any) { cfg.MetricsInterval = v.(time.Duration) }},
+	"server.health_interval":  {kind: kindDuration, check: positive, apply: func(cfg *Config, v any) { cfg.HealthInterval = v.(time.Duration) }},
+	"server.health_timeout":   {kind: kindDuration, check: positive, apply: func(cfg *Config, v any) { cfg.HealthTimeout = v.(time.Duration) }},
+	"server.auth_tokens":      {kind: kindStrings, apply: func(cfg *Config, v any) { cfg.AuthTokens = v.([]string) }},
+
+	"client.connect":       {kind: kindStrings, apply: func(cfg *Config, v any) { cfg.Connect = strings.Join(v.([]string), ",") }},
+	"client.name":          {kind: kindString, apply: func(cfg *Config, v any) { cfg.Name = v.(string) }},
+	"client.ping_interval": {kind: kindDuration, check: positive, apply: func(cfg *Config, v any) { cfg.PingInterval = v.(time.Duration) }},
+	"client.pong_timeout":  {kind: kindDuration, check: positive, apply: func(cfg *Config, v any) { cfg.PongTimeout = v.(time.Duration) }},
+	"client.auth_token":    {kind: kindString, apply: func(cfg *Config, v any) { cfg.AuthToken = v.(string) }},
+
+	"tui.enabled": {kind: kindBool, apply: func(cfg *Config, v any) { cfg.WithTui = v.(bool) }},
+	"tui.theme":   {kind: kindString, apply: func(cfg *Config, v any) { cfg.Theme = v.(string) }},
+	"tui.mouse":   {kind: kindBool, apply: func(cfg *Config, v any) { cfg.WithMouse = v.(bool) }},
+
+	"keymap.preset": {kind: kindString, apply: func(cfg *Config, v any) { cfg.KeymapPreset = v.(string) }},
+
+	"log.level":       {kind: kindString, check: validLogLevel, apply: func(cfg *Config, v any) { cfg.LogLevel = v.(string) }},
+	"log.format":      {kind: kindString, check: oneOf(logs.FORMAT_TEXT, logs.FORMAT_JSON), apply: func(cfg *Config, v any) { cfg.LogFormat = v.(string) }},
+	"log.output":      {kind: kindString, check: oneOf(LOG_OUTPUT_AUTO, LOG_OUTPUT_FILE, LOG_OUTPUT_STDERR, LOG_OUTPUT_BOTH), apply: func(cfg *Config, v any) { cfg.LogOutput = v.(string) }},
+	"log.file":        {kind: kindString, apply: func(cfg *Config, v any) { cfg.LogFile = v.(string) }},
+	"log.max_size":    {kind: kindInt, apply: func(cfg *Config, v any) { cfg.LogMaxSize = v.(int) }},
+	"log.max_age":     {kind: kindDuration, apply: func(cfg *Config, v any) { cfg.LogMaxAge = v.(time.Duration) }},
+	"log.max_backups": {kind: kindInt, apply: func(cfg *Config, v any) { cfg.LogMaxBackups = v.(int) }},
+}
+
+// KEY_BINDINGS_TABLE holds the keymap overrides, keyed by binding ID:
+//
+//	[keymap.bindings]
+//	"projects.new" = ["N"]
+const KEY_BINDINGS_TABLE = "keymap.bindings"
+
+// HEALTH_CHECKS_TABLE holds the addresses the server probes instead of the
+// endpoints of the services, keyed by service name:
+//
+//	[server.health_checks]
+//	"api" = "http://localhost:8080/healthz"
+const HEALTH_CHECKS_TABLE = "server.health_checks"
+
+// DefaultConfigFile is the config file in the config directory, which is
+// the one passed with --config-dir in args, then the one in the environment,
+// then the default one, like Load resolves it.
+func DefaultConfigFile(args []string) string {
+	dir := ConfigDir()
+	if v := os.Getenv(ENV_CONFIG_DIR); v != "" {
+		dir = v
+	}
+	if v, ok := FlagValue(args, FLAG_CONFIG_DIR); ok {
+		dir = v
+	}
+	return filepath.Join(dir, CONFIG_FILE)
+}
+
+// Validate checks the config file at path and returns everything wrong with
+// it: syntax errors, unknown keys and values of the wrong type. Only a file
+// that cannot be read is an error.
+func Validate(path string) ([]*Problem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	_, problems := parseFile(path, string(data))
+	return problems, nil
+}
+
+// loadFile applies the config file at path to cfg. A missing file is only
+// an error if it was asked for explicitly.
+func (cfg *Config) loadFile(path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values, problems := parseFile(path, string(data))
+	if len(problems) > 0 {
+		errs := make([]error, len(problems))
+		for i, problem := range problems {
+			errs[i] = problem
+		}
+		return errors.Join(errs...)
+	}
+	for _, value := range values {
+		if id, ok := strings.CutPrefix(value.key, KEY_BINDINGS_TABLE+"."); ok {
+			cfg.KeyBindings[id] = value.value.([]string)
+			continue
+		}
+		if name, ok := strings.CutPrefix(value.key, HEALTH_CHECKS_TABLE+"."); ok {
+			cfg.HealthChecks[name] = value.value.(string)
+			continue
+		}
+		settings[value.key].apply(cfg, value.value)
+	}
+	return nil
+}
+
+// parseFile parses data and converts the values to the types of their
+// settings. Values that are in the returned problems are left out.
+func parseFile(path string, data string) ([]tomlValue, []*Problem) {
+	values, err := parseTOML(data)
+	if problem, ok := err.(*Problem); ok {
+		problem.File = path
+		return nil, []*Problem{problem}
+	}
+
+	converted := []tomlValue{}
+	problems := []*Problem{}
+	for _, value := range values {
+		s, ok := settings[value.key]
+		if _, binding := strings.CutPrefix(value.key, KEY_BINDINGS_TABLE+"."); binding {
+			s, ok = setting{kind: kindStrings}, true
+		}
+		if _, check := strings.CutPrefix(value.key, HEALTH_CHECKS_TABLE+"."); check {
+			s, ok = setting{kind: kindString}, true
+		}
+		if !ok {
+			problems = append(problems, &Problem{File: path, Line: value.line, Key: value.key, Message: "unknown key" + suggest(value.key)})
+			continue
+		}
+
+		v, err := convert(s.kind, value.value)
+		if err == nil && s.check != nil {
+			err = s.check(v)
+		}
+		if err != nil {
+			problems = append(problems, &Problem{File: path, Line: value.line, Key: value.key, Message: err.Error()})
+			continue
+		}
+		value.value = v
+		converted = append(converted, value)
+	}
+	return converted, problems
+}
+
+// convert turns a parsed value into the Go type of kind.
+func convert(kind settingKind, value any) (any, error) {
+	mismatch := fmt.Errorf("expected %s, got %s", kind, describe(value))
+	switch kind {
+	case kindString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case kindBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case kindInt:
+		if i, ok := value.(int64); ok {
+			return int(i), nil
+		}
+	case kindDuration:
+		if s, ok := value.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, mismatch
+			}
+			return d, nil
+		}
+	case kindPort:
+		switch v := value.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case string:
+			if _, err := strconv.Atoi(v); err == nil {
+				return v, nil
+			}
+		}
+	case kindStrings:
+		items, ok := value.([]any)
+		if !ok {
+			break
+		}
+		strs := make([]string, 0, len(items))
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, mismatch
+			}
+			strs = append(strs, s)
+		}
+		return strs, nil
+	}
+	return nil, mismatch
+}
+
+func describe(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return "a boolean"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a number"
+	case []any:
+		return "an array"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// suggest names a known key that looks like a typo of key.
+func suggest(key string) string {
+	table, name, _ := strings.Cut(key, ".")
+	for _, known := range slices.Sorted(maps.Keys(settings)) {
+		knownTable, knownName, _ := strings.Cut(known, ".")
+		if knownName == name && knownTable != table || knownTable == table && strings.ReplaceAll(knownName, "_", "") == strings.ReplaceAll(strings.ReplaceAll(name, "-", ""), "_", "") {
+			return fmt.Sprintf(", did you mean %s?", known)
+		}
+	}
+	return ""
+}
+
+func positive(value any) error {
+	if value.(time.Duration) <= 0 {
+		return fmt.Errorf("must be greater than zero")
+	}
+	return nil
+}
+
+func validLogLevel(value any) error {
+	_, err := logs.ParseLevels(value.(string))
+	return err
+}
+
+func oneOf(allowed ...string) func(value any) error {
+	return func(value any) error {
+		if !slices.Contains(allowed, value.(string)) {
+			return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+		}
+		return nil
+	}
+}
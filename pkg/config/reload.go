@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+)
+
+const WATCH_INTERVAL = 2 * time.Second // Time between checks of the config file for changes
+
+// reloadable are the settings a reload compares, by their key in the config
+// file. Live ones are applied by the running process, the others only take
+// effect after a restart.
+var reloadable = []struct {
+	key   string
+	live  bool
+	value func(cfg *Config) any
+}{
+	{"server.enabled", false, func(cfg *Config) any { return cfg.WithServer }},
+	{"server.host", false, func(cfg *Config) any { return cfg.ServerHost }},
+	{"server.port", false, func(cfg *Config) any { return cfg.ServerPort }},
+	{"server.data_dir", false, func(cfg *Config) any { return cfg.DataDir }},
+	{"server.peers", false, func(cfg *Config) any { return cfg.Peers }},
+	{"server.metrics_interval", true, func(cfg *Config) any { return cfg.MetricsInterval }},
+	{"server.health_interval", true, func(cfg *Config) any { return cfg.HealthInterval }},
+	{"server.health_timeout", true, func(cfg *Config) any { return cfg.HealthTimeout }},
+	{HEALTH_CHECKS_TABLE, true, func(cfg *Config) any { return cfg.HealthChecks }},
+	{"server.auth_tokens", true, func(cfg *Config) any { return cfg.AuthTokens }},
+
+	{"client.connect", false, func(cfg *Config) any { return cfg.Connect }},
+	{"client.name", false, func(cfg *Config) any { return cfg.Name }},
+	{"client.ping_interval", false, func(cfg *Config) any { return cfg.PingInterval }},
+	{"client.pong_timeout", false, func(cfg *Config) any { return cfg.PongTimeout }},
+	{"client.auth_token", false, func(cfg *Config) any { return cfg.AuthToken }},
+
+	{"tui.enabled", false, func(cfg *Config) any { return cfg.WithTui }},
+	{"tui.theme", true, func(cfg *Config) any { return cfg.Theme }},
+	{"tui.mouse", true, func(cfg *Config) any { return cfg.WithMouse }},
+
+	{"keymap.preset", true, func(cfg *Config) any { return cfg.KeymapPreset }},
+	{KEY_BINDINGS_TABLE, true, func(cfg *Config) any { return cfg.KeyBindings }},
+
+	{"log.level", true, func(cfg *Config) any { return cfg.LogLevel }},
+	{"log.format", false, func(cfg *Config) any { return cfg.LogFormat }},
+	{"log.output", false, func(cfg *Config) any { return cfg.LogOutput }},
+	{"log.file", false, func(cfg *Config) any { return cfg.LogFile }},
+	{"log.max_size", false, func(cfg *Config) any { return cfg.LogMaxSize }},
+	{"log.max_age", false, func(cfg *Config) any { return cfg.LogMaxAge }},
+	{"log.max_backups", false, func(cfg *Config) any { return cfg.LogMaxBackups }},
+}
+
+// Changes compares two configurations and returns the keys of the settings
+// that differ, split into those that can be applied live and those that
+// need a restart.
+func Changes(old *Config, new *Config) (live []string, restart []string) {
+	for _, setting := range reloadable {
+		if reflect.DeepEqual(setting.value(old), setting.value(new)) {
+			continue
+		}
+		if setting.live {
+			live = append(live, setting.key)
+		} else {
+			restart = append(restart, setting.key)
+		}
+	}
+	return live, restart
+}
+
+// Watch checks the file at path every interval and signals on the returned
+// channel when it was created, changed or removed, until ctx is done.
+// Changes that come in faster than they are received are signalled once.
+func Watch(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := stat(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current := stat(path)
+			if current == last {
+				continue
+			}
+			last = current
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changed
+}
+
+// fileState is what Watch compares to notice a change.
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"p1/pkg/models"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const SERVERS_FILE = "servers.json"
+
+// ConfigDir returns the per-user settings directory, following the XDG base
+// directory spec through os.UserConfigDir.
+func ConfigDir() string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "p1")
+	}
+	return filepath.Join(".", ".p1")
+}
+
+// ServerStore keeps the server connections saved from the TUI in
+// SERVERS_FILE inside the config directory.
+type ServerStore struct {
+	mu      sync.Mutex
+	path    string
+	servers []*models.Server
+}
+
+// NewServerStore loads the saved servers from dir. A missing file is not an
+// error, the store simply starts empty.
+func NewServerStore(dir string) (*ServerStore, error) {
+	store := &ServerStore{
+		path:    filepath.Join(dir, SERVERS_FILE),
+		servers: []*models.Server{},
+	}
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return store, err
+	}
+	if err := json.Unmarshal(data, &store.servers); err != nil {
+		return store, fmt.Errorf("invalid %s: %w", store.path, err)
+	}
+	return store, nil
+}
+
+// Servers returns the saved servers in the order they were added.
+func (s *ServerStore) Servers() []*models.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.servers)
+}
+
+// Save adds server, or replaces the saved server with the same ID, and writes the file.
+func (s *ServerStore) Save(server *models.Server) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.servers, func(saved *models.Server) bool {
+		return saved.ID == server.ID
+	})
+	if i >= 0 {
+		s.servers[i] = server
+	} else {
+		s.servers = append(s.servers, server)
+	}
+	return s.write()
+}
+
+// Delete removes the saved server with id and writes the file.
+func (s *ServerStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.servers = slices.DeleteFunc(s.servers, func(saved *models.Server) bool {
+		return saved.ID == id
+	})
+	return s.write()
+}
+
+// write replaces the file atomically so a crash never leaves half a list behind.
+func (s *ServerStore) write() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.servers, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tomlValue is a value of the config file with the line it was set on.
+type tomlValue struct {
+	key   string // Full dotted key, including the table
+	value any    // string, int64, float64, bool or []any
+	line  int
+}
+
+// parseTOML reads the subset of TOML the config file needs: tables, dotted
+// and quoted keys, strings, numbers, booleans and arrays of those. Syntax
+// errors carry the line they were found on.
+func parseTOML(data string) ([]tomlValue, error) {
+	values := []tomlValue{}
+	table := ""
+	seen := map[string]int{}
+
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") {
+				return nil, lineError(number, "arrays of tables are not supported")
+			}
+			if !strings.HasSuffix(line, "]") {
+				return nil, lineError(number, "unterminated table header")
+			}
+			keys, rest, err := parseKey(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil || rest != "" {
+				return nil, lineError(number, "invalid table header %s", line)
+			}
+			table = strings.Join(keys, ".")
+			continue
+		}
+
+		keys, rest, err := parseKey(line)
+		if err != nil {
+			return nil, lineError(number, "%s", err)
+		}
+		rest, found := strings.CutPrefix(strings.TrimSpace(rest), "=")
+		if !found {
+			return nil, lineError(number, "expected = after %s", strings.Join(keys, "."))
+		}
+		rest = strings.TrimSpace(rest)
+
+		// arrays may continue on the following lines
+		for strings.HasPrefix(rest, "[") && !arrayClosed(rest) && i+1 < len(lines) {
+			i++
+			rest += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+
+		value, rest, err := parseValue(rest)
+		if err != nil {
+			return nil, lineError(number, "%s", err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, lineError(number, "unexpected %q after the value", strings.TrimSpace(rest))
+		}
+
+		key := strings.Join(keys, ".")
+		if table != "" {
+			key = table + "." + key
+		}
+		if first, ok := seen[key]; ok {
+			return nil, lineError(number, "%s is already set on line %d", key, first)
+		}
+		seen[key] = number
+		values = append(values, tomlValue{key: key, value: value, line: number})
+	}
+	return values, nil
+}
+
+func lineError(line int, format string, args ...any) error {
+	return &Problem{Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+// stripComment cuts off a comment, leaving # inside strings alone.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && c == '#':
+			return line[:i]
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		}
+	}
+	return line
+}
+
+// arrayClosed reports whether the brackets in s are balanced.
+func arrayClosed(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// parseKey reads a dotted key of bare and quoted parts from the start of s.
+func parseKey(s string) ([]string, string, error) {
+	keys := []string{}
+	for {
+		s = strings.TrimSpace(s)
+		var part string
+		switch {
+		case strings.HasPrefix(s, `"`), strings.HasPrefix(s, "'"):
+			value, rest, err := parseString(s)
+			if err != nil {
+				return nil, "", err
+			}
+			part, s = value, rest
+		default:
+			end := strings.IndexFunc(s, func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+			})
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, "", fmt.Errorf("expected a key")
+			}
+			part, s = s[:end], s[end:]
+		}
+		keys = append(keys, part)
+
+		rest, dotted := strings.CutPrefix(strings.TrimSpace(s), ".")
+		if !dotted {
+			return keys, s, nil
+		}
+		s = rest
+	}
+}
+
+// parseValue reads one value from the start of s and returns what follows it.
+func parseValue(s string) (any, string, error) {
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case strings.HasPrefix(s, `"`), strings.HasPrefix(s, "'"):
+		return parseString(s)
+	case strings.HasPrefix(s, "["):
+		return parseArray(s)
+	case strings.HasPrefix(s, "{"):
+		return nil, "", fmt.Errorf("inline tables are not supported")
+	}
+
+	end := strings.IndexAny(s, " \t,]")
+	if end < 0 {
+		end = len(s)
+	}
+	token, rest := s[:end], s[end:]
+	switch token {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	value, err := parseNumber(token)
+	if err != nil {
+		return nil, "", err
+	}
+	return value, rest, nil
+}
+
+var (
+	// decimal integers and floats once the underscores are removed
+	decimalNumber = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+	leadingZero   = regexp.MustCompile(`^[+-]?0[0-9]`)
+	prefixedBase  = regexp.MustCompile(`^[+-]?0[xob]`)
+)
+
+// parseNumber reads a decimal integer or float. Underscores may separate
+// digits. Hexadecimal, octal and binary integers are not supported.
+func parseNumber(token string) (any, error) {
+	number := strings.ReplaceAll(token, "_", "")
+	if !decimalNumber.MatchString(number) || !underscoresBetweenDigits(token) {
+		switch {
+		case leadingZero.MatchString(number):
+			return nil, fmt.Errorf("invalid number %q, leading zeros are not allowed", token)
+		case prefixedBase.MatchString(number):
+			return nil, fmt.Errorf("invalid number %q, only decimal numbers are supported", token)
+		}
+		return nil, fmt.Errorf("invalid value %q, strings need quotes", token)
+	}
+	if !strings.ContainsAny(number, ".eE") {
+		i, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("number %q is out of range", token)
+		}
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return nil, fmt.Errorf("number %q is out of range", token)
+	}
+	return f, nil
+}
+
+// underscoresBetweenDigits reports whether every underscore in token sits
+// between two digits, as TOML requires.
+func underscoresBetweenDigits(token string) bool {
+	for i := range len(token) {
+		if token[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(token)-1 || !isDigit(token[i-1]) || !isDigit(token[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// parseString reads a basic ("...") or literal ('...') string.
+func parseString(s string) (string, string, error) {
+	if strings.HasPrefix(s, "'") {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return value, s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+func parseArray(s string) ([]any, string, error) {
+	values := []any{}
+	s = strings.TrimSpace(s[1:])
+	for {
+		if rest, ok := strings.CutPrefix(s, "]"); ok {
+			return values, rest, nil
+		}
+		value, rest, err := parseValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, value)
+		s = strings.TrimSpace(rest)
+		if rest, ok := strings.CutPrefix(s, ","); ok {
+			s = strings.TrimSpace(rest)
+		} else if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
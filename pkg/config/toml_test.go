@@ -0,0 +1,110 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValueNumbers(t *testing.T) {
+	tests := []struct {
+		token string
+		want  any
+		err   string
+	}{
+		{token: "8080", want: int64(8080)},
+		{token: "0", want: int64(0)},
+		{token: "-0", want: int64(0)},
+		{token: "+42", want: int64(42)},
+		{token: "-17", want: int64(-17)},
+		{token: "1_000_000", want: int64(1000000)},
+		{token: "1.5", want: 1.5},
+		{token: "0.25", want: 0.25},
+		{token: "-2e3", want: -2000.0},
+		{token: "6.02E+23", want: 6.02e23},
+		{token: "010", err: "leading zeros are not allowed"},
+		{token: "08080", err: "leading zeros are not allowed"},
+		{token: "-007", err: "leading zeros are not allowed"},
+		{token: "01.5", err: "leading zeros are not allowed"},
+		{token: "0x10", err: "only decimal numbers are supported"},
+		{token: "0o17", err: "only decimal numbers are supported"},
+		{token: "0b101", err: "only decimal numbers are supported"},
+		{token: "_1", err: "strings need quotes"},
+		{token: "1_", err: "strings need quotes"},
+		{token: "1__0", err: "strings need quotes"},
+		{token: "1.", err: "strings need quotes"},
+		{token: ".5", err: "strings need quotes"},
+		{token: "inf", err: "strings need quotes"},
+		{token: "0x1p-2", err: "only decimal numbers are supported"},
+		{token: "localhost", err: "strings need quotes"},
+		{token: "9223372036854775808", err: "out of range"},
+		{token: "1e400", err: "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			got, rest, err := parseValue(tt.token)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || rest != "" {
+				t.Errorf("value = %#v rest %q, want %#v", got, rest, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []tomlValue
+		err  string
+	}{
+		{
+			name: "tables and keys",
+			data: "# settings\nlog_level = \"debug\" # inline\n\n[server]\nport = 8080\n\"peer.links\" = ['ws://a', \"ws://b\"]\n[client.ping]\ninterval = 1.5\nenabled = true\n",
+			want: []tomlValue{
+				{key: "log_level", value: "debug", line: 2},
+				{key: "server.port", value: int64(8080), line: 5},
+				{key: "server.peer.links", value: []any{"ws://a", "ws://b"}, line: 6},
+				{key: "client.ping.interval", value: 1.5, line: 8},
+				{key: "client.ping.enabled", value: true, line: 9},
+			},
+		},
+		{
+			name: "multiline array",
+			data: "ports = [\n  1,\n  2, # two\n]\n",
+			want: []tomlValue{{key: "ports", value: []any{int64(1), int64(2)}, line: 1}},
+		},
+		{name: "leading zero", data: "[server]\nport = 010\n", err: ":2: invalid number \"010\", leading zeros are not allowed"},
+		{name: "duplicate key", data: "a = 1\na = 2\n", err: ":2: a is already set on line 1"},
+		{name: "missing value", data: "a =\n", err: ":1: missing value"},
+		{name: "value after value", data: "a = 1 2\n", err: ":1: unexpected \"2\" after the value"},
+		{name: "unterminated string", data: "a = \"b\n", err: ":1: unterminated string"},
+		{name: "array of tables", data: "[[servers]]\n", err: ":1: arrays of tables are not supported"},
+		{name: "inline table", data: "a = {b = 1}\n", err: ":1: inline tables are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(tt.data)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
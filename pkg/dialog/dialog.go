@@ -1,8 +1,22 @@
 package dialog
 
 import (
+	"strings"
+
+	"p1/pkg/keymap"
+	"p1/pkg/tui/theme"
+
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	confirmKey = keymap.Register("dialog.confirm", keymap.SCOPE_DIALOG, "Confirm", "enter")
+	cancelKey  = keymap.Register("dialog.cancel", keymap.SCOPE_DIALOG, "Cancel", "esc")
+	yesKey     = keymap.Register("dialog.yes", keymap.SCOPE_DIALOG, "Select Yes", "right")
+	noKey      = keymap.Register("dialog.no", keymap.SCOPE_DIALOG, "Select Cancel", "left")
 )
 
 type DialogBody interface {
@@ -12,7 +26,16 @@ type DialogBody interface {
 	Focused() bool
 }
 
+// Submitter is implemented by bodies that decide themselves when they are
+// done, like forms that use enter to move between their fields. Dialogs with
+// such a body leave enter to the body and show no buttons.
+type Submitter interface {
+	Submitted() bool
+	Aborted() bool
+}
+
 type Dialog struct {
+	theme         theme.Theme
 	title         string
 	body          DialogBody
 	Value         any
@@ -23,22 +46,17 @@ type Dialog struct {
 	visible       bool
 }
 
-var (
-	dialogBoxStyle = lipgloss.NewStyle().MarginRight(1)
-	buttonStyle    = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFF7DB")).
-			Background(lipgloss.Color("#888B7E")).
-			Padding(0, 3)
-	activeButtonStyle = buttonStyle.
-				Foreground(lipgloss.Color("#FFF7DB")).
-				Background(lipgloss.Color("#F25D94")).
-				Underline(true)
-	subtle                = lipgloss.AdaptiveColor{Light: "#D9DCCF", Dark: "#383838"}
-	dialogBackgroundColor = lipgloss.AdaptiveColor{Light: "#DDDDDD", Dark: "#222222"}
-)
+const YES_BUTTON = "Yes"
+const CANCEL_BUTTON = "Cancel"
 
-func NewDialog(title string, body DialogBody) *Dialog {
+// buttonPadding is the space on either side of a button's label.
+const buttonPadding = 3
+
+var dialogBoxStyle = lipgloss.NewStyle().MarginRight(1)
+
+func NewDialog(t theme.Theme, title string, body DialogBody) *Dialog {
 	return &Dialog{
+		theme:         t,
 		width:         0,
 		height:        0,
 		title:         title,
@@ -52,6 +70,9 @@ func NewDialog(title string, body DialogBody) *Dialog {
 func (d *Dialog) UpdateSize(w, h int) {
 	d.width = w
 	d.height = h
+	if form, ok := d.body.(*FormBody); ok {
+		form.SetWidth(min(DEFAULT_FORM_WIDTH, max(20, w-8)))
+	}
 }
 
 func (d *Dialog) View() string {
@@ -65,13 +86,25 @@ func (d *Dialog) View() string {
 	question := lipgloss.NewStyle().Align(lipgloss.Left).Render(d.title + "\n")
 	body := d.body.View() + "\n"
 
+	if _, ok := d.body.(Submitter); ok {
+		return d.place(lipgloss.JoinVertical(lipgloss.Top, question, body))
+	}
+
+	buttonStyle := d.theme.Base().
+		Foreground(d.theme.ButtonText()).
+		Background(d.theme.Button()).
+		Padding(0, buttonPadding)
+	activeButtonStyle := buttonStyle.
+		Background(d.theme.Highlight()).
+		Underline(true)
+
 	var okButton string
-	cancelButton := buttonStyle.Render("Cancel")
+	cancelButton := buttonStyle.Render(CANCEL_BUTTON)
 	if d.confirmStatus == "yes" {
-		okButton = activeButtonStyle.Render("Yes")
+		okButton = activeButtonStyle.Render(YES_BUTTON)
 	} else {
-		okButton = buttonStyle.Render("Yes")
-		cancelButton = activeButtonStyle.Render("Cancel")
+		okButton = buttonStyle.Render(YES_BUTTON)
+		cancelButton = activeButtonStyle.Render(CANCEL_BUTTON)
 	}
 
 	// Create a filler to push buttons to the right
@@ -81,13 +114,18 @@ func (d *Dialog) View() string {
 	filler := lipgloss.NewStyle().Width(fillerWidth).Render("")
 
 	buttons := lipgloss.JoinHorizontal(lipgloss.Left, filler, cancelButton,
-		lipgloss.NewStyle().Background(dialogBackgroundColor).Render(" "),
+		d.theme.Base().Background(d.theme.Surface()).Render(" "),
 		okButton)
 
 	content := lipgloss.JoinVertical(lipgloss.Top, question, body, buttons)
 
-	box := lipgloss.NewStyle().
-		Background(dialogBackgroundColor).
+	return d.place(content)
+}
+
+// place centers content in a box on the dialog's background.
+func (d *Dialog) place(content string) string {
+	box := d.theme.Base().
+		Background(d.theme.Surface()).
 		Padding(1).
 		Render(content)
 
@@ -95,7 +133,7 @@ func (d *Dialog) View() string {
 		lipgloss.Center, lipgloss.Center,
 		dialogBoxStyle.Render(box),
 		lipgloss.WithWhitespaceChars("猫咪"),
-		lipgloss.WithWhitespaceForeground(subtle),
+		lipgloss.WithWhitespaceForeground(d.theme.Border()),
 	)
 
 	return dialog
@@ -112,22 +150,41 @@ func (d *Dialog) Update(msg tea.Msg) tea.Cmd {
 
 	cmds = append(cmds, d.body.Update(msg))
 
+	if submitter, ok := d.body.(Submitter); ok {
+		switch {
+		case submitter.Submitted():
+			d.Value = d.body.Value()
+			d.confirmStatus = "yes"
+			d.done = true
+		case submitter.Aborted():
+			d.Value = nil
+			d.confirmStatus = "cancel"
+			d.done = true
+		}
+		if msg, ok := msg.(tea.KeyMsg); ok && key.Matches(msg, cancelKey.Binding) {
+			d.Value = nil
+			d.confirmStatus = "cancel"
+			d.done = true
+		}
+		return tea.Batch(cmds...)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "enter":
+		switch {
+		case key.Matches(msg, confirmKey.Binding):
 			if !d.done {
 				d.Value = d.body.Value()
 				d.done = true
 			}
-		case "esc":
+		case key.Matches(msg, cancelKey.Binding):
 			d.Value = nil
 			d.done = true
-		case "left":
+		case key.Matches(msg, noKey.Binding):
 			if !d.body.Focused() {
 				d.confirmStatus = "cancel"
 			}
-		case "right":
+		case key.Matches(msg, yesKey.Binding):
 			if !d.body.Focused() {
 				d.confirmStatus = "yes"
 			}
@@ -137,6 +194,55 @@ func (d *Dialog) Update(msg tea.Msg) tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// Mouse presses the button under a left click at x, y of the dialog's view.
+// The button's key is sent, so a click takes the same path as a key press.
+func (d *Dialog) Mouse(msg tea.MouseMsg) tea.Cmd {
+	if !d.visible || d.done || msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft {
+		return nil
+	}
+
+	var binding *keymap.Binding
+	switch d.buttonAt(msg.X, msg.Y) {
+	case YES_BUTTON:
+		d.confirmStatus = "yes"
+		binding = confirmKey
+	case CANCEL_BUTTON:
+		binding = cancelKey
+	default:
+		return nil
+	}
+	press, ok := keymap.KeyMsg(binding.Key())
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg { return press }
+}
+
+// buttonAt returns the label of the button at x, y of the dialog's view, or
+// an empty string. Forms have no buttons.
+func (d *Dialog) buttonAt(x, y int) string {
+	if _, ok := d.body.(Submitter); ok {
+		return ""
+	}
+	lines := strings.Split(ansi.Strip(d.View()), "\n")
+	if y < 0 || y >= len(lines) {
+		return ""
+	}
+	// both buttons are on the last line of the box
+	line := lines[y]
+	if !strings.Contains(line, CANCEL_BUTTON) || !strings.Contains(line, YES_BUTTON) {
+		return ""
+	}
+	for _, label := range []string{CANCEL_BUTTON, YES_BUTTON} {
+		start := ansi.StringWidth(line[:strings.LastIndex(line, label)]) - buttonPadding
+		end := start + lipgloss.Width(label) + 2*buttonPadding
+		if x >= start && x < end {
+			return label
+		}
+	}
+	return ""
+}
+
 func (d *Dialog) GetConfirm() string {
 	return d.confirmStatus
 }
@@ -161,3 +267,61 @@ func (d *Dialog) Reset() {
 	d.done = false
 	d.confirmStatus = "yes"
 }
+
+// Bindings lists the keys the dialog reacts to. Bodies with their own keys,
+// like forms, report those of their focused field.
+func (d *Dialog) Bindings() []key.Binding {
+	if body, ok := d.body.(interface{ KeyBinds() []key.Binding }); ok {
+		return append(body.KeyBinds(), cancelKey.Binding)
+	}
+	return []key.Binding{confirmKey.Binding, cancelKey.Binding, noKey.Binding, yesKey.Binding}
+}
+
+// ActiveBindings returns the bindings of the first open dialog, or nil if
+// none of them is open.
+func ActiveBindings(dialogs ...*Dialog) []key.Binding {
+	for _, d := range dialogs {
+		if d.IsVisible() && !d.IsDone() {
+			return d.Bindings()
+		}
+	}
+	return nil
+}
+
+// SetTheme restyles the dialog and its body.
+func (d *Dialog) SetTheme(t theme.Theme) {
+	d.theme = t
+	if form, ok := d.body.(*FormBody); ok {
+		form.SetTheme(t.Form())
+	}
+}
+
+// SetBody replaces the body, e.g. to show a question about a different item.
+func (d *Dialog) SetBody(body DialogBody) {
+	d.body = body
+}
+
+// ConfirmBody is a body without inputs, used to ask for a plain yes or no.
+type ConfirmBody struct {
+	text string
+}
+
+func NewConfirmBody(text string) *ConfirmBody {
+	return &ConfirmBody{text: text}
+}
+
+func (cb *ConfirmBody) Update(msg tea.Msg) tea.Cmd {
+	return nil
+}
+
+func (cb *ConfirmBody) View() string {
+	return cb.text
+}
+
+func (cb *ConfirmBody) Value() any {
+	return true
+}
+
+func (cb *ConfirmBody) Focused() bool {
+	return false
+}
@@ -0,0 +1,95 @@
+package dialog
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+const DEFAULT_FORM_WIDTH = 60
+
+// FormBody hosts a huh.Form with any number of fields. Enter and tab move
+// to the next field, shift+tab back, and enter on the last field submits
+// once every field passes its validation; errors are shown inline by huh.
+//
+// A huh.Form cannot be reset, so the form is rebuilt from fields on every
+// Reset. The fields bind their values to variables owned by the caller, who
+// sets them beforehand to prefill the form and reads them once submitted.
+type FormBody struct {
+	theme  *huh.Theme
+	fields func() []huh.Field
+	form   *huh.Form
+	width  int
+}
+
+func NewFormBody(theme *huh.Theme, fields func() []huh.Field) *FormBody {
+	fb := &FormBody{
+		theme:  theme,
+		fields: fields,
+		width:  DEFAULT_FORM_WIDTH,
+	}
+	fb.Reset()
+	return fb
+}
+
+func (fb *FormBody) Update(msg tea.Msg) tea.Cmd {
+	model, cmd := fb.form.Update(msg)
+	if form, ok := model.(*huh.Form); ok {
+		fb.form = form
+	}
+	return cmd
+}
+
+func (fb *FormBody) View() string {
+	return fb.form.View()
+}
+
+// Value returns the submitted form, results of keyed fields can be read with Get.
+func (fb *FormBody) Value() any {
+	return fb.form
+}
+
+func (fb *FormBody) Focused() bool {
+	return fb.form.State == huh.StateNormal
+}
+
+// Submitted reports whether the last field was confirmed with valid values.
+func (fb *FormBody) Submitted() bool {
+	return fb.form.State == huh.StateCompleted
+}
+
+// Aborted reports whether the form was cancelled from within, e.g. with ctrl+c.
+func (fb *FormBody) Aborted() bool {
+	return fb.form.State == huh.StateAborted
+}
+
+// KeyBinds returns the keys of the focused field.
+func (fb *FormBody) KeyBinds() []key.Binding {
+	if fb.form == nil {
+		return nil
+	}
+	return fb.form.KeyBinds()
+}
+
+// Reset rebuilds the form from the current values of the bound variables.
+func (fb *FormBody) Reset() {
+	fb.form = huh.NewForm(huh.NewGroup(fb.fields()...)).
+		WithTheme(fb.theme).
+		WithShowHelp(true).
+		WithWidth(fb.width)
+}
+
+// Init focuses the first field, it has to be called after Reset.
+func (fb *FormBody) Init() tea.Cmd {
+	return fb.form.Init()
+}
+
+// SetTheme changes the theme, taking effect on the next Reset.
+func (fb *FormBody) SetTheme(t *huh.Theme) {
+	fb.theme = t
+}
+
+// SetWidth sets the width of the form, taking effect on the next Reset.
+func (fb *FormBody) SetWidth(width int) {
+	fb.width = width
+}
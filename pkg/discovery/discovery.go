@@ -0,0 +1,149 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DISCOVERY_FILE = "server.json"
+const PID_FILE = "p1.pid"
+const PROBE_TIMEOUT = 500 * time.Millisecond
+
+// ServerInfo is written by a running server so that clients on the same host
+// can find it without being told its address.
+type ServerInfo struct {
+	ID        string    `json:"id"`
+	Address   string    `json:"address"`
+	Port      int       `json:"port"`
+	WSLink    string    `json:"ws_link"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// StateDir returns the directory for state worth keeping between runs but
+// not worth backing up, such as the discovery file and logs, following the
+// XDG base directory spec.
+func StateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "p1")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "p1")
+	}
+	return filepath.Join(home, ".local", "state", "p1")
+}
+
+// Write records info in dir, replacing any previous discovery file.
+func Write(dir string, info ServerInfo) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so readers never see a partial file
+	path := filepath.Join(dir, DISCOVERY_FILE)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Remove deletes the discovery file if it still belongs to the server with id.
+func Remove(dir string, id string) error {
+	info, err := Read(dir)
+	if err != nil {
+		return nil
+	}
+	if info.ID != id {
+		return nil
+	}
+	return os.Remove(filepath.Join(dir, DISCOVERY_FILE))
+}
+
+// WritePID writes the process ID to the pidfile in dir.
+func WritePID(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, PID_FILE), []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// RemovePID deletes the pidfile in dir if it holds the ID of this process.
+func RemovePID(dir string) error {
+	path := filepath.Join(dir, PID_FILE)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// Read returns the server info stored in dir.
+func Read(dir string) (*ServerInfo, error) {
+	data, err := os.ReadFile(filepath.Join(dir, DISCOVERY_FILE))
+	if err != nil {
+		return nil, err
+	}
+	var info ServerInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid discovery file: %w", err)
+	}
+	return &info, nil
+}
+
+// Find returns the server recorded in dir if it is still accepting connections.
+func Find(dir string) (*ServerInfo, error) {
+	info, err := Read(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no running server found in %s: %w", dir, err)
+	}
+	conn, err := net.DialTimeout("tcp", info.Address, PROBE_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("server %s at %s is not reachable: %w", info.ID, info.Address, err)
+	}
+	conn.Close()
+	return info, nil
+}
+
+// NormalizeLink turns the user supplied address into a websocket link.
+// "host:port", "http://host:port" and full "ws://host:port/ws" links are accepted.
+func NormalizeLink(address string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("empty server address")
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		u, err = url.Parse("ws://" + address)
+		if err != nil {
+			return "", fmt.Errorf("invalid server address %q: %w", address, err)
+		}
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("unsupported scheme %q in %q", u.Scheme, address)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/ws"
+	}
+	return u.String(), nil
+}
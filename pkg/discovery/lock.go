@@ -0,0 +1,41 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const LOCK_FILE = "server.lock"
+
+// ErrLocked is returned by Lock when another server holds the lock.
+var ErrLocked = errors.New("another server is running")
+
+// DirLock makes sure only one server uses a data dir. The operating system
+// releases it when the process ends, so a crashed server does not leave a
+// stale lock behind.
+type DirLock struct {
+	file *os.File
+}
+
+// Lock takes the lock of dir without waiting for it.
+func Lock(dir string) (*DirLock, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(dir, LOCK_FILE), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &DirLock{file: file}, nil
+}
+
+// Unlock releases the lock, the lock file stays for the next server.
+func (l *DirLock) Unlock() error {
+	return l.file.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package discovery
+
+import "os"
+
+// lockFile is not supported here, every server gets the lock and the
+// discovery file names the one that started last.
+func lockFile(file *os.File) error {
+	return nil
+}
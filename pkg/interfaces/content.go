@@ -1,12 +1,42 @@
 package interfaces
 
-import tea "github.com/charmbracelet/bubbletea"
+import (
+	"p1/pkg/keymap"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
 
 type FooterCommand struct {
 	Key   string
 	Value string
 }
 
+// ActionProvider is implemented by screen contents with actions of their
+// own. The footer and the command palette are both built from that list.
+type ActionProvider interface {
+	Actions() []*keymap.Binding
+}
+
+// DialogProvider is implemented by screen contents that open dialogs, so
+// the help overlay can list the keys of the open one.
+type DialogProvider interface {
+	DialogBindings() []key.Binding // Nil while no dialog is open
+}
+
+// MouseHandler is implemented by screen contents that react to the mouse.
+// The coordinates of the event are relative to the top left corner of the
+// content's view.
+type MouseHandler interface {
+	Mouse(msg tea.MouseMsg) tea.Cmd
+}
+
+// FocusHandler is implemented by screen contents that need to know whether
+// their screen has the focus, e.g. to tell read messages from unread ones.
+type FocusHandler interface {
+	SetFocused(focused bool)
+}
+
 type Content interface {
 	Update(msg tea.Msg) tea.Cmd
 	View() string
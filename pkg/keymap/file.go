@@ -0,0 +1,49 @@
+package keymap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+)
+
+const KEYMAP_FILE = "keymap.json"
+
+// File is the keymap file in the config directory, e.g.
+//
+//	{"preset": "vim", "bindings": {"projects.new": ["N"], "services.sort": []}}
+type File struct {
+	Preset   string              `json:"preset,omitempty"`   // One of Presets, applied before the bindings
+	Bindings map[string][]string `json:"bindings,omitempty"` // Keys by binding ID, an empty list disables the binding
+}
+
+// LoadFile reads the keymap file from dir and applies it on top of base,
+// the keymap settings of the config file, to the default keymap. A missing
+// file leaves base in place.
+func LoadFile(dir string, base File) error {
+	file := File{}
+	raw, err := os.ReadFile(filepath.Join(dir, KEYMAP_FILE))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return fmt.Errorf("%s: %w", KEYMAP_FILE, err)
+		}
+	}
+	preset := base.Preset
+	if file.Preset != "" {
+		preset = file.Preset
+	}
+	bindings := maps.Clone(base.Bindings)
+	if bindings == nil {
+		bindings = map[string][]string{}
+	}
+	maps.Copy(bindings, file.Bindings)
+	if err := Default.Apply(preset, bindings); err != nil {
+		return fmt.Errorf("%s: %w", KEYMAP_FILE, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// registerTest adds the bindings used by the tests to the default keymap,
+// which is what LoadFile applies to.
+func registerTest(t *testing.T) (a, b *Binding) {
+	t.Helper()
+	AddScope("test", "Test", SCOPE_GLOBAL)
+	a = Register("test.a", "test", "A", "a")
+	b = Register("test.b", "test", "B", "b")
+	Presets["test"] = map[string][]string{"test.a": {"p"}}
+	t.Cleanup(func() {
+		delete(Presets, "test")
+		Default.Apply("", nil)
+	})
+	return a, b
+}
+
+func writeKeymap(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if content != "" {
+		if err := os.WriteFile(filepath.Join(dir, KEYMAP_FILE), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFile(t *testing.T) {
+	a, b := registerTest(t)
+	tests := []struct {
+		name  string
+		file  string // Content of the keymap file, none if empty
+		base  File
+		wantA []string
+		wantB []string
+		err   string
+	}{
+		{name: "defaults", wantA: []string{"a"}, wantB: []string{"b"}},
+		{name: "config file bindings", base: File{Bindings: map[string][]string{"test.a": {"x"}}}, wantA: []string{"x"}, wantB: []string{"b"}},
+		{name: "keymap file wins", file: `{"bindings": {"test.a": ["y"]}}`, base: File{Bindings: map[string][]string{"test.a": {"x"}, "test.b": {"z"}}}, wantA: []string{"y"}, wantB: []string{"z"}},
+		{name: "preset of the config file", base: File{Preset: "test"}, wantA: []string{"p"}, wantB: []string{"b"}},
+		{name: "bindings over the preset", file: `{"preset": "test", "bindings": {"test.a": ["ctrl+a", "A"]}}`, wantA: []string{"ctrl+a", "A"}, wantB: []string{"b"}},
+		{name: "empty list disables", file: `{"bindings": {"test.b": []}}`, wantA: []string{"a"}, wantB: nil},
+		{name: "unknown binding", file: `{"bindings": {"test.nope": ["x"], "test.a": ["y"]}}`, wantA: []string{"y"}, wantB: []string{"b"}, err: `unknown key binding "test.nope"`},
+		{name: "unknown key", file: `{"bindings": {"test.a": ["y", "hyper+q"]}}`, wantA: []string{"y"}, wantB: []string{"b"}, err: `test.a: unknown key "hyper+q"`},
+		{name: "unknown preset", file: `{"preset": "nano"}`, wantA: []string{"a"}, wantB: []string{"b"}, err: `unknown keymap preset "nano"`},
+		{name: "invalid json", file: `{"bindings": [`, wantA: []string{"a"}, wantB: []string{"b"}, err: KEYMAP_FILE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Default.Apply("", nil)
+			err := LoadFile(writeKeymap(t, tt.file), tt.base)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.err)
+			}
+			if got := enabledKeys(a); !slices.Equal(got, tt.wantA) {
+				t.Errorf("test.a keys = %v, want %v", got, tt.wantA)
+			}
+			if got := enabledKeys(b); !slices.Equal(got, tt.wantB) {
+				t.Errorf("test.b keys = %v, want %v", got, tt.wantB)
+			}
+		})
+	}
+}
+
+func enabledKeys(b *Binding) []string {
+	if !b.Enabled() {
+		return nil
+	}
+	return b.Keys()
+}
+
+func TestConflicts(t *testing.T) {
+	k := New()
+	k.AddScope("parent", "Parent", "")
+	k.AddScope("child", "Child", "parent")
+	k.AddScope("sibling", "Sibling", "parent")
+	k.Register("parent.quit", "parent", "Quit", "q")
+	k.Register("child.new", "child", "New", "n")
+	k.Register("sibling.new", "sibling", "New", "n")
+	k.Register("child.query", "child", "Query", "x")
+
+	if conflicts := k.Conflicts(); len(conflicts) != 0 {
+		t.Fatalf("conflicts = %v, want none", conflicts)
+	}
+	if err := k.Apply("", map[string][]string{"child.query": {"q"}}); err != nil {
+		t.Fatal(err)
+	}
+	conflicts := k.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Key != "q" || conflicts[0].First.ID != "parent.quit" || conflicts[0].Second.ID != "child.query" {
+		t.Errorf("conflicts = %v, want q between parent.quit and child.query", conflicts)
+	}
+	if lineage := k.Lineage("child"); !slices.Equal(lineage, []string{"child", "parent"}) {
+		t.Errorf("lineage = %v, want [child parent]", lineage)
+	}
+}
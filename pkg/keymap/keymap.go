@@ -0,0 +1,245 @@
+package keymap
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Scopes used by more than one package. Screens add their own scope below
+// SCOPE_LIST.
+const (
+	SCOPE_GLOBAL  = "global"
+	SCOPE_SIDEBAR = "sidebar"
+	SCOPE_LIST    = "list"
+	SCOPE_DIALOG  = "dialog"
+	SCOPE_PALETTE = "palette"
+	SCOPE_HELP    = "help"
+)
+
+// Scope groups the bindings that are active at the same time. A scope is
+// also active whenever one of its children is, so its keys must not be
+// reused below it.
+type Scope struct {
+	ID     string
+	Title  string
+	Parent string // Empty for scopes that take every key while they are active
+}
+
+// Binding is a key.Binding with a stable ID, which is what the keymap file
+// refers to, and the scope it is active in.
+type Binding struct {
+	key.Binding
+	ID       string
+	Scope    string
+	title    string
+	defaults []string
+}
+
+// Key returns the first key of the binding, the one shown in hints, or an
+// empty string if the binding is disabled.
+func (b *Binding) Key() string {
+	if !b.Enabled() || len(b.Keys()) == 0 {
+		return ""
+	}
+	return b.Keys()[0]
+}
+
+func (b *Binding) Title() string {
+	return b.title
+}
+
+func (b *Binding) set(keys []string) {
+	b.SetKeys(keys...)
+	b.SetEnabled(len(keys) > 0)
+	if len(keys) > 0 {
+		b.SetHelp(keys[0], b.title)
+	}
+}
+
+// Keymap is the registry of every key binding in the TUI. Bindings are
+// registered with their default keys when their package is initialized and
+// changed in place by Apply, so holders of a *Binding always see the
+// current keys. It is not safe for concurrent use; apply changes before the
+// TUI starts or from its update loop.
+type Keymap struct {
+	scopes   []*Scope
+	bindings []*Binding
+	byID     map[string]*Binding
+}
+
+// Default holds the bindings registered by the TUI packages.
+var Default = New()
+
+func New() *Keymap {
+	return &Keymap{
+		scopes:   []*Scope{},
+		bindings: []*Binding{},
+		byID:     make(map[string]*Binding),
+	}
+}
+
+// AddScope declares a scope on the default keymap.
+func AddScope(id, title, parent string) *Scope {
+	return Default.AddScope(id, title, parent)
+}
+
+// Register adds a binding to the default keymap.
+func Register(id, scope, title string, keys ...string) *Binding {
+	return Default.Register(id, scope, title, keys...)
+}
+
+func (k *Keymap) AddScope(id, title, parent string) *Scope {
+	if scope := k.Scope(id); scope != nil {
+		return scope
+	}
+	scope := &Scope{ID: id, Title: title, Parent: parent}
+	k.scopes = append(k.scopes, scope)
+	return scope
+}
+
+func (k *Keymap) Scope(id string) *Scope {
+	for _, scope := range k.scopes {
+		if scope.ID == id {
+			return scope
+		}
+	}
+	return nil
+}
+
+func (k *Keymap) Scopes() []*Scope {
+	return k.scopes
+}
+
+// Register adds a binding with its default keys. Registering an ID twice
+// returns the existing binding.
+func (k *Keymap) Register(id, scope, title string, keys ...string) *Binding {
+	if b, ok := k.byID[id]; ok {
+		return b
+	}
+	b := &Binding{ID: id, Scope: scope, title: title, defaults: keys}
+	b.set(keys)
+	k.bindings = append(k.bindings, b)
+	k.byID[id] = b
+	return b
+}
+
+func (k *Keymap) Get(id string) *Binding {
+	return k.byID[id]
+}
+
+// Bindings returns every binding in the order they were registered.
+func (k *Keymap) Bindings() []*Binding {
+	return k.bindings
+}
+
+// InScope returns the bindings registered directly in scope.
+func (k *Keymap) InScope(scope string) []*Binding {
+	result := []*Binding{}
+	for _, b := range k.bindings {
+		if b.Scope == scope {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// Apply resets every binding to its default keys, then applies the preset
+// and finally the overrides, both keyed by binding ID. An empty key list
+// disables a binding. Unknown presets and IDs are reported but do not stop
+// the other overrides from being applied.
+func (k *Keymap) Apply(preset string, overrides map[string][]string) error {
+	for _, b := range k.bindings {
+		b.set(b.defaults)
+	}
+
+	errs := []error{}
+	if preset != "" {
+		bindings, ok := Presets[preset]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown keymap preset %q", preset))
+		}
+		errs = append(errs, k.override(bindings)...)
+	}
+	errs = append(errs, k.override(overrides)...)
+	return errors.Join(errs...)
+}
+
+func (k *Keymap) override(bindings map[string][]string) []error {
+	errs := []error{}
+	// sorted so errors come out in a stable order
+	for _, id := range slices.Sorted(maps.Keys(bindings)) {
+		b, ok := k.byID[id]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown key binding %q", id))
+			continue
+		}
+		keys := []string{}
+		for _, key := range bindings[id] {
+			if !Valid(key) {
+				errs = append(errs, fmt.Errorf("%s: unknown key %q", id, key))
+				continue
+			}
+			keys = append(keys, key)
+		}
+		b.set(keys)
+	}
+	return errs
+}
+
+// Conflict is a key bound twice among bindings that are active at the same
+// time.
+type Conflict struct {
+	Key    string
+	First  *Binding
+	Second *Binding
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%q is bound to both %s and %s", c.Key, c.First.ID, c.Second.ID)
+}
+
+// Conflicts lists every key bound twice within a scope or in a scope and one
+// of its parents.
+func (k *Keymap) Conflicts() []Conflict {
+	conflicts := []Conflict{}
+	for i, a := range k.bindings {
+		if !a.Enabled() {
+			continue
+		}
+		for _, b := range k.bindings[i+1:] {
+			if !b.Enabled() || !k.overlap(a.Scope, b.Scope) {
+				continue
+			}
+			for _, key := range a.Keys() {
+				if slices.Contains(b.Keys(), key) {
+					conflicts = append(conflicts, Conflict{Key: key, First: a, Second: b})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// overlap reports whether two scopes can be active at the same time, which
+// is the case when one of them is the other or one of its parents.
+func (k *Keymap) overlap(a, b string) bool {
+	return slices.Contains(k.Lineage(a), b) || slices.Contains(k.Lineage(b), a)
+}
+
+// Lineage returns scope followed by its parents.
+func (k *Keymap) Lineage(scope string) []string {
+	result := []string{}
+	for scope != "" && !slices.Contains(result, scope) {
+		result = append(result, scope)
+		parent := ""
+		if s := k.Scope(scope); s != nil {
+			parent = s.Parent
+		}
+		scope = parent
+	}
+	return result
+}
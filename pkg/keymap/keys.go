@@ -0,0 +1,67 @@
+package keymap
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	AddScope(SCOPE_GLOBAL, "Global", "")
+	AddScope(SCOPE_SIDEBAR, "Sidebar", SCOPE_GLOBAL)
+	AddScope(SCOPE_LIST, "Lists", SCOPE_GLOBAL)
+	AddScope(SCOPE_DIALOG, "Dialog", SCOPE_GLOBAL)
+	AddScope(SCOPE_PALETTE, "Command Palette", "")
+	AddScope(SCOPE_HELP, "Help", "")
+}
+
+// Bindings that work everywhere and in the sidebar. Screen, dialog and
+// palette bindings are registered by their packages.
+var (
+	Palette      = Register("global.palette", SCOPE_GLOBAL, "Commands", "ctrl+p")
+	FocusSidebar = Register("global.sidebar", SCOPE_GLOBAL, "Focus Sidebar", "ctrl+k")
+	Help         = Register("global.help", SCOPE_GLOBAL, "Help", "f1")
+	NextTheme    = Register("global.theme", SCOPE_GLOBAL, "Toggle Theme", "ctrl+t")
+
+	Quit         = Register("sidebar.quit", SCOPE_SIDEBAR, "Quit", "q")
+	Search       = Register("sidebar.search", SCOPE_SIDEBAR, "Search", "?")
+	CancelSearch = Register("sidebar.cancel", SCOPE_SIDEBAR, "Close Search", "esc", "ctrl+c")
+	SidebarUp    = Register("sidebar.up", SCOPE_SIDEBAR, "Up", "up", "k")
+	SidebarDown  = Register("sidebar.down", SCOPE_SIDEBAR, "Down", "down", "j")
+	OpenScreen   = Register("sidebar.open", SCOPE_SIDEBAR, "Open Screen", "enter", "tab")
+)
+
+// keyTypes maps the names bubbletea gives to special keys back to their type.
+var keyTypes = func() map[string]tea.KeyType {
+	types := map[string]tea.KeyType{}
+	for k := tea.KeyType(-100); k <= 127; k++ {
+		name := k.String()
+		if _, ok := types[name]; name != "" && !ok {
+			types[name] = k
+		}
+	}
+	return types
+}()
+
+// KeyMsg builds the message bubbletea sends when key is pressed, so running
+// an action from the palette looks the same to a screen as pressing its key.
+func KeyMsg(key string) (tea.KeyMsg, bool) {
+	alt := false
+	if rest, ok := strings.CutPrefix(key, "alt+"); ok && rest != "" {
+		alt, key = true, rest
+	}
+	if k, ok := keyTypes[key]; ok {
+		return tea.KeyMsg{Type: k, Alt: alt}, true
+	}
+	if utf8.RuneCountInString(key) == 1 {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key), Alt: alt}, true
+	}
+	return tea.KeyMsg{}, false
+}
+
+// Valid reports whether key is a name bubbletea produces for some key press.
+func Valid(key string) bool {
+	_, ok := KeyMsg(key)
+	return ok
+}
@@ -0,0 +1,43 @@
+package keymap
+
+// Presets change the defaults towards the habits of other editors. They
+// only list the bindings they change, keyed by binding ID, and may name
+// bindings of any package.
+var Presets = map[string]map[string][]string{
+	"default": {},
+	"vim": {
+		"sidebar.search": {"/"},
+		"sidebar.open":   {"enter", "l", "tab"},
+		"list.page_down": {"ctrl+f", "pgdown"},
+		"list.page_up":   {"ctrl+b", "pgup"},
+		"list.top":       {"g", "home"},
+		"list.bottom":    {"G", "end"},
+		"projects.new":   {"n"},
+		"projects.back":  {"esc", "h", "backspace"},
+		"palette.up":     {"ctrl+k", "up"},
+		"palette.down":   {"ctrl+j", "down"},
+		"global.sidebar": {"ctrl+h"},
+	},
+	"emacs": {
+		"global.palette": {"alt+x"},
+		"sidebar.quit":   {"ctrl+x"},
+		"sidebar.search": {"ctrl+s"},
+		"sidebar.cancel": {"ctrl+g", "esc"},
+		"sidebar.up":     {"ctrl+p", "up"},
+		"sidebar.down":   {"ctrl+n", "down"},
+		"list.up":        {"ctrl+p", "up"},
+		"list.down":      {"ctrl+n", "down"},
+		"list.page_down": {"ctrl+v", "pgdown"},
+		"list.page_up":   {"alt+v", "pgup"},
+		"list.top":       {"alt+<", "home"},
+		"list.bottom":    {"alt+>", "end"},
+		"projects.new":   {"alt+n"},
+		"projects.back":  {"ctrl+g", "esc", "backspace"},
+		"dialog.cancel":  {"ctrl+g", "esc"},
+		"palette.close":  {"ctrl+g", "esc"},
+		"logs.search":    {"ctrl+s"},
+		"logs.cancel":    {"ctrl+g", "esc"},
+		"palette.up":     {"ctrl+p", "up"},
+		"palette.down":   {"ctrl+n", "down"},
+	},
+}
@@ -0,0 +1,98 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+const MAX_BACKLOG_BYTES = 1 << 20 // How far back into an existing file the first read goes
+
+// Follower reads the entries appended to a log file, like tail -f. It
+// starts over when the file is truncated or replaced, e.g. by rotation.
+type Follower struct {
+	path    string
+	info    fs.FileInfo // The file read last, to notice when it is replaced
+	offset  int64       // How much of the file has been read
+	partial []byte      // Start of a line that has not been finished yet
+}
+
+func NewFollower(path string) *Follower {
+	return &Follower{path: path}
+}
+
+func (f *Follower) Path() string {
+	return f.path
+}
+
+// Read returns the entries written since the last call. The first call
+// returns the end of what the file already holds. A missing file is not an
+// error, it may just not have been written yet.
+func (f *Follower) Read() ([]Entry, error) {
+	file, err := os.Open(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	first := f.info == nil
+	if !first && (!os.SameFile(f.info, info) || info.Size() < f.offset) {
+		f.offset = 0
+		f.partial = nil
+	}
+	f.info = info
+
+	skipLine := false
+	if first && info.Size() > MAX_BACKLOG_BYTES {
+		// the first line would most likely be cut in half
+		f.offset = info.Size() - MAX_BACKLOG_BYTES
+		skipLine = true
+	}
+	if info.Size() == f.offset {
+		return nil, nil
+	}
+
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(file, info.Size()-f.offset))
+	if err != nil {
+		return nil, err
+	}
+	f.offset += int64(len(data))
+
+	data = append(f.partial, data...)
+	end := bytes.LastIndexByte(data, '\n')
+	f.partial = bytes.Clone(data[end+1:])
+	if end < 0 {
+		return nil, nil
+	}
+
+	lines := bytes.Split(data[:end], []byte("\n"))
+	if skipLine {
+		lines = lines[1:]
+	}
+	entries := make([]Entry, 0, len(lines))
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		entry, ok := Parse(string(line))
+		if !ok && len(entries) > 0 {
+			// stack traces and the like are filtered with the entry before them
+			entry.Time = entries[len(entries)-1].Time
+			entry.Level = entries[len(entries)-1].Level
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
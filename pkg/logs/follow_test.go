@@ -0,0 +1,104 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func appendLog(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func logLine(msg string) string {
+	return "time=2024-05-01T12:30:00.000Z level=ERROR msg=" + msg + "\n"
+}
+
+func messagesOf(entries []Entry) []string {
+	msgs := []string{}
+	for _, entry := range entries {
+		msgs = append(msgs, entry.Message)
+	}
+	return msgs
+}
+
+func TestFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.log")
+	f := NewFollower(path)
+
+	steps := []struct {
+		name  string
+		write func()
+		want  []string
+	}{
+		{name: "missing file", write: func() {}, want: []string{}},
+		{name: "existing lines", write: func() { appendLog(t, path, logLine("a")+logLine("b")) }, want: []string{"a", "b"}},
+		{name: "nothing new", write: func() {}, want: []string{}},
+		{name: "partial line", write: func() { appendLog(t, path, logLine("c")+"time=2024-05-01T12:30:00.000Z level=INFO") }, want: []string{"c"}},
+		{name: "line finished", write: func() { appendLog(t, path, " msg=d\n") }, want: []string{"d"}},
+		{name: "truncated", write: func() { os.WriteFile(path, []byte(logLine("e")), 0o644) }, want: []string{"e"}},
+		{
+			name: "replaced",
+			write: func() {
+				os.Rename(path, path+".1")
+				appendLog(t, path, logLine("f")+logLine("g"))
+			},
+			want: []string{"f", "g"},
+		},
+	}
+	for _, step := range steps {
+		step.write()
+		entries, err := f.Read()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := messagesOf(entries); !slices.Equal(got, step.want) {
+			t.Errorf("%s: read %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestFollowerStackTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.log")
+	appendLog(t, path, logLine("panic")+"goroutine 1 [running]:\n\n")
+
+	entries, err := NewFollower(path).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("read %v, want the record and the trace", messagesOf(entries))
+	}
+	if entries[1].Level != entries[0].Level || !entries[1].Time.Equal(entries[0].Time) {
+		t.Errorf("trace = %+v, want the time and level of %+v", entries[1], entries[0])
+	}
+}
+
+func TestFollowerBacklog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.log")
+	line := logLine(strings.Repeat("x", 100))
+	appendLog(t, path, strings.Repeat(line, MAX_BACKLOG_BYTES/len(line)+10))
+
+	entries, err := NewFollower(path).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || len(entries) > MAX_BACKLOG_BYTES/len(line) {
+		t.Fatalf("read %d entries, want at most %d", len(entries), MAX_BACKLOG_BYTES/len(line))
+	}
+	for _, entry := range entries {
+		if entry.Message != strings.Repeat("x", 100) {
+			t.Fatalf("read the cut line %q", entry.Message)
+		}
+	}
+}
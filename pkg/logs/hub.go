@@ -0,0 +1,167 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+const (
+	MAX_RECENT_ENTRIES = 200 // Entries a new subscriber is caught up with
+	SUBSCRIBER_BUFFER  = 256 // Entries queued per subscriber before new ones are dropped
+)
+
+// Hub keeps the most recent log entries of the process and hands new ones
+// to its subscribers, so a server can stream its logs to clients.
+type Hub struct {
+	mu          sync.Mutex
+	recent      []Entry
+	subscribers map[chan Entry]struct{}
+	skipped     []string // Packages whose records are not published
+	packages    packageCache
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		recent:      []Entry{},
+		subscribers: make(map[chan Entry]struct{}),
+	}
+}
+
+// Handler returns a slog handler that passes records on to next and
+// publishes them on the hub.
+func (h *Hub) Handler(next slog.Handler) slog.Handler {
+	return &hubHandler{hub: h, next: next}
+}
+
+// Skip keeps the records logged from the packages with the given import
+// paths off the hub, they are still passed on. A client running in the same
+// process as the server must not have what it logs about the messages it
+// receives streamed back to it.
+func (h *Hub) Skip(pkgs ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.skipped = append(h.skipped, pkgs...)
+}
+
+// Recent returns the latest entries, oldest first.
+func (h *Hub) Recent() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.recent)
+}
+
+// Subscribe returns a channel receiving every new entry and a function
+// that ends the subscription. Entries are dropped while the channel is
+// full, a slow reader must never hold up logging.
+func (h *Hub) Subscribe() (<-chan Entry, func()) {
+	ch := make(chan Entry, SUBSCRIBER_BUFFER)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// skips reports whether records logged from the function at pc are kept
+// off the hub.
+func (h *Hub) skips(pc uintptr) bool {
+	h.mu.Lock()
+	skipped := h.skipped
+	h.mu.Unlock()
+	return len(skipped) > 0 && slices.Contains(skipped, h.packages.of(pc))
+}
+
+func (h *Hub) publish(entry Entry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recent = append(h.recent, entry)
+	if len(h.recent) > MAX_RECENT_ENTRIES {
+		h.recent = h.recent[len(h.recent)-MAX_RECENT_ENTRIES:]
+	}
+	for ch := range h.subscribers {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
+
+type hubHandler struct {
+	hub    *Hub
+	next   slog.Handler
+	attrs  []Attr // Added with WithAttrs
+	prefix string // Groups opened with WithGroup, each followed by a dot
+}
+
+func (h *hubHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *hubHandler) Handle(ctx context.Context, record slog.Record) error {
+	err := h.next.Handle(ctx, record)
+	if h.hub.skips(record.PC) {
+		return err
+	}
+
+	entry := Entry{
+		Time:    record.Time,
+		Level:   record.Level,
+		Message: record.Message,
+		Attrs:   slices.Clone(h.attrs),
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		entry.Attrs = appendAttr(entry.Attrs, h.prefix, attr)
+		return true
+	})
+	h.hub.publish(entry)
+	return err
+}
+
+func (h *hubHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.next = h.next.WithAttrs(attrs)
+	derived.attrs = slices.Clone(h.attrs)
+	for _, attr := range attrs {
+		derived.attrs = appendAttr(derived.attrs, h.prefix, attr)
+	}
+	return &derived
+}
+
+func (h *hubHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	derived := *h
+	derived.next = h.next.WithGroup(name)
+	derived.prefix = h.prefix + name + "."
+	return &derived
+}
+
+// appendAttr formats attr the way the text handler does, with the keys of
+// groups joined by dots.
+func appendAttr(attrs []Attr, prefix string, attr slog.Attr) []Attr {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			attrs = appendAttr(attrs, prefix, member)
+		}
+		return attrs
+	}
+	if attr.Equal(slog.Attr{}) {
+		return attrs
+	}
+	return append(attrs, Attr{Key: prefix + attr.Key, Value: value.String()})
+}
@@ -0,0 +1,44 @@
+package logs
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestHubPublishes(t *testing.T) {
+	hub := NewHub()
+	entries, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	var out bytes.Buffer
+	logger := slog.New(hub.Handler(slog.NewTextHandler(&out, nil)))
+	logger.With("server", "s1").WithGroup("peer").Info("joined", "id", "p1")
+
+	want := []Attr{{"server", "s1"}, {"peer.id", "p1"}}
+	entry := <-entries
+	if entry.Message != "joined" || !reflect.DeepEqual(entry.Attrs, want) {
+		t.Errorf("entry = %+v, want joined with %v", entry, want)
+	}
+	if recent := hub.Recent(); len(recent) != 1 {
+		t.Errorf("recent = %v, want the one entry", recent)
+	}
+	if out.Len() == 0 {
+		t.Error("record was not passed on")
+	}
+}
+
+func TestHubSkip(t *testing.T) {
+	hub := NewHub()
+	hub.Skip("p1/pkg/logs")
+
+	var out bytes.Buffer
+	slog.New(hub.Handler(slog.NewTextHandler(&out, nil))).Info("received text message")
+	if recent := hub.Recent(); len(recent) != 0 {
+		t.Errorf("recent = %v, want the record kept off the hub", recent)
+	}
+	if out.Len() == 0 {
+		t.Error("skipped record was not passed on")
+	}
+}
@@ -0,0 +1,162 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Levels are the minimum levels records are written at, the default one
+// and those of single packages.
+type Levels struct {
+	Default  slog.Level
+	Packages map[string]slog.Level // By import path or its last element, e.g. "client"
+}
+
+// ParseLevels reads a level spec like "info,client=debug,server=warn": a
+// default level followed by per-package ones. Either part may be left out.
+func ParseLevels(spec string) (Levels, error) {
+	levels := Levels{Default: slog.LevelInfo, Packages: map[string]slog.Level{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pkg, name, found := strings.Cut(part, "=")
+		if !found {
+			name = pkg
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+			return levels, fmt.Errorf("invalid log level %q", part)
+		}
+		if !found {
+			levels.Default = level
+			continue
+		}
+		pkg = strings.TrimSpace(pkg)
+		if pkg == "" {
+			return levels, fmt.Errorf("invalid log level %q: missing package", part)
+		}
+		levels.Packages[pkg] = level
+	}
+	return levels, nil
+}
+
+// Min is the lowest level any package logs at.
+func (l Levels) Min() slog.Level {
+	lowest := l.Default
+	for _, level := range l.Packages {
+		lowest = min(lowest, level)
+	}
+	return lowest
+}
+
+// For returns the level of the package with import path pkg.
+func (l Levels) For(pkg string) slog.Level {
+	if level, ok := l.Packages[pkg]; ok {
+		return level
+	}
+	if level, ok := l.Packages[path.Base(pkg)]; ok {
+		return level
+	}
+	return l.Default
+}
+
+// LevelsVar holds levels that can be changed while they are in use, like
+// slog.LevelVar does for a single level. As a slog.Leveler it stands for
+// the lowest of the levels.
+type LevelsVar struct {
+	current atomic.Pointer[currentLevels]
+}
+
+type currentLevels struct {
+	levels Levels
+	min    slog.Level
+}
+
+func NewLevelsVar(levels Levels) *LevelsVar {
+	v := &LevelsVar{}
+	v.Set(levels)
+	return v
+}
+
+func (v *LevelsVar) Load() Levels {
+	return v.current.Load().levels
+}
+
+func (v *LevelsVar) Set(levels Levels) {
+	v.current.Store(&currentLevels{levels: levels, min: levels.Min()})
+}
+
+// Level is the lowest level any package logs at.
+func (v *LevelsVar) Level() slog.Level {
+	return v.current.Load().min
+}
+
+// Filter returns a handler that drops the records below the level of the
+// package they were logged from before passing the rest on to next. The
+// levels are looked up for every record, so setting them takes effect at
+// once.
+func Filter(next slog.Handler, levels *LevelsVar) slog.Handler {
+	return &filterHandler{next: next, levels: levels, packages: &packageCache{}}
+}
+
+type filterHandler struct {
+	next     slog.Handler
+	levels   *LevelsVar
+	packages *packageCache // Shared by derived handlers
+}
+
+func (h *filterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level() && h.next.Enabled(ctx, level)
+}
+
+func (h *filterHandler) Handle(ctx context.Context, record slog.Record) error {
+	levels := h.levels.Load()
+	level := levels.Default
+	if len(levels.Packages) > 0 {
+		level = levels.For(h.packages.of(record.PC))
+	}
+	if record.Level < level {
+		return nil
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *filterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &filterHandler{next: h.next.WithAttrs(attrs), levels: h.levels, packages: h.packages}
+}
+
+func (h *filterHandler) WithGroup(name string) slog.Handler {
+	return &filterHandler{next: h.next.WithGroup(name), levels: h.levels, packages: h.packages}
+}
+
+// packageCache remembers the package of each program counter seen.
+type packageCache struct {
+	sync.Map
+}
+
+// of returns the import path of the package the function at pc belongs to.
+func (c *packageCache) of(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	if pkg, ok := c.Load(pc); ok {
+		return pkg.(string)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	// a function is named like p1/pkg/client.(*Client).reconnect
+	name := frame.Function
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		name = name[:slash+1+dot]
+	}
+	c.Store(pc, name)
+	return name
+}
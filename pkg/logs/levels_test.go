@@ -0,0 +1,125 @@
+package logs
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Levels
+		err  string
+	}{
+		{spec: "", want: Levels{Default: slog.LevelInfo, Packages: map[string]slog.Level{}}},
+		{spec: "debug", want: Levels{Default: slog.LevelDebug, Packages: map[string]slog.Level{}}},
+		{spec: "WARN", want: Levels{Default: slog.LevelWarn, Packages: map[string]slog.Level{}}},
+		{spec: "error+2", want: Levels{Default: slog.LevelError + 2, Packages: map[string]slog.Level{}}},
+		{spec: "client=debug", want: Levels{Default: slog.LevelInfo, Packages: map[string]slog.Level{"client": slog.LevelDebug}}},
+		{
+			spec: " warn , client = debug, p1/pkg/server=error ,",
+			want: Levels{Default: slog.LevelWarn, Packages: map[string]slog.Level{"client": slog.LevelDebug, "p1/pkg/server": slog.LevelError}},
+		},
+		{spec: "loud", err: `invalid log level "loud"`},
+		{spec: "info,client=loud", err: `invalid log level "client=loud"`},
+		{spec: "=debug", err: "missing package"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseLevels(tt.spec)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("levels = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelsFor(t *testing.T) {
+	levels := Levels{
+		Default:  slog.LevelInfo,
+		Packages: map[string]slog.Level{"client": slog.LevelDebug, "p1/pkg/server": slog.LevelError, "server": slog.LevelWarn},
+	}
+	tests := []struct {
+		pkg  string
+		want slog.Level
+	}{
+		{pkg: "p1/pkg/client", want: slog.LevelDebug},
+		{pkg: "p1/pkg/server", want: slog.LevelError},
+		{pkg: "other/server", want: slog.LevelWarn},
+		{pkg: "p1/pkg/logs", want: slog.LevelInfo},
+		{pkg: "", want: slog.LevelInfo},
+	}
+	for _, tt := range tests {
+		if got := levels.For(tt.pkg); got != tt.want {
+			t.Errorf("For(%q) = %v, want %v", tt.pkg, got, tt.want)
+		}
+	}
+	if got := levels.Min(); got != slog.LevelDebug {
+		t.Errorf("Min() = %v, want DEBUG", got)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want []string // Messages written by this package
+	}{
+		{name: "default level", spec: "info", want: []string{"info", "warn"}},
+		{name: "package level", spec: "error,logs=debug", want: []string{"debug", "info", "warn"}},
+		{name: "import path", spec: "debug,p1/pkg/logs=warn", want: []string{"warn"}},
+		{name: "other package", spec: "warn,client=debug", want: []string{"warn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels, err := ParseLevels(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			next := slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})
+			logger := slog.New(Filter(next, NewLevelsVar(levels))).With("test", tt.name)
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.Warn("warn")
+
+			got := []string{}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if entry, ok := Parse(line); ok {
+					got = append(got, entry.Message)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("written %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelsVarSet(t *testing.T) {
+	levels := NewLevelsVar(Levels{Default: slog.LevelWarn})
+	var out bytes.Buffer
+	logger := slog.New(Filter(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: levels}), levels))
+
+	logger.Info("dropped")
+	levels.Set(Levels{Default: slog.LevelWarn, Packages: map[string]slog.Level{"logs": slog.LevelInfo}})
+	if levels.Level() != slog.LevelInfo {
+		t.Errorf("level = %v, want the lowest of the levels", levels.Level())
+	}
+	logger.Info("written")
+	if got := out.String(); strings.Contains(got, "dropped") || !strings.Contains(got, "written") {
+		t.Errorf("output = %q, want only the record after the change", got)
+	}
+}
@@ -0,0 +1,167 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attr is an attribute of a log entry with its value already formatted.
+type Attr struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Entry is one record written by slog, read back from a log file or
+// streamed by a server.
+type Entry struct {
+	Time    time.Time  `json:"time"`
+	Level   slog.Level `json:"level"`
+	Message string     `json:"msg"`
+	Attrs   []Attr     `json:"attrs,omitempty"`
+	Origin  string     `json:"-"` // ID of the server that sent it, empty for the local file
+}
+
+// Text is the message followed by the attributes as key=value pairs, which
+// is what searches look at.
+func (e *Entry) Text() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, attr := range e.Attrs {
+		b.WriteString(" ")
+		b.WriteString(attr.Key)
+		b.WriteString("=")
+		b.WriteString(attr.Value)
+	}
+	return b.String()
+}
+
+// Parse reads a line written by slog's text or JSON handler. Lines in any
+// other format, like a panic's stack trace, become an entry with the whole
+// line as the message and false is returned.
+func Parse(line string) (Entry, bool) {
+	entry := Entry{Level: slog.LevelInfo}
+	if strings.HasPrefix(line, "{") {
+		if fields, ok := jsonFields(line); ok {
+			return entryOf(fields), true
+		}
+		entry.Message = line
+		return entry, false
+	}
+	fields, ok := splitFields(line)
+	if !ok || len(fields) == 0 || fields[0].Key != slog.TimeKey {
+		entry.Message = line
+		return entry, false
+	}
+	return entryOf(fields), true
+}
+
+// entryOf builds an entry from the fields of a line.
+func entryOf(fields []Attr) Entry {
+	entry := Entry{Level: slog.LevelInfo}
+	for _, field := range fields {
+		switch field.Key {
+		case slog.TimeKey:
+			if t, err := time.Parse(time.RFC3339Nano, field.Value); err == nil {
+				entry.Time = t
+			}
+		case slog.LevelKey:
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(field.Value)); err == nil {
+				entry.Level = level
+			}
+		case slog.MessageKey:
+			entry.Message = field.Value
+		default:
+			entry.Attrs = append(entry.Attrs, field)
+		}
+	}
+	return entry
+}
+
+// jsonFields flattens a line of the JSON handler into fields, joining the
+// keys of groups with dots. It fails on lines without a time.
+func jsonFields(line string) ([]Attr, bool) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil {
+		return nil, false
+	}
+	if _, ok := object[slog.TimeKey]; !ok {
+		return nil, false
+	}
+
+	// the handler writes time, level and message first, the order of the
+	// rest is lost in the map
+	fields := []Attr{}
+	for _, key := range []string{slog.TimeKey, slog.LevelKey, slog.MessageKey} {
+		if value, ok := object[key]; ok {
+			fields = append(fields, Attr{Key: key, Value: fmt.Sprint(value)})
+			delete(object, key)
+		}
+	}
+	return appendJSON(fields, "", object), true
+}
+
+func appendJSON(fields []Attr, prefix string, object map[string]any) []Attr {
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		if group, ok := object[key].(map[string]any); ok {
+			fields = appendJSON(fields, prefix+key+".", group)
+			continue
+		}
+		fields = append(fields, Attr{Key: prefix + key, Value: fmt.Sprint(object[key])})
+	}
+	return fields
+}
+
+// splitFields splits a line into its key=value pairs, unquoting quoted
+// values. It fails on anything that is not a key=value pair.
+func splitFields(line string) ([]Attr, bool) {
+	fields := []Attr{}
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		key, value, found := strings.Cut(rest, "=")
+		if !found || key == "" || strings.ContainsAny(key, " \"") {
+			return nil, false
+		}
+
+		if strings.HasPrefix(value, `"`) {
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, false
+			}
+			fields = append(fields, Attr{Key: key, Value: unquoted})
+			rest = strings.TrimLeft(value[end+1:], " ")
+			continue
+		}
+
+		value, rest, _ = strings.Cut(value, " ")
+		fields = append(fields, Attr{Key: key, Value: value})
+		rest = strings.TrimLeft(rest, " ")
+	}
+	return fields, true
+}
+
+// closingQuote returns the index of the quote that ends the quoted string
+// at the start of s, or -1 if it is not terminated.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
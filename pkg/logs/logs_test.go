@@ -0,0 +1,114 @@
+package logs
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		line string
+		want Entry
+		ok   bool
+	}{
+		{
+			name: "text",
+			line: `time=2024-05-01T12:30:00.000Z level=WARN msg="peer lost" peer=p1 services=2`,
+			want: Entry{Time: at, Level: slog.LevelWarn, Message: "peer lost", Attrs: []Attr{{"peer", "p1"}, {"services", "2"}}},
+			ok:   true,
+		},
+		{
+			name: "quoted values",
+			line: `time=2024-05-01T12:30:00.000Z level=ERROR msg=failed error="dial \"a\": refused" path="a b"`,
+			want: Entry{Time: at, Level: slog.LevelError, Message: "failed", Attrs: []Attr{{"error", `dial "a": refused`}, {"path", "a b"}}},
+			ok:   true,
+		},
+		{
+			name: "json",
+			line: `{"time":"2024-05-01T12:30:00Z","level":"DEBUG","msg":"tick","server":{"id":"s1"},"count":3}`,
+			want: Entry{Time: at, Level: slog.LevelDebug, Message: "tick", Attrs: []Attr{{"count", "3"}, {"server.id", "s1"}}},
+			ok:   true,
+		},
+		{
+			name: "unknown level",
+			line: `time=2024-05-01T12:30:00.000Z level=LOUD msg=hi`,
+			want: Entry{Time: at, Level: slog.LevelInfo, Message: "hi"},
+			ok:   true,
+		},
+		{
+			name: "stack trace",
+			line: "goroutine 1 [running]:",
+			want: Entry{Level: slog.LevelInfo, Message: "goroutine 1 [running]:"},
+		},
+		{
+			name: "json without a time",
+			line: `{"msg":"hi"}`,
+			want: Entry{Level: slog.LevelInfo, Message: `{"msg":"hi"}`},
+		},
+		{
+			name: "unterminated quote",
+			line: `time=2024-05-01T12:30:00.000Z msg="hi`,
+			want: Entry{Level: slog.LevelInfo, Message: `time=2024-05-01T12:30:00.000Z msg="hi`},
+		},
+		{
+			name: "no time first",
+			line: `level=INFO msg=hi`,
+			want: Entry{Level: slog.LevelInfo, Message: `level=INFO msg=hi`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse(tt.line)
+			if ok != tt.ok {
+				t.Errorf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time = tt.want.Time
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entry = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseHandlers reads back what slog's own handlers write.
+func TestParseHandlers(t *testing.T) {
+	handlers := map[string]func(*bytes.Buffer) slog.Handler{
+		FORMAT_TEXT: func(b *bytes.Buffer) slog.Handler { return slog.NewTextHandler(b, nil) },
+		FORMAT_JSON: func(b *bytes.Buffer) slog.Handler { return slog.NewJSONHandler(b, nil) },
+	}
+	for format, handler := range handlers {
+		t.Run(format, func(t *testing.T) {
+			var b bytes.Buffer
+			slog.New(handler(&b)).With("server", "s1").WithGroup("req").Warn("slow = request", "path", "/ws x", "took", time.Second)
+
+			got, ok := Parse(strings.TrimSuffix(b.String(), "\n"))
+			if !ok {
+				t.Fatalf("line %q was not parsed", b.String())
+			}
+			want := []Attr{{"req.path", "/ws x"}, {"req.took", "1s"}, {"server", "s1"}}
+			if format == FORMAT_TEXT {
+				// the text handler keeps the order the attributes were added in
+				want = []Attr{{"server", "s1"}, {"req.path", "/ws x"}, {"req.took", "1s"}}
+			}
+			if got.Level != slog.LevelWarn || got.Message != "slow = request" || got.Time.IsZero() {
+				t.Errorf("entry = %+v", got)
+			}
+			if format == FORMAT_JSON {
+				// durations are written as nanoseconds
+				want[1].Value = "1000000000"
+			}
+			if !reflect.DeepEqual(got.Attrs, want) {
+				t.Errorf("attrs = %v, want %v", got.Attrs, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RotateOptions decide when a log file is moved aside for a new one and how
+// many of the old ones are kept.
+type RotateOptions struct {
+	MaxSize    int64         // Bytes a file may grow to, 0 for no limit
+	MaxAge     time.Duration // How long one file is written to, 0 for no limit
+	MaxBackups int           // Rotated files kept as path.1 (newest) to path.N
+}
+
+// RotatingFile appends to a log file and rotates it by size and age. Old
+// files are renamed to path.1, path.2 and so on, the oldest beyond
+// MaxBackups are removed.
+type RotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	options RotateOptions
+	file    *os.File
+	size    int64
+	started time.Time // When the current file was started, as far as known
+}
+
+// OpenRotating opens path for appending, creating it and its directory if
+// needed. Unlike a plain create, what earlier runs wrote is kept.
+func OpenRotating(path string, options RotateOptions) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	r := &RotatingFile{path: path, options: options}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	// a file left by an earlier run counts from its last write, the best
+	// guess at its age there is
+	if r.size > 0 {
+		if info, err := r.file.Stat(); err == nil {
+			r.started = info.ModTime()
+		}
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) Path() string {
+	return r.path
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.due(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// due reports whether the file has to be rotated before writing n bytes.
+// An empty file is never rotated, a single entry may exceed MaxSize.
+func (r *RotatingFile) due(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.options.MaxSize > 0 && r.size+int64(n) > r.options.MaxSize {
+		return true
+	}
+	return r.options.MaxAge > 0 && time.Since(r.started) > r.options.MaxAge
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.started = time.Now()
+	return nil
+}
+
+// rotate moves the current file to path.1, shifting the backups by one,
+// and starts a new one. If moving fails the current file is simply written
+// on, logging must not stop over it.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.shift()
+	return r.open()
+}
+
+func (r *RotatingFile) shift() error {
+	if r.options.MaxBackups <= 0 {
+		return os.Remove(r.path)
+	}
+	if err := os.Remove(r.backup(r.options.MaxBackups)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := r.options.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(r.path, r.backup(1))
+}
+
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
@@ -0,0 +1,104 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readLogs returns the contents of the file at path and its backups, empty
+// for the ones that do not exist.
+func readLogs(t *testing.T, path string, backups int) []string {
+	t.Helper()
+	contents := []string{}
+	for i := 0; i <= backups; i++ {
+		name := path
+		if i > 0 {
+			name = (&RotatingFile{path: path}).backup(i)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(data))
+	}
+	return contents
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		options RotateOptions
+		writes  []string
+		want    []string // The file, then path.1, path.2 and path.3
+	}{
+		{name: "no limit", writes: []string{"aaaa", "bbbb"}, want: []string{"aaaabbbb", "", "", ""}},
+		{name: "within the limit", options: RotateOptions{MaxSize: 8, MaxBackups: 2}, writes: []string{"aaaa", "bbbb"}, want: []string{"aaaabbbb", "", "", ""}},
+		{name: "rotated", options: RotateOptions{MaxSize: 8, MaxBackups: 2}, writes: []string{"aaaa", "bbbb", "cc"}, want: []string{"cc", "aaaabbbb", "", ""}},
+		{name: "backups shifted", options: RotateOptions{MaxSize: 4, MaxBackups: 2}, writes: []string{"aaaa", "bbbb", "cccc"}, want: []string{"cccc", "bbbb", "aaaa", ""}},
+		{name: "oldest removed", options: RotateOptions{MaxSize: 4, MaxBackups: 2}, writes: []string{"aaaa", "bbbb", "cccc", "dddd"}, want: []string{"dddd", "cccc", "bbbb", ""}},
+		{name: "no backups", options: RotateOptions{MaxSize: 4}, writes: []string{"aaaa", "bbbb"}, want: []string{"bbbb", "", "", ""}},
+		{name: "large entry", options: RotateOptions{MaxSize: 4, MaxBackups: 1}, writes: []string{"aaaaaaaa", "b"}, want: []string{"b", "aaaaaaaa", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "output.log")
+			r, err := OpenRotating(path, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			for _, w := range tt.writes {
+				if _, err := r.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := readLogs(t, path, 3)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("files = %q, want %q", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.log")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	written := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, written, written); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenRotating(path, RotateOptions{MaxAge: time.Minute, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// the file left by an earlier run is as old as its last write
+	r.Write([]byte("new"))
+	r.Write([]byte("er"))
+	if got := readLogs(t, path, 1); got[0] != "newer" || got[1] != "old" {
+		t.Errorf("files = %q, want the old file rotated once", got)
+	}
+}
+
+func TestRotatingFileKeepsContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.log")
+	for _, w := range []string{"first ", "second"} {
+		r, err := OpenRotating(path, RotateOptions{MaxSize: 1 << 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Write([]byte(w))
+		r.Close()
+	}
+	if got := readLogs(t, path, 0); got[0] != "first second" {
+		t.Errorf("file = %q, want what both runs wrote", got[0])
+	}
+}
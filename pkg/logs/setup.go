@@ -0,0 +1,54 @@
+package logs
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+// Options describe where log records go and in which format.
+type Options struct {
+	Level  slog.Leveler // Lowest level the handler writes
+	Format string       // FORMAT_TEXT or FORMAT_JSON
+	File   string       // Path of the log file, empty to write none
+	Stderr bool         // Also write to stderr
+	Rotate RotateOptions
+}
+
+// Open returns a handler writing to the destinations in options and the
+// closer of the log file, if there is one.
+func Open(options Options) (slog.Handler, io.Closer, error) {
+	writers := []io.Writer{}
+	var closer io.Closer = io.NopCloser(nil)
+	if options.File != "" {
+		file, err := OpenRotating(options.File, options.Rotate)
+		if err != nil {
+			return nil, nil, err
+		}
+		writers = append(writers, file)
+		closer = file
+	}
+	if options.Stderr {
+		writers = append(writers, os.Stderr)
+	}
+	if len(writers) == 0 {
+		writers = append(writers, io.Discard)
+	}
+
+	out := io.MultiWriter(writers...)
+	handlerOptions := &slog.HandlerOptions{Level: options.Level}
+	switch options.Format {
+	case FORMAT_TEXT, "":
+		return slog.NewTextHandler(out, handlerOptions), closer, nil
+	case FORMAT_JSON:
+		return slog.NewJSONHandler(out, handlerOptions), closer, nil
+	}
+	closer.Close()
+	return nil, nil, fmt.Errorf("unknown log format %q, use %s or %s", options.Format, FORMAT_TEXT, FORMAT_JSON)
+}
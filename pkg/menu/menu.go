@@ -3,17 +3,21 @@ package menu
 import (
 	"log/slog"
 	"p1/pkg/interfaces"
+	"p1/pkg/keymap"
 	"p1/pkg/models"
 	"p1/pkg/screens"
+	"p1/pkg/tui/theme"
 	"slices"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type Menu struct {
+	theme             theme.Theme
 	items             []*MenuItem
 	selectedItemIndex int
 	selectedItem      *MenuItem
@@ -26,18 +30,20 @@ type Menu struct {
 
 type MenuItem struct {
 	id     string
+	name   string // Title the item was created with, title follows the screen's display
 	title  string
 	screen *screens.Screen
 }
 
-func NewMenu() *Menu {
+func NewMenu(t theme.Theme) *Menu {
 	ti := textinput.New()
 	ti.PromptStyle.MaxWidth(23)
 	ti.PromptStyle.Width(23)
 	ti.Prompt = "▶ "
 	ti.Placeholder = "Search" + strings.Repeat(" ", 23-lipgloss.Width("Search"))
 
-	return &Menu{
+	m := &Menu{
+		theme:             t,
 		items:             []*MenuItem{},
 		selectedItemIndex: -1,
 		selectedItem:      nil,
@@ -47,6 +53,16 @@ func NewMenu() *Menu {
 		width:             30,
 		height:            0,
 	}
+	m.setTheme(t)
+	return m
+}
+
+func (m *Menu) setTheme(t theme.Theme) {
+	m.theme = t
+	m.search.PromptStyle = t.TextHighlight().Background(t.Surface())
+	m.search.TextStyle = t.TextAccent().Background(t.Surface())
+	m.search.PlaceholderStyle = t.TextBody().Background(t.Surface())
+	m.search.Cursor.Style = t.TextHighlight()
 }
 
 func (m *Menu) FooterHeight() int {
@@ -75,10 +91,48 @@ func (m *Menu) RemoveItem(item *MenuItem) *Menu {
 	return m
 }
 
+func (m *Menu) Items() []*MenuItem {
+	return m.items
+}
+
+// Selected returns the item whose screen is shown, or nil.
+func (m *Menu) Selected() *MenuItem {
+	return m.selectedItem
+}
+
+// Select switches to the item with id and focuses its screen. The search is
+// cleared so the item can be found in the list again.
+func (m *Menu) Select(id string) bool {
+	for i, item := range m.items {
+		if item.id != id {
+			continue
+		}
+		if m.selectedItem != nil {
+			m.selectedItem.screen.SetFocused(false)
+		}
+		m.search.Reset()
+		m.search.Blur()
+		m.selectedItemIndex = i
+		m.selectedItem = item
+		m.focused = false
+		item.screen.SetFocused(true)
+		return true
+	}
+	return false
+}
+
+// FocusSidebar moves the focus from the screen back to the sidebar.
+func (m *Menu) FocusSidebar() {
+	m.focused = true
+	if m.selectedItem != nil {
+		m.selectedItem.screen.SetFocused(false)
+	}
+}
+
 func (m *Menu) GetCommands() []*interfaces.FooterCommand {
 	cmds := []*interfaces.FooterCommand{}
 
-	cmds = append(cmds, m.selectedItem.screen.Commands...)
+	cmds = append(cmds, m.selectedItem.screen.Commands()...)
 
 	return cmds
 }
@@ -99,6 +153,10 @@ func (m *Menu) Update(msg tea.Msg) tea.Cmd {
 		m.selectedItemIndex = 0
 		m.selectedItem = filteredItems[0]
 	}
+	// the mouse only concerns what is under it, not every screen
+	if msg, ok := msg.(tea.MouseMsg); ok {
+		return m.mouse(msg, filteredItems)
+	}
 	for _, item := range filteredItems {
 		cmds = append(cmds, item.Update(parentMsg))
 	}
@@ -106,35 +164,33 @@ func (m *Menu) Update(msg tea.Msg) tea.Cmd {
 	switch msg := msg.(type) {
 	case models.InternalWindowSizeMsg:
 		m.height = msg.Height
+	case theme.ChangedMsg:
+		m.setTheme(msg.Theme)
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "?":
+		switch {
+		case key.Matches(msg, keymap.Search.Binding):
 			if m.focused && !m.search.Focused() {
 				m.search.Focus()
 			}
-		case "esc":
+		case key.Matches(msg, keymap.CancelSearch.Binding):
 			if m.search.Focused() {
 				m.search.Blur()
 			} else {
 				m.focused = false
 			}
-		case "ctrl+c":
-			if m.search.Focused() {
-				m.search.Blur()
-			}
-		case "j", "down":
+		case key.Matches(msg, keymap.SidebarDown.Binding):
 			if m.focused && !m.search.Focused() && m.selectedItemIndex < len(filteredItems)-1 {
 				m.selectedItemIndex = max(len(filteredItems)-1, m.selectedItemIndex+1)
 				m.selectedItem = filteredItems[m.selectedItemIndex]
 				m.selectedItem.screen.SetFocused(false)
 			}
-		case "k", "up":
+		case key.Matches(msg, keymap.SidebarUp.Binding):
 			if m.focused && !m.search.Focused() && m.selectedItemIndex > 0 {
 				m.selectedItemIndex = max(0, m.selectedItemIndex-1)
 				m.selectedItem = filteredItems[m.selectedItemIndex]
 				m.selectedItem.screen.SetFocused(false)
 			}
-		case "enter", "tab":
+		case key.Matches(msg, keymap.OpenScreen.Binding):
 			// unfocus sidebar
 			if m.focused && m.search.Focused() {
 				m.search.Blur()
@@ -142,13 +198,13 @@ func (m *Menu) Update(msg tea.Msg) tea.Cmd {
 				m.focused = false
 				m.selectedItem.screen.SetFocused(true)
 			}
-		case "ctrl+k":
+		case key.Matches(msg, keymap.FocusSidebar.Binding):
 			// Return to sidebar
 			if !m.focused && !m.search.Focused() {
 				m.focused = true
 				m.selectedItem.screen.SetFocused(false)
 			}
-		case "q":
+		case key.Matches(msg, keymap.Quit.Binding):
 			if m.focused && !m.search.Focused() {
 				cmds = append(cmds, tea.Quit)
 			}
@@ -164,14 +220,46 @@ func (m *Menu) Update(msg tea.Msg) tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
-func (m *Menu) View() string {
-	bgColor := lipgloss.Color("#222222")
+// menuItemsTop is the line of the first item: below the padding, the search
+// bar and the blank line that follows it.
+const menuItemsTop = 3
+
+// mouse handles a mouse event at window coordinates. A click on an item
+// shows its screen and one on the search bar focuses it; events right of the
+// sidebar go to the shown screen, which gets the focus when clicked. While
+// its scrollbar is dragged the screen gets every event, wherever it is.
+func (m *Menu) mouse(msg tea.MouseMsg, filteredItems []*MenuItem) tea.Cmd {
+	width := m.GetWidth()
+	press := msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft
+	if m.selectedItem != nil && (msg.X >= width || m.selectedItem.screen.Dragging()) {
+		if press && m.focused {
+			m.search.Blur()
+			m.focused = false
+			m.selectedItem.screen.SetFocused(true)
+		}
+		msg.X -= width
+		return m.selectedItem.screen.Mouse(msg)
+	}
 
-	menuItemStyle := lipgloss.NewStyle().
-		Background(bgColor).Width(m.width)
+	if !press {
+		return nil
+	}
+	if msg.Y == menuItemsTop-2 {
+		m.FocusSidebar()
+		return m.search.Focus()
+	}
+	if row := msg.Y - menuItemsTop; row >= 0 && row < len(filteredItems) {
+		m.Select(filteredItems[row].id)
+	}
+	return nil
+}
 
-	menuStyle := lipgloss.NewStyle().
-		Background(bgColor).Padding(1).Width(m.width)
+func (m *Menu) View() string {
+	menuItemStyle := m.theme.TextAccent().
+		Background(m.theme.Surface()).Width(m.width)
+
+	menuStyle := m.theme.Base().
+		Background(m.theme.Surface()).Padding(1).Width(m.width)
 
 	var content string
 
@@ -219,6 +307,7 @@ func (m *Menu) Screen() string {
 func NewMenuItem(id string, title string, screen *screens.Screen) *MenuItem {
 	return &MenuItem{
 		id:     id,
+		name:   title,
 		title:  title,
 		screen: screen,
 	}
@@ -248,21 +337,22 @@ func (m *Menu) formatItem(display string, active bool) string {
 	content := title + spacing + info
 	if active {
 		if m.focused {
-			return lipgloss.NewStyle().
-				Foreground(lipgloss.Color("205")).
-				Background(lipgloss.Color("#333333")).
+			return m.theme.Base().
+				Foreground(m.theme.Highlight()).
+				Background(m.theme.Selection()).
 				Bold(true).
 				PaddingRight(2).
 				Render("▶ " + content)
 		}
-		return lipgloss.NewStyle().
-			Foreground(lipgloss.Color("244")).
-			Background(lipgloss.Color("#333333")).
+		return m.theme.Base().
+			Foreground(m.theme.Muted()).
+			Background(m.theme.Selection()).
 			Bold(true).
 			PaddingRight(2).
 			Render("▶ " + content)
 	}
-	return lipgloss.NewStyle().
+	return m.theme.TextAccent().
+		Background(m.theme.Surface()).
 		PaddingLeft(2).
 		PaddingRight(2).
 		Render(content)
@@ -282,6 +372,18 @@ func (mi *MenuItem) Update(msg tea.Msg) tea.Cmd {
 	return nil
 }
 
+func (mi *MenuItem) ID() string {
+	return mi.id
+}
+
+func (mi *MenuItem) Name() string {
+	return mi.name
+}
+
+func (mi *MenuItem) Screen() *screens.Screen {
+	return mi.screen
+}
+
 func (mi *MenuItem) View() string {
 	content := mi.title
 	return content
@@ -1,7 +1,12 @@
 package messages
 
 import (
+	"encoding/json"
+	"p1/pkg/models"
 	"p1/pkg/states"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
 )
 
 type RerenderMessage struct {
@@ -12,6 +17,20 @@ type RerenderMessage struct {
 
 type SyncMsg *states.ClientState
 
+// SendMsg asks the root model to send Message to the server with the ID in
+// Origin, or to every connected server if Origin is empty.
+type SendMsg struct {
+	Origin  string
+	Message *Message
+}
+
+// Send returns a command that hands msg to the client.
+func Send(origin string, msg *Message) tea.Cmd {
+	return func() tea.Msg {
+		return SendMsg{Origin: origin, Message: msg}
+	}
+}
+
 type MessageType string
 
 const (
@@ -29,10 +48,98 @@ const (
 
 	TypeMetrics   MessageType = "METRICS"
 	TypeBroadcast MessageType = "BROADCAST"
+
+	// TypeSession is sent by the server right after the handshake and tells
+	// the client whether its session was resumed or needs a full resync.
+	TypeSession MessageType = "SESSION"
+
+	// TypeClientJoined is broadcast when a client starts a new session.
+	TypeClientJoined MessageType = "CLIENT_JOINED"
+	// TypeListClients lists the clients attached to the server. It is also
+	// pushed to everyone whenever a client comes or goes.
+	TypeListClients MessageType = "LIST_CLIENTS"
+
+	// TypeFollowLogs starts (payload true) or stops (false) the stream of
+	// the server's log entries to the session.
+	TypeFollowLogs MessageType = "FOLLOW_LOGS"
+	// TypeLogs carries log entries of the server, the recent ones right
+	// after following starts and new ones as they are written.
+	TypeLogs MessageType = "LOGS"
+
+	// TypeConfigReloaded is broadcast when the server applied a change of
+	// its configuration.
+	TypeConfigReloaded MessageType = "CONFIG_RELOADED"
+
+	// TypePeerSync carries a server's own registry and health to a federated peer.
+	TypePeerSync MessageType = "PEER_SYNC"
 )
 
 type Message struct {
 	Type    MessageType `json:"type"`
 	Payload interface{} `json:"payload"`
 	Sender  string      `json:"sender"`
+	Seq     uint64      `json:"seq,omitempty"` // Per-session sequence number, 0 for control messages
+}
+
+// ConnectServerMsg asks the root model to connect to Server, replacing the
+// connection to a server with the same ID.
+type ConnectServerMsg struct {
+	Server *models.Server
+}
+
+// DisconnectServerMsg asks the root model to drop the connection to the server with ID.
+type DisconnectServerMsg struct {
+	ID string
+}
+
+// SelectServerMsg limits the other screens to the server with ID, an empty
+// ID shows every server.
+type SelectServerMsg struct {
+	ID string
+}
+
+// SessionInfo is the payload of a TypeSession message.
+type SessionInfo struct {
+	SessionID string `json:"session_id"`
+	Token     string `json:"token"`   // Secret sent back with the ID to resume the session
+	Seq       uint64 `json:"seq"`     // Latest sequence number the server has issued
+	Resumed   bool   `json:"resumed"` // Missed messages follow and will be replayed
+	Resync    bool   `json:"resync"`  // The gap could not be replayed, state must be re-requested
+	Version   string `json:"version"` // Version of the server software
+}
+
+// ClientInfo is the payload of a TypeClientJoined message and describes
+// each client in a TypeListClients message.
+type ClientInfo struct {
+	SessionID string `json:"session_id"`
+	ClientID  string `json:"client_id"`
+	Name      string `json:"name"` // Chosen by the user, may be empty
+}
+
+// Chat is the payload of a TypeBroadcast message. Clients send the ID and
+// the text, the server fills in the sender and the time before relaying it.
+type Chat struct {
+	ID   string     `json:"id"` // Identifies the message when it arrives through several servers
+	Text string     `json:"text"`
+	From ClientInfo `json:"from"`
+	At   time.Time  `json:"at"`
+}
+
+// ConfigReload is the payload of a TypeConfigReloaded message. Settings are
+// named by their key in the config file.
+type ConfigReload struct {
+	Applied []string  `json:"applied"` // Settings now in effect
+	Restart []string  `json:"restart"` // Changed settings that need a restart
+	At      time.Time `json:"at"`
+}
+
+// DecodePayload converts the generic payload into v. Payloads arrive as
+// map[string]interface{} after unmarshalling, so they are round-tripped
+// through JSON to end up in the concrete type.
+func (m *Message) DecodePayload(v any) error {
+	raw, err := json.Marshal(m.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
 }
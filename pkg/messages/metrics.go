@@ -0,0 +1,57 @@
+package messages
+
+import "time"
+
+type Memory struct {
+	MemTotal uint64 `json:"mem_total"` // Total memory in bytes
+	MemFree  uint64 `json:"mem_free"`  // Free memory in bytes
+}
+
+type Network struct {
+	ID           string `json:"id"`            // Interface identifier
+	RxBytes      uint64 `json:"rx_bytes"`      // Received bytes
+	RxPackets    uint64 `json:"rx_packets"`    // Received packets
+	RxErrors     uint64 `json:"rx_errors"`     // Received errors
+	RxDropped    uint64 `json:"rx_dropped"`    // Received dropped packets
+	RxFifo       uint64 `json:"rx_fifo"`       // Received FIFO errors
+	RxFrame      uint64 `json:"rx_frame"`      // Received frame errors
+	RxCompressed uint64 `json:"rx_compressed"` // Received compressed packets
+	RxMulticast  uint64 `json:"rx_multicast"`  // Received multicast packets
+	TxBytes      uint64 `json:"tx_bytes"`      // Transmitted bytes
+	TxPackets    uint64 `json:"tx_packets"`    // Transmitted packets
+	TxErrors     uint64 `json:"tx_errors"`     // Transmitted errors
+	TxDropped    uint64 `json:"tx_dropped"`    // Transmitted dropped packets
+	TxFifo       uint64 `json:"tx_fifo"`       // Transmitted FIFO errors
+	TxFrame      uint64 `json:"tx_frame"`      // Transmitted frame errors
+	TxCompressed uint64 `json:"tx_compressed"` // Transmitted compressed packets
+	TxMulticast  uint64 `json:"tx_multicast"`  // Transmitted multicast packets
+}
+
+type Storage struct {
+	Disks []Disk `json:"disks"` // List of disks
+}
+
+type Disk struct {
+	MountPoint string `json:"mount_point"` // Mount point of the disk
+	Total      uint64 `json:"total"`       // Total space in bytes
+	Used       uint64 `json:"used"`        // Used space in bytes
+}
+
+type CPU struct {
+	User   uint64 `json:"user"`            // User CPU time
+	System uint64 `json:"system"`          // System CPU time
+	Idle   uint64 `json:"idle"`            // Idle CPU time
+	Total  uint64 `json:"total"`           // Total CPU time
+	Cores  []CPU  `json:"cores,omitempty"` // Per-core CPU times
+}
+
+// ServerMetrics is the payload of a TypeMetrics message. It carries the raw
+// counters of the host, usage and rates are derived from two samples.
+type ServerMetrics struct {
+	CPU        *CPU      `json:"cpu"`        // CPU metrics
+	Memory     *Memory   `json:"memory"`     // Memory metrics
+	Storage    *Storage  `json:"storage"`    // Storage metrics
+	Network    *Network  `json:"network"`    // Network metrics, summed over all interfaces but loopback
+	Interfaces []Network `json:"interfaces"` // Network metrics per interface
+	At         time.Time `json:"at"`         // When the sample was taken, rates are computed from it
+}
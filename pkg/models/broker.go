@@ -9,9 +9,10 @@ import (
 )
 
 type Broker struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Origin string `json:"origin,omitempty"` // Server the entry was received from
 }
 
 func NewBroker(name string, url string) *Broker {
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Participant is a client attached to a server, as shown in the presence list.
+type Participant struct {
+	SessionID string
+	ClientID  string
+	Name      string
+	Origin    string // ID of the server the client is attached to
+	Self      bool   // The participant is this client
+}
+
+// DisplayName is the participant's name, or a short form of the client ID
+// for clients without one.
+func (p Participant) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	if len(p.ClientID) > 8 {
+		return p.ClientID[:8]
+	}
+	return p.ClientID
+}
+
+// ChatMessage is a broadcast one client sent to everyone on a server.
+type ChatMessage struct {
+	ID     string
+	Text   string
+	From   Participant
+	At     time.Time
+	Origin string // ID of the server that relayed the message
+}
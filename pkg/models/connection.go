@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"p1/pkg/tui/theme"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ConnectionStatus describes the health of the client's connection to the server.
+type ConnectionStatus struct {
+	Connected   bool          `json:"connected"`
+	Latency     time.Duration `json:"latency"`      // Round-trip time of the last ping
+	Reconnects  int           `json:"reconnects"`   // Successful reconnects since start
+	LastMessage time.Time     `json:"last_message"` // When anything was last received
+	Version     string        `json:"version"`      // Version the server reported
+}
+
+// ConnectionStatusMsg is sent periodically by the root model so the footer
+// can display the current connection health.
+type ConnectionStatusMsg ConnectionStatus
+
+// ServerStatusMsg carries the connection health of every server, by server ID.
+type ServerStatusMsg map[string]ConnectionStatus
+
+// LastMessageAge returns how long ago the last message was received.
+func (c *ConnectionStatus) LastMessageAge() time.Duration {
+	if c.LastMessage.IsZero() {
+		return 0
+	}
+	return time.Since(c.LastMessage)
+}
+
+func (c *ConnectionStatus) Update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case ConnectionStatusMsg:
+		*c = ConnectionStatus(msg)
+	}
+	return nil
+}
+
+func (c *ConnectionStatus) View(t *theme.Theme) string {
+	style := t.Base().Background(t.Panel())
+	if !c.Connected {
+		return style.Foreground(t.Error()).Render("● offline")
+	}
+	color := t.Success()
+	if c.Latency > 250*time.Millisecond || c.LastMessageAge() > 30*time.Second {
+		color = t.Warning()
+	}
+	content := fmt.Sprintf("● %dms", c.Latency.Milliseconds())
+	if c.Reconnects > 0 {
+		content += fmt.Sprintf(" ↻%d", c.Reconnects)
+	}
+	content += fmt.Sprintf(" %ds ago", int(c.LastMessageAge().Seconds()))
+	return style.Foreground(color).Render(content)
+}
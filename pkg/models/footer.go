@@ -1,8 +1,8 @@
 package models
 
 import (
-	"fmt"
 	"p1/pkg/interfaces"
+	"p1/pkg/keymap"
 	"p1/pkg/tui/theme"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -10,11 +10,11 @@ import (
 )
 
 type Footer struct {
-	Commands []*interfaces.FooterCommand
-	theme    *theme.Theme
-	error    *VisibleError
-	width    int
-	helper   string
+	Commands   []*interfaces.FooterCommand
+	theme      *theme.Theme
+	width      int
+	helper     string
+	connection *ConnectionStatus
 }
 
 type FooterUpdate struct {
@@ -22,12 +22,17 @@ type FooterUpdate struct {
 	Commands []*interfaces.FooterCommand
 }
 
-var (
-	BaseCommands = []*interfaces.FooterCommand{
-		{Key: "q", Value: "Quit"},
-		{Key: "ctrl+k", Value: "Focus Sidebar"},
+// BaseCommands lists quitting and the global bindings, which the footer
+// shows on every screen.
+func BaseCommands() []*interfaces.FooterCommand {
+	cmds := []*interfaces.FooterCommand{}
+	for _, b := range append([]*keymap.Binding{keymap.Quit}, keymap.Default.InScope(keymap.SCOPE_GLOBAL)...) {
+		if b.Enabled() {
+			cmds = append(cmds, &interfaces.FooterCommand{Key: b.Key(), Value: b.Title()})
+		}
 	}
-)
+	return cmds
+}
 
 func NewFooter(theme *theme.Theme, commands []*interfaces.FooterCommand) *Footer {
 	return &Footer{
@@ -45,10 +50,15 @@ func (f *Footer) SetCommands(cmds []*interfaces.FooterCommand) {
 
 func (f *Footer) Update(msg tea.Msg) tea.Cmd {
 	switch msg := msg.(type) {
-	case VisibleError:
-		f.error = &msg
+	case ConnectionStatusMsg:
+		if f.connection == nil {
+			f.connection = &ConnectionStatus{}
+		}
+		return f.connection.Update(msg)
 	case InternalWindowSizeMsg:
 		f.width = msg.Width - msg.MenuWidth
+	case theme.ChangedMsg:
+		f.theme = &msg.Theme
 	case FooterUpdate:
 		f.helper = msg.Content
 		f.Commands = msg.Commands
@@ -57,16 +67,16 @@ func (f *Footer) Update(msg tea.Msg) tea.Cmd {
 }
 
 func (f *Footer) ResetCommands() {
-	f.Commands = BaseCommands
+	f.Commands = BaseCommands()
 }
 
 func (f *Footer) View() string {
-	bold := f.theme.TextAccent().Background(lipgloss.AdaptiveColor{Dark: "#000000", Light: "#FFFFFF"}).Bold(true).Render
-	base := f.theme.TextAccent().Background(lipgloss.AdaptiveColor{Dark: "#000000", Light: "#FFFFFF"}).Render
+	bold := f.theme.TextAccent().Background(f.theme.Panel()).Bold(true).Render
+	base := f.theme.TextAccent().Background(f.theme.Panel()).Render
 
 	table := f.theme.Base().
 		Width(f.width - 1).
-		Background(lipgloss.AdaptiveColor{Dark: "#000000", Light: "#FFFFFF"}).
+		Background(f.theme.Panel()).
 		Padding(1).
 		PaddingLeft(2).
 		PaddingRight(2).
@@ -74,26 +84,27 @@ func (f *Footer) View() string {
 
 	lines := []string{}
 
-	content := lipgloss.NewStyle().
-		Background(lipgloss.AdaptiveColor{Dark: "#000000", Light: "#FFFFFF"}).
-		Foreground(lipgloss.Color("#777777")).Render(f.helper + " ")
+	content := f.theme.Base().
+		Background(f.theme.Panel()).
+		Foreground(f.theme.Muted()).Render(f.helper + " ")
 
-	if f.error != nil {
-		errorString := fmt.Sprintf("Error: %s ", f.error.Message)
+	if f.connection != nil {
 		content = lipgloss.JoinHorizontal(
 			lipgloss.Left,
-			f.theme.TextError().Render(errorString),
+			f.connection.View(f.theme),
+			base(" "),
 			content,
 		)
 	}
 
 	lines = append(lines, content)
 
-	mergedCommands := BaseCommands
+	baseCommands := BaseCommands()
+	mergedCommands := baseCommands
 	for _, cmd := range f.Commands {
 		// only add non existing keys
 		found := false
-		for _, cmd2 := range BaseCommands {
+		for _, cmd2 := range baseCommands {
 			if cmd.Key == cmd2.Key {
 				found = true
 				break
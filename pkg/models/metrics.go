@@ -8,10 +8,20 @@ import (
 )
 
 type Metrics struct {
-	CPU     float64 `json:"cpu"`
-	RAM     float64 `json:"ram"`
-	Disk    float64 `json:"disk"`
-	Network float64 `json:"network"`
+	CPU        float64         `json:"cpu"`
+	RAM        float64         `json:"ram"`
+	Disk       float64         `json:"disk"`
+	Network    float64         `json:"network"`          // Throughput in KiB/s
+	Cores      []float64       `json:"cores"`            // Usage per CPU core in percent
+	Interfaces []InterfaceRate `json:"interfaces"`       // Throughput per network interface
+	Origin     string          `json:"origin,omitempty"` // Server the sample was taken on
+}
+
+// InterfaceRate is the throughput of a single network interface in KiB/s.
+type InterfaceRate struct {
+	ID string  `json:"id"`
+	Rx float64 `json:"rx"`
+	Tx float64 `json:"tx"`
 }
 
 func (m *Metrics) Update(msg tea.Msg) tea.Cmd {
@@ -21,6 +31,6 @@ func (m *Metrics) Update(msg tea.Msg) tea.Cmd {
 
 func (m *Metrics) View() string {
 	mainStyle := lipgloss.NewStyle().Padding(2)
-	content := fmt.Sprintf("CPU: %.2f%%\nRAM: %.2f%%\nDisk: %.2f%%\nNetwork: %.2f%%", m.CPU, m.RAM, m.Disk, m.Network)
+	content := fmt.Sprintf("CPU: %.2f%%\nRAM: %.2f%%\nDisk: %.2f%%\nNetwork: %.2f KiB/s", m.CPU, m.RAM, m.Disk, m.Network)
 	return mainStyle.Render(content)
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"p1/pkg/tui/theme"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeveritySuccess Severity = "success"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Icon is the symbol notifications of the severity are marked with.
+func (s Severity) Icon() string {
+	switch s {
+	case SeveritySuccess:
+		return "✓"
+	case SeverityWarning:
+		return "!"
+	case SeverityError:
+		return "✗"
+	default:
+		return "•"
+	}
+}
+
+// Color is the theme color notifications of the severity are drawn in.
+func (s Severity) Color(t *theme.Theme) lipgloss.TerminalColor {
+	switch s {
+	case SeveritySuccess:
+		return t.Success()
+	case SeverityWarning:
+		return t.Warning()
+	case SeverityError:
+		return t.Error()
+	default:
+		return t.Accent()
+	}
+}
+
+// Notification is something the user should know about, like a server event
+// or a failed action. It is shown as a toast and kept in the history.
+type Notification struct {
+	Severity Severity
+	Message  string
+	Origin   string // ID of the server it came from, empty for local ones
+	At       time.Time
+}
+
+func NewNotification(severity Severity, message string) Notification {
+	return Notification{
+		Severity: severity,
+		Message:  message,
+		At:       time.Now(),
+	}
+}
+
+// Notify returns a command that raises a notification.
+func Notify(severity Severity, message string) tea.Cmd {
+	return func() tea.Msg {
+		return NewNotification(severity, message)
+	}
+}
@@ -3,14 +3,28 @@ package models
 import (
 	"encoding/json"
 	"p1/pkg/api"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type Project struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Metadata    map[string]string `json:"metadata"`
+	Services    []string          `json:"services"` // IDs of the linked services
+	Events      []ProjectEvent    `json:"events"`   // Most recent changes, oldest first
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Origin      string            `json:"origin,omitempty"` // Server the entry was received from
+}
+
+// ProjectEvent is a change to a project, recorded by the server.
+type ProjectEvent struct {
+	At      time.Time `json:"at"`
+	Message string    `json:"message"`
 }
 
 func NewProject(id string, name string) *Project {
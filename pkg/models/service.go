@@ -0,0 +1,30 @@
+package models
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Service struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Endpoint    string            `json:"endpoint"`
+	Description string            `json:"description"`
+	Metadata    map[string]string `json:"metadata"`
+	Health      string            `json:"health,omitempty"`    // up, down or unknown
+	Owner       string            `json:"owner,omitempty"`     // Federated server that owns the entry
+	Conflicts   []string          `json:"conflicts,omitempty"` // Other servers claiming the same ID
+	Origin      string            `json:"origin,omitempty"`    // Server the service was received from
+}
+
+func (p *Service) Update(msg tea.Msg) tea.Cmd {
+	// Handle updates specific to Service
+	return nil
+}
+
+func (p *Service) View() string {
+	mainStyle := lipgloss.NewStyle()
+	return mainStyle.Render(fmt.Sprintf("%s (%s)", p.Name, p.Endpoint))
+}
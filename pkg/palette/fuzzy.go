@@ -0,0 +1,98 @@
+package palette
+
+import (
+	"math"
+	"unicode"
+)
+
+const (
+	SCORE_MATCH       = 1
+	SCORE_CONSECUTIVE = 5
+	SCORE_WORD_START  = 8
+	PENALTY_GAP       = 1 // Per skipped character before the first match, capped at MAX_LEADING_GAP
+	MAX_LEADING_GAP   = 5
+)
+
+const noMatch = math.MinInt
+
+// Match reports whether every character of pattern appears in text in the
+// same order, ignoring case. The score rewards runs of consecutive characters
+// and matches at the start of words, matched holds the rune positions in
+// text for highlighting.
+func Match(pattern, text string) (score int, matched []int, ok bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+	runes := []rune(text)
+	wanted := []rune(pattern)
+	if len(wanted) > len(runes) {
+		return 0, nil, false
+	}
+
+	// best[j][i] is the highest score with wanted[j] matched at runes[i], or
+	// noMatch. from[j][i] remembers where wanted[j-1] was matched for it.
+	best := make([][]int, len(wanted))
+	from := make([][]int, len(wanted))
+	for j := range wanted {
+		best[j] = make([]int, len(runes))
+		from[j] = make([]int, len(runes))
+		for i, r := range runes {
+			best[j][i] = noMatch
+			if !equalFold(r, wanted[j]) {
+				continue
+			}
+			bonus := SCORE_MATCH
+			if isWordStart(runes, i) {
+				bonus += SCORE_WORD_START
+			}
+			if j == 0 {
+				best[j][i] = bonus - min(i, MAX_LEADING_GAP)*PENALTY_GAP
+				continue
+			}
+			for k := 0; k < i; k++ {
+				if best[j-1][k] == noMatch {
+					continue
+				}
+				candidate := best[j-1][k] + bonus
+				if k == i-1 {
+					candidate += SCORE_CONSECUTIVE
+				}
+				if candidate > best[j][i] {
+					best[j][i] = candidate
+					from[j][i] = k
+				}
+			}
+		}
+	}
+
+	last := len(wanted) - 1
+	end := -1
+	for i, score := range best[last] {
+		if score != noMatch && (end < 0 || score > best[last][end]) {
+			end = i
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	matched = make([]int, len(wanted))
+	for j, i := last, end; j >= 0; j-- {
+		matched[j] = i
+		i = from[j][i]
+	}
+	return best[last][end], matched, true
+}
+
+func isWordStart(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := runes[i-1]
+	return unicode.IsSpace(prev) || unicode.IsPunct(prev) ||
+		(unicode.IsLower(prev) && unicode.IsUpper(runes[i]))
+}
+
+func equalFold(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+}
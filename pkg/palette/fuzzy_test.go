@@ -0,0 +1,67 @@
+package palette
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		ok      bool
+		matched []int
+	}{
+		{pattern: "", text: "anything", ok: true},
+		{pattern: "ns", text: "New Service", ok: true, matched: []int{0, 4}},
+		{pattern: "NS", text: "new service", ok: true, matched: []int{0, 4}},
+		{pattern: "serv", text: "New Service", ok: true, matched: []int{4, 5, 6, 7}},
+		{pattern: "gs", text: "goToServers", ok: true, matched: []int{0, 4}},
+		{pattern: "sn", text: "New Service", ok: false},
+		{pattern: "longer than text", text: "short", ok: false},
+		{pattern: "x", text: "New Service", ok: false},
+		{pattern: "È", text: "Thème", ok: true, matched: []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" in "+tt.text, func(t *testing.T) {
+			_, matched, ok := Match(tt.pattern, tt.text)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !slices.Equal(matched, tt.matched) {
+				t.Errorf("matched = %v, want %v", matched, tt.matched)
+			}
+		})
+	}
+}
+
+// TestMatchRanking checks that better matches score higher than worse ones
+// for the same pattern.
+func TestMatchRanking(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		better  string
+		worse   string
+	}{
+		{name: "word starts", pattern: "ns", better: "New Service", worse: "transport"},
+		{name: "consecutive", pattern: "ice", better: "Service", worse: "pick one"},
+		{name: "earlier", pattern: "theme", better: "Theme: dark", worse: "Switch theme"},
+		{name: "camel case", pattern: "ss", better: "selectServer", worse: "classes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			better, _, ok := Match(tt.pattern, tt.better)
+			if !ok {
+				t.Fatalf("%q does not match %q", tt.pattern, tt.better)
+			}
+			worse, _, ok := Match(tt.pattern, tt.worse)
+			if !ok {
+				t.Fatalf("%q does not match %q", tt.pattern, tt.worse)
+			}
+			if better <= worse {
+				t.Errorf("%q scores %d for %q and %d for %q", tt.pattern, better, tt.better, worse, tt.worse)
+			}
+		})
+	}
+}
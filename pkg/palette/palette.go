@@ -0,0 +1,219 @@
+package palette
+
+import (
+	"slices"
+	"strings"
+
+	"p1/pkg/keymap"
+	"p1/pkg/tui/theme"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	PALETTE_WIDTH  = 64
+	VISIBLE_ROWS   = 10
+	RECENT_ENTRIES = 10
+	SCORE_RECENT   = 4 // Added to a match per place an entry is away from the end of the recent list
+)
+
+var (
+	closeKey = keymap.Register("palette.close", keymap.SCOPE_PALETTE, "Close", "esc", "ctrl+c")
+	upKey    = keymap.Register("palette.up", keymap.SCOPE_PALETTE, "Up", "up", "ctrl+k")
+	downKey  = keymap.Register("palette.down", keymap.SCOPE_PALETTE, "Down", "down", "ctrl+j", "ctrl+n")
+	runKey   = keymap.Register("palette.run", keymap.SCOPE_PALETTE, "Run", "enter")
+)
+
+// Entry is one action listed in the palette.
+type Entry struct {
+	ID    string // Stable identifier used to remember recently run entries
+	Title string
+	Key   string // Key hint shown next to the title, empty if the action has no key
+	Run   func() tea.Cmd
+}
+
+type result struct {
+	entry   *Entry
+	score   int
+	matched []int
+}
+
+// Palette is a searchable list of every action the TUI offers. It is opened
+// over the current screen and closes again once an entry is run.
+type Palette struct {
+	theme    theme.Theme
+	input    textinput.Model
+	entries  []*Entry
+	results  []result
+	selected int
+	recent   []string // Entry IDs, most recently run first
+	visible  bool
+	width    int
+	height   int
+}
+
+func New(t theme.Theme) *Palette {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Placeholder = "Type a command"
+
+	p := &Palette{input: ti}
+	p.SetTheme(t)
+	return p
+}
+
+func (p *Palette) SetTheme(t theme.Theme) {
+	p.theme = t
+	p.input.PromptStyle = t.TextHighlight()
+	p.input.TextStyle = t.TextAccent()
+	p.input.PlaceholderStyle = t.TextBody()
+}
+
+// Open shows the palette with entries and an empty query.
+func (p *Palette) Open(entries []*Entry) tea.Cmd {
+	p.entries = entries
+	p.input.Reset()
+	p.visible = true
+	p.filter()
+	return p.input.Focus()
+}
+
+func (p *Palette) Close() {
+	p.visible = false
+	p.input.Blur()
+}
+
+func (p *Palette) Visible() bool {
+	return p.visible
+}
+
+func (p *Palette) SetSize(width, height int) {
+	p.width = width
+	p.height = height
+}
+
+func (p *Palette) Update(msg tea.Msg) tea.Cmd {
+	if !p.visible {
+		return nil
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, closeKey.Binding, keymap.Palette.Binding):
+			p.Close()
+			return nil
+		case key.Matches(msg, upKey.Binding):
+			p.selected = max(0, p.selected-1)
+			return nil
+		case key.Matches(msg, downKey.Binding):
+			p.selected = min(len(p.results)-1, p.selected+1)
+			return nil
+		case key.Matches(msg, runKey.Binding):
+			return p.run()
+		}
+	}
+
+	query := p.input.Value()
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	if p.input.Value() != query {
+		p.filter()
+	}
+	return cmd
+}
+
+func (p *Palette) run() tea.Cmd {
+	if p.selected < 0 || p.selected >= len(p.results) {
+		return nil
+	}
+	entry := p.results[p.selected].entry
+	p.Close()
+
+	p.recent = slices.DeleteFunc(p.recent, func(id string) bool { return id == entry.ID })
+	p.recent = slices.Insert(p.recent, 0, entry.ID)
+	if len(p.recent) > RECENT_ENTRIES {
+		p.recent = p.recent[:RECENT_ENTRIES]
+	}
+
+	if entry.Run == nil {
+		return nil
+	}
+	return entry.Run()
+}
+
+// filter matches the entries against the query. Recently run entries rank
+// first, and among equally good matches the order the entries were given in
+// is kept.
+func (p *Palette) filter() {
+	query := strings.TrimSpace(p.input.Value())
+	p.results = p.results[:0]
+	for _, entry := range p.entries {
+		score, matched, ok := Match(query, entry.Title)
+		if !ok {
+			continue
+		}
+		if i := slices.Index(p.recent, entry.ID); i >= 0 {
+			score += (len(p.recent) - i) * SCORE_RECENT
+		}
+		p.results = append(p.results, result{entry: entry, score: score, matched: matched})
+	}
+	slices.SortStableFunc(p.results, func(a, b result) int {
+		return b.score - a.score
+	})
+	p.selected = 0
+}
+
+func (p *Palette) View() string {
+	if !p.visible {
+		return ""
+	}
+	width := min(PALETTE_WIDTH, max(20, p.width-4))
+	rowWidth := width - 2
+
+	rows := []string{p.input.View(), ""}
+
+	// Keep the selected row in view when there are more results than rows
+	offset := max(0, p.selected-VISIBLE_ROWS+1)
+	end := min(len(p.results), offset+VISIBLE_ROWS)
+	for i := offset; i < end; i++ {
+		rows = append(rows, p.viewResult(p.results[i], i == p.selected, rowWidth))
+	}
+	if len(p.results) == 0 {
+		rows = append(rows, p.theme.TextBody().Render("No matching commands"))
+	}
+
+	box := p.theme.Base().
+		Width(width).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(p.theme.Highlight()).
+		Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+
+	return lipgloss.Place(p.width, p.height, lipgloss.Center, lipgloss.Top,
+		lipgloss.NewStyle().MarginTop(2).Render(box))
+}
+
+func (p *Palette) viewResult(r result, selected bool, width int) string {
+	text := p.theme.TextAccent()
+	match := p.theme.TextHighlight().Bold(true)
+	hint := p.theme.TextBody()
+	if selected {
+		text = text.Background(p.theme.Border())
+		match = match.Background(p.theme.Border())
+		hint = hint.Background(p.theme.Border())
+	}
+
+	var title strings.Builder
+	for i, c := range []rune(r.entry.Title) {
+		if slices.Contains(r.matched, i) {
+			title.WriteString(match.Render(string(c)))
+		} else {
+			title.WriteString(text.Render(string(c)))
+		}
+	}
+
+	gap := max(1, width-lipgloss.Width(title.String())-lipgloss.Width(r.entry.Key))
+	return title.String() + text.Render(strings.Repeat(" ", gap)) + hint.Render(r.entry.Key)
+}
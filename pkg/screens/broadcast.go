@@ -0,0 +1,217 @@
+package screens
+
+import (
+	"fmt"
+	"p1/pkg/keymap"
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+	"p1/pkg/tui/theme"
+	"p1/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+)
+
+const PRESENCE_WIDTH = 24 // Width of the list of connected clients
+
+var (
+	broadcastScope = keymap.AddScope("broadcast", "Broadcast", keymap.SCOPE_LIST)
+
+	sendBroadcastKey = keymap.Register("broadcast.send", broadcastScope.ID, "Send", "enter")
+)
+
+// Broadcast Screen
+type BroadcastScreen struct {
+	theme    theme.Theme
+	chat     []*models.ChatMessage
+	clients  []*models.Participant
+	servers  []*models.Server
+	input    textinput.Model
+	focused  bool
+	lastSeen time.Time // When the newest message the user has seen was sent
+	width    int
+	height   int
+}
+
+func NewBroadcastScreen(renderer *lipgloss.Renderer) *Screen {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "Message everyone on the servers"
+	input.CharLimit = 500
+
+	screen := &BroadcastScreen{
+		theme:   theme.BasicTheme(renderer, nil),
+		chat:    []*models.ChatMessage{},
+		clients: []*models.Participant{},
+		servers: []*models.Server{},
+		input:   input,
+	}
+	screen.setTheme(screen.theme)
+	return New(renderer, screen)
+}
+
+func (bs *BroadcastScreen) Actions() []*keymap.Binding {
+	return []*keymap.Binding{sendBroadcastKey}
+}
+
+// SetFocused focuses the input with the screen, everything shown while it
+// has the focus counts as read.
+func (bs *BroadcastScreen) SetFocused(focused bool) {
+	bs.focused = focused
+	if focused {
+		bs.input.Focus()
+		bs.markRead()
+	} else {
+		bs.input.Blur()
+	}
+}
+
+func (bs *BroadcastScreen) Update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case models.InternalWindowSizeMsg:
+		bs.width = max(0, msg.Width-msg.MenuWidth-8)
+		bs.height = max(0, msg.Height-msg.FooterHeight-4)
+		bs.input.Width = max(10, bs.width-PRESENCE_WIDTH-6)
+	case theme.ChangedMsg:
+		bs.setTheme(msg.Theme)
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		bs.chat = state.Chat
+		bs.clients = state.Clients
+		bs.servers = state.Servers
+		if bs.focused {
+			bs.markRead()
+		}
+	case tea.KeyMsg:
+		if key.Matches(msg, sendBroadcastKey.Binding) {
+			return bs.send()
+		}
+		input, cmd := bs.input.Update(msg)
+		bs.input = input
+		return cmd
+	}
+	return nil
+}
+
+// send broadcasts the text of the input through every connected server.
+func (bs *BroadcastScreen) send() tea.Cmd {
+	text := strings.TrimSpace(bs.input.Value())
+	if text == "" {
+		return nil
+	}
+	bs.input.Reset()
+	return messages.Send("", &messages.Message{
+		Type:    messages.TypeBroadcast,
+		Payload: messages.Chat{ID: uuid.NewString(), Text: text},
+	})
+}
+
+func (bs *BroadcastScreen) markRead() {
+	if len(bs.chat) > 0 {
+		bs.lastSeen = bs.chat[len(bs.chat)-1].At
+	}
+}
+
+// unread counts the messages from others that arrived while the screen did
+// not have the focus.
+func (bs *BroadcastScreen) unread() int {
+	count := 0
+	for _, message := range bs.chat {
+		if message.At.After(bs.lastSeen) && !message.From.Self {
+			count++
+		}
+	}
+	return count
+}
+
+func (bs *BroadcastScreen) View() string {
+	title := bs.theme.TextAccent().Bold(true).Render("Broadcast")
+	scrollbackWidth := max(20, bs.width-PRESENCE_WIDTH-2)
+	scrollbackHeight := max(1, bs.height-4)
+
+	scrollback := lipgloss.NewStyle().
+		Width(scrollbackWidth).
+		Height(scrollbackHeight).
+		Render(bs.viewScrollback(scrollbackWidth, scrollbackHeight))
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		"",
+		lipgloss.JoinHorizontal(lipgloss.Top, scrollback, "  ", bs.viewPresence()),
+		"",
+		bs.input.View(),
+	)
+}
+
+// viewScrollback renders the newest messages that fit into height lines.
+func (bs *BroadcastScreen) viewScrollback(width int, height int) string {
+	if len(bs.chat) == 0 {
+		return bs.theme.TextBody().Render("No broadcasts yet. Whatever you send here reaches every client on the connected servers.")
+	}
+
+	lines := []string{}
+	for _, message := range bs.chat {
+		name := bs.theme.TextAccent().Bold(true)
+		if message.From.Self {
+			name = bs.theme.TextHighlight().Bold(true)
+		}
+		header := bs.theme.TextBody().Render(message.At.Local().Format(time.TimeOnly)) + " " + name.Render(message.From.DisplayName())
+		lines = append(lines, header)
+		for _, line := range strings.Split(utils.WordWrap(message.Text, width-2), "\n") {
+			lines = append(lines, "  "+bs.theme.TextAccent().Render(line))
+		}
+	}
+	if len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// viewPresence lists the connected clients, with their server if more than
+// one server is connected.
+func (bs *BroadcastScreen) viewPresence() string {
+	lines := []string{bs.theme.TextAccent().Bold(true).Render(fmt.Sprintf("Online (%d)", len(bs.clients)))}
+	for _, client := range bs.clients {
+		name := client.DisplayName()
+		if client.Self {
+			name += " (you)"
+		}
+		line := bs.theme.TextHighlight().Render("● ") + bs.theme.TextAccent().Render(name)
+		if len(bs.servers) > 1 {
+			line += bs.theme.TextBody().Render(" " + bs.serverName(client.Origin))
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.NewStyle().Width(PRESENCE_WIDTH).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (bs *BroadcastScreen) serverName(origin string) string {
+	for _, server := range bs.servers {
+		if server.ID == origin {
+			return server.Name
+		}
+	}
+	return origin
+}
+
+// Display shows the number of unread messages as a badge in the sidebar.
+func (bs *BroadcastScreen) Display() string {
+	if unread := bs.unread(); unread > 0 {
+		return fmt.Sprintf("Broadcast ●%d", unread)
+	}
+	return "Broadcast"
+}
+
+func (bs *BroadcastScreen) setTheme(t theme.Theme) {
+	bs.theme = t
+	bs.input.PromptStyle = t.TextHighlight()
+	bs.input.TextStyle = t.TextAccent()
+	bs.input.PlaceholderStyle = t.TextBody()
+	bs.input.Cursor.Style = t.TextHighlight()
+}
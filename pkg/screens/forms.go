@@ -0,0 +1,53 @@
+package screens
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// required returns a huh validator that rejects empty values for field.
+func required(field string) func(string) error {
+	return func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s is required", field)
+		}
+		return nil
+	}
+}
+
+// validateURL accepts absolute URLs with a scheme and a host.
+func validateURL(value string) error {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("use scheme://host:port")
+	}
+	return nil
+}
+
+// validateEndpoint accepts what the server can health check: an http(s)
+// URL or a host:port pair.
+func validateEndpoint(value string) error {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "://") {
+		return validateURL(value)
+	}
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		return fmt.Errorf("use http://host:port or host:port")
+	}
+	return nil
+}
+
+// validateMetadata accepts a comma separated list of key=value pairs.
+func validateMetadata(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		if k, _, ok := strings.Cut(pair, "="); !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("%q is not a key=value pair", strings.TrimSpace(pair))
+		}
+	}
+	return nil
+}
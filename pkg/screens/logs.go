@@ -0,0 +1,427 @@
+package screens
+
+import (
+	"fmt"
+	"log/slog"
+	"p1/pkg/keymap"
+	"p1/pkg/logs"
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+	"p1/pkg/tui/theme"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	LOG_POLL_INTERVAL = 500 * time.Millisecond // How often the log file is checked for new entries
+	MAX_LOG_ENTRIES   = 2000                   // Entries of the log file kept in memory
+)
+
+var (
+	logsScope = keymap.AddScope("logs", "Logs", keymap.SCOPE_LIST)
+
+	searchLogsKey = keymap.Register("logs.search", logsScope.ID, "Search", "/")
+	regexLogsKey  = keymap.Register("logs.regex", logsScope.ID, "Regex", "r")
+	levelLogsKey  = keymap.Register("logs.level", logsScope.ID, "Level", "l")
+	followLogsKey = keymap.Register("logs.follow", logsScope.ID, "Follow", "F")
+	sourceLogsKey = keymap.Register("logs.source", logsScope.ID, "Source", "s")
+	applyLogsKey  = keymap.Register("logs.apply", logsScope.ID, "Apply", "enter")
+	cancelLogsKey = keymap.Register("logs.cancel", logsScope.ID, "Clear Search", "esc")
+)
+
+// logLevels are the minimum levels the filter cycles through.
+var logLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// logsTickMsg asks the logs screen to read what was appended to the file.
+type logsTickMsg time.Time
+
+// PollLogs returns the command that makes the logs screen check its file,
+// the screen keeps polling from then on.
+func PollLogs() tea.Cmd {
+	return tea.Tick(LOG_POLL_INTERVAL, func(t time.Time) tea.Msg {
+		return logsTickMsg(t)
+	})
+}
+
+// Logs Screen
+type LogsScreen struct {
+	theme     theme.Theme
+	follower  *logs.Follower
+	local     []*logs.Entry // Entries of the log file
+	remote    []*logs.Entry // Entries streamed by the followed server
+	servers   []*models.Server
+	source    string // ID of the server whose logs are shown, empty for the file
+	level     slog.Level
+	input     textinput.Model
+	searching bool
+	regex     bool           // The query is a regular expression, not plain text
+	matcher   *regexp.Regexp // Compiled query, nil without one
+	queryErr  error          // Why the query could not be compiled
+	readErr   error          // Why the file could not be read on the last tick
+	follow    bool           // Stick to the newest entry
+	top       int            // First entry shown while not following
+	width     int
+	height    int
+}
+
+func NewLogsScreen(renderer *lipgloss.Renderer, path string) *Screen {
+	input := textinput.New()
+	input.Prompt = "/"
+	input.Placeholder = "search"
+	input.Width = 24
+
+	screen := &LogsScreen{
+		theme:    theme.BasicTheme(renderer, nil),
+		follower: logs.NewFollower(path),
+		local:    []*logs.Entry{},
+		remote:   []*logs.Entry{},
+		servers:  []*models.Server{},
+		level:    slog.LevelInfo,
+		input:    input,
+		follow:   true,
+	}
+	screen.setTheme(screen.theme)
+	return New(renderer, screen)
+}
+
+func (ls *LogsScreen) Actions() []*keymap.Binding {
+	if ls.searching {
+		return []*keymap.Binding{applyLogsKey, cancelLogsKey}
+	}
+	return []*keymap.Binding{searchLogsKey, regexLogsKey, levelLogsKey, followLogsKey, sourceLogsKey}
+}
+
+func (ls *LogsScreen) Update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case models.InternalWindowSizeMsg:
+		ls.width = max(0, msg.Width-msg.MenuWidth-8)
+		ls.height = max(0, msg.Height-msg.FooterHeight-4)
+	case theme.ChangedMsg:
+		ls.setTheme(msg.Theme)
+	case logsTickMsg:
+		ls.read()
+		return PollLogs()
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		ls.servers = state.Servers
+		ls.remote = state.Logs
+		if ls.source != "" && ls.serverName(ls.source) == "" {
+			// the followed server was removed
+			ls.source = ""
+		}
+	case tea.KeyMsg:
+		if ls.searching {
+			return ls.updateSearch(msg)
+		}
+		return ls.updateKeys(msg)
+	}
+	return nil
+}
+
+func (ls *LogsScreen) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, applyLogsKey.Binding):
+		ls.searching = false
+		ls.input.Blur()
+		return nil
+	case key.Matches(msg, cancelLogsKey.Binding):
+		ls.searching = false
+		ls.input.Blur()
+		ls.input.Reset()
+		ls.compile()
+		return nil
+	}
+	input, cmd := ls.input.Update(msg)
+	ls.input = input
+	ls.compile()
+	return cmd
+}
+
+func (ls *LogsScreen) updateKeys(msg tea.KeyMsg) tea.Cmd {
+	page := ls.pageSize()
+	switch {
+	case key.Matches(msg, listUpKey.Binding):
+		ls.scroll(-1)
+	case key.Matches(msg, listDownKey.Binding):
+		ls.scroll(1)
+	case key.Matches(msg, listPageUpKey.Binding):
+		ls.scroll(-page)
+	case key.Matches(msg, listPageDownKey.Binding):
+		ls.scroll(page)
+	case key.Matches(msg, listHalfPageUpKey.Binding):
+		ls.scroll(-page / 2)
+	case key.Matches(msg, listHalfPageDownKey.Binding):
+		ls.scroll(page / 2)
+	case key.Matches(msg, listTopKey.Binding):
+		ls.follow = false
+		ls.top = 0
+	case key.Matches(msg, listBottomKey.Binding):
+		ls.follow = true
+	case key.Matches(msg, followLogsKey.Binding):
+		ls.follow = !ls.follow
+		ls.top = ls.firstShown(len(ls.entries()))
+	case key.Matches(msg, levelLogsKey.Binding):
+		for i, level := range logLevels {
+			if level == ls.level {
+				ls.level = logLevels[(i+1)%len(logLevels)]
+				break
+			}
+		}
+	case key.Matches(msg, regexLogsKey.Binding):
+		ls.regex = !ls.regex
+		ls.compile()
+	case key.Matches(msg, searchLogsKey.Binding):
+		ls.searching = true
+		return ls.input.Focus()
+	case key.Matches(msg, cancelLogsKey.Binding):
+		ls.input.Reset()
+		ls.compile()
+	case key.Matches(msg, sourceLogsKey.Binding):
+		return ls.nextSource()
+	}
+	return nil
+}
+
+// read adds what was appended to the log file since the last tick.
+func (ls *LogsScreen) read() {
+	entries, err := ls.follower.Read()
+	ls.readErr = err
+	for _, entry := range entries {
+		ls.local = append(ls.local, &entry)
+	}
+	if len(ls.local) > MAX_LOG_ENTRIES {
+		ls.local = ls.local[len(ls.local)-MAX_LOG_ENTRIES:]
+	}
+}
+
+// nextSource switches from the log file to the servers and back, following
+// the logs of the server that is shown.
+func (ls *LogsScreen) nextSource() tea.Cmd {
+	sources := []string{""}
+	for _, server := range ls.servers {
+		sources = append(sources, server.ID)
+	}
+	next := sources[0]
+	for i, source := range sources {
+		if source == ls.source {
+			next = sources[(i+1)%len(sources)]
+		}
+	}
+
+	cmds := []tea.Cmd{}
+	if ls.source != "" {
+		cmds = append(cmds, messages.Send(ls.source, &messages.Message{Type: messages.TypeFollowLogs, Payload: false}))
+	}
+	if next != "" {
+		cmds = append(cmds, messages.Send(next, &messages.Message{Type: messages.TypeFollowLogs, Payload: true}))
+	}
+	ls.source = next
+	ls.remote = []*logs.Entry{}
+	ls.follow = true
+	return tea.Batch(cmds...)
+}
+
+// compile turns the query into the matcher. Plain text matches regardless
+// of case, a regular expression is taken as it is.
+func (ls *LogsScreen) compile() {
+	ls.matcher = nil
+	ls.queryErr = nil
+	query := ls.input.Value()
+	if query == "" {
+		return
+	}
+	if !ls.regex {
+		ls.matcher = regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+		return
+	}
+	matcher, err := regexp.Compile(query)
+	if err != nil {
+		ls.queryErr = fmt.Errorf("invalid regex: %w", err)
+		return
+	}
+	ls.matcher = matcher
+}
+
+// entries returns the entries of the current source that pass the filters.
+func (ls *LogsScreen) entries() []*logs.Entry {
+	all := ls.local
+	if ls.source != "" {
+		all = ls.remote
+	}
+	filtered := []*logs.Entry{}
+	for _, entry := range all {
+		if ls.source != "" && entry.Origin != ls.source {
+			continue
+		}
+		if entry.Level < ls.level {
+			continue
+		}
+		if ls.matcher != nil && !ls.matcher.MatchString(entry.Text()) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// pageSize is the number of entries that fit below the title and status.
+func (ls *LogsScreen) pageSize() int {
+	return max(1, ls.height-3)
+}
+
+// firstShown is the index of the first of count entries on the screen.
+func (ls *LogsScreen) firstShown(count int) int {
+	last := max(0, count-ls.pageSize())
+	if ls.follow {
+		return last
+	}
+	return max(0, min(ls.top, last))
+}
+
+// scroll moves the view by delta entries. Scrolling up stops following,
+// reaching the end again resumes it.
+func (ls *LogsScreen) scroll(delta int) {
+	count := len(ls.entries())
+	ls.top = ls.firstShown(count) + delta
+	last := max(0, count-ls.pageSize())
+	ls.follow = ls.top >= last
+	ls.top = max(0, min(ls.top, last))
+}
+
+func (ls *LogsScreen) View() string {
+	entries := ls.entries()
+	first := ls.firstShown(len(entries))
+	last := min(len(entries), first+ls.pageSize())
+
+	lines := []string{
+		ls.theme.TextAccent().Bold(true).Render(fmt.Sprintf("Logs (%d)", len(entries))),
+		ansi.Truncate(ls.viewStatus(), max(10, ls.width), "…"),
+		"",
+	}
+	if len(entries) == 0 {
+		lines = append(lines, ls.theme.TextBody().Render(ls.emptyMessage()))
+	}
+	for _, entry := range entries[first:last] {
+		lines = append(lines, ansi.Truncate(ls.viewEntry(entry), max(10, ls.width), "…"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (ls *LogsScreen) emptyMessage() string {
+	if ls.source != "" {
+		return fmt.Sprintf("Waiting for logs from %s.", ls.serverName(ls.source))
+	}
+	if len(ls.local) > 0 {
+		return "No entries match the filters."
+	}
+	return fmt.Sprintf("Nothing logged to %s yet.", ls.follower.Path())
+}
+
+func (ls *LogsScreen) viewStatus() string {
+	label := ls.theme.TextBody().Render
+	value := ls.theme.TextHighlight().Render
+
+	source := filepath.Base(ls.follower.Path())
+	if ls.source != "" {
+		source = ls.serverName(ls.source)
+	}
+	follow := "off"
+	if ls.follow {
+		follow = "on"
+	}
+	mode := "text"
+	if ls.regex {
+		mode = "regex"
+	}
+
+	parts := []string{
+		label("Source ") + value(source),
+		label("Level ") + value(ls.level.String()+"+"),
+		label("Follow ") + value(follow),
+	}
+	switch {
+	case ls.searching:
+		parts = append(parts, ls.input.View()+label(" "+mode))
+	case ls.input.Value() != "":
+		parts = append(parts, label("Search ")+value(ls.input.Value())+label(" "+mode))
+	}
+	status := strings.Join(parts, label("  ·  "))
+	for _, err := range []error{ls.queryErr, ls.readErr} {
+		if err != nil {
+			status += "  " + ls.theme.TextError().Render(err.Error())
+		}
+	}
+	return status
+}
+
+// viewEntry renders entry on a single line with the matches of the search
+// highlighted.
+func (ls *LogsScreen) viewEntry(entry *logs.Entry) string {
+	at := strings.Repeat(" ", len(time.TimeOnly))
+	if !entry.Time.IsZero() {
+		at = entry.Time.Local().Format(time.TimeOnly)
+	}
+	level := ls.theme.Base().Foreground(ls.levelColor(entry.Level)).Bold(true).Width(6).Render(entry.Level.String())
+
+	text := strings.ReplaceAll(entry.Text(), "\n", " ")
+	message := ls.theme.TextAccent().Render
+	match := ls.theme.Base().Background(ls.theme.Selection()).Foreground(ls.theme.Highlight()).Render
+	var b strings.Builder
+	end := 0
+	if ls.matcher != nil {
+		for _, loc := range ls.matcher.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			b.WriteString(message(text[end:loc[0]]))
+			b.WriteString(match(text[loc[0]:loc[1]]))
+			end = loc[1]
+		}
+	}
+	b.WriteString(message(text[end:]))
+
+	return ls.theme.TextBody().Render(at+" ") + level + b.String()
+}
+
+func (ls *LogsScreen) levelColor(level slog.Level) lipgloss.TerminalColor {
+	switch {
+	case level >= slog.LevelError:
+		return ls.theme.Error()
+	case level >= slog.LevelWarn:
+		return ls.theme.Warning()
+	case level >= slog.LevelInfo:
+		return ls.theme.Success()
+	default:
+		return ls.theme.Muted()
+	}
+}
+
+func (ls *LogsScreen) serverName(id string) string {
+	for _, server := range ls.servers {
+		if server.ID == id {
+			return server.Name
+		}
+	}
+	return ""
+}
+
+func (ls *LogsScreen) Display() string {
+	return "Logs"
+}
+
+func (ls *LogsScreen) setTheme(t theme.Theme) {
+	ls.theme = t
+	ls.input.PromptStyle = t.TextHighlight()
+	ls.input.TextStyle = t.TextAccent()
+	ls.input.PlaceholderStyle = t.TextBody()
+	ls.input.Cursor.Style = t.TextHighlight()
+}
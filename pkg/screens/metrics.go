@@ -0,0 +1,265 @@
+package screens
+
+import (
+	"fmt"
+	"math"
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+	"p1/pkg/tui/theme"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const METRICS_HISTORY = 120 // Samples kept per server for the sparklines
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// metricsHistory keeps the recent samples of one server.
+type metricsHistory struct {
+	cpu     []float64
+	ram     []float64
+	disk    []float64
+	network []float64
+	last    *models.Metrics
+}
+
+func (h *metricsHistory) push(m *models.Metrics) {
+	h.cpu = appendSample(h.cpu, m.CPU)
+	h.ram = appendSample(h.ram, m.RAM)
+	h.disk = appendSample(h.disk, m.Disk)
+	h.network = appendSample(h.network, m.Network)
+	h.last = m
+}
+
+func appendSample(samples []float64, value float64) []float64 {
+	samples = append(samples, value)
+	if len(samples) > METRICS_HISTORY {
+		samples = samples[len(samples)-METRICS_HISTORY:]
+	}
+	return samples
+}
+
+// Metrics Screen
+type MetricsScreen struct {
+	theme   theme.Theme
+	servers []*models.Server
+	history map[string]*metricsHistory // By origin server
+	width   int
+}
+
+func NewMetricsScreen(renderer *lipgloss.Renderer) *Screen {
+	screen := &MetricsScreen{
+		theme:   theme.BasicTheme(renderer, nil),
+		servers: []*models.Server{},
+		history: make(map[string]*metricsHistory),
+		width:   0,
+	}
+	return New(renderer, screen)
+}
+
+func (ms *MetricsScreen) Update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case models.InternalWindowSizeMsg:
+		ms.width = max(0, msg.Width-msg.MenuWidth-8)
+	case theme.ChangedMsg:
+		ms.setTheme(msg.Theme)
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		ms.servers = state.Servers
+		for origin, m := range state.Metrics {
+			h, ok := ms.history[origin]
+			if !ok {
+				h = &metricsHistory{}
+				ms.history[origin] = h
+			}
+			// every METRICS message produces a new sample, other syncs reuse it
+			if h.last != m {
+				h.push(m)
+			}
+		}
+		for origin := range ms.history {
+			if _, ok := state.Metrics[origin]; !ok {
+				delete(ms.history, origin)
+			}
+		}
+	}
+	return nil
+}
+
+func (ms *MetricsScreen) View() string {
+	if len(ms.history) == 0 {
+		return ms.theme.TextBody().Render("Waiting for metrics...")
+	}
+
+	sections := []string{}
+	for _, origin := range ms.origins() {
+		sections = append(sections, ms.viewServer(origin, ms.history[origin]))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
+
+func (ms *MetricsScreen) Display() string {
+	return fmt.Sprintf("Metrics (%d)", len(ms.history))
+}
+
+// origins returns the servers with metrics in the order they were added to the client.
+func (ms *MetricsScreen) origins() []string {
+	origins := []string{}
+	for _, server := range ms.servers {
+		if _, ok := ms.history[server.ID]; ok {
+			origins = append(origins, server.ID)
+		}
+	}
+	rest := []string{}
+	for origin := range ms.history {
+		if !slices.Contains(origins, origin) {
+			rest = append(rest, origin)
+		}
+	}
+	slices.Sort(rest)
+	return append(origins, rest...)
+}
+
+func (ms *MetricsScreen) serverName(origin string) string {
+	for _, server := range ms.servers {
+		if server.ID == origin {
+			return server.Name
+		}
+	}
+	return origin
+}
+
+func (ms *MetricsScreen) viewServer(origin string, h *metricsHistory) string {
+	m := h.last
+	width := max(40, ms.width)
+	labelWidth := 9
+	valueWidth := 12
+	gaugeWidth := max(10, (width-labelWidth-valueWidth)/2)
+	sparkWidth := max(10, width-labelWidth-valueWidth-gaugeWidth-2)
+
+	label := ms.theme.TextAccent().Width(labelWidth).Render
+	value := ms.theme.TextBody().Width(valueWidth).Align(lipgloss.Right).Render
+	spark := ms.theme.TextHighlight().Render
+
+	row := func(name string, current float64, samples []float64, ceiling float64) string {
+		return lipgloss.JoinHorizontal(lipgloss.Top,
+			label(name),
+			ms.gauge(current, gaugeWidth),
+			value(fmt.Sprintf("%.1f%%", current)),
+			"  ",
+			spark(sparkline(samples, sparkWidth, ceiling)),
+		)
+	}
+
+	lines := []string{
+		ms.theme.TextHighlight().Bold(true).Render(ms.serverName(origin)),
+		"",
+		row("CPU", m.CPU, h.cpu, 100),
+		row("Memory", m.RAM, h.ram, 100),
+		row("Disk", m.Disk, h.disk, 100),
+		lipgloss.JoinHorizontal(lipgloss.Top,
+			label("Network"),
+			ms.theme.TextBody().Width(gaugeWidth).Render(""),
+			value(formatRate(m.Network)),
+			"  ",
+			spark(sparkline(h.network, sparkWidth, 0)),
+		),
+	}
+
+	if len(m.Cores) > 0 {
+		lines = append(lines, "", ms.theme.TextAccent().Render("Cores"), ms.viewCores(m.Cores, width))
+	}
+	if len(m.Interfaces) > 0 {
+		lines = append(lines, "", ms.theme.TextAccent().Render("Interfaces"), ms.viewInterfaces(m.Interfaces))
+	}
+
+	return lipgloss.NewStyle().PaddingBottom(1).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// viewCores lays the per-core bars out in as many columns as fit.
+func (ms *MetricsScreen) viewCores(cores []float64, width int) string {
+	cellWidth := 24
+	columns := max(1, width/cellWidth)
+	rows := []string{}
+	for start := 0; start < len(cores); start += columns {
+		cells := []string{}
+		for i := start; i < min(start+columns, len(cores)); i++ {
+			cell := lipgloss.JoinHorizontal(lipgloss.Top,
+				ms.theme.TextBody().Width(4).Render(fmt.Sprintf("%d", i)),
+				ms.gauge(cores[i], 12),
+				ms.theme.TextBody().Width(7).Align(lipgloss.Right).Render(fmt.Sprintf("%.0f%%", cores[i])),
+			)
+			cells = append(cells, ms.theme.Base().Width(cellWidth).Render(cell))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, cells...))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+func (ms *MetricsScreen) viewInterfaces(interfaces []models.InterfaceRate) string {
+	rows := []string{}
+	for _, iface := range interfaces {
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top,
+			ms.theme.TextBody().Width(16).Render(iface.ID),
+			ms.theme.TextHighlight().Width(18).Render("↓ "+formatRate(iface.Rx)),
+			ms.theme.TextAccent().Width(18).Render("↑ "+formatRate(iface.Tx)),
+		))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+// gauge renders value (0-100) as a horizontal bar of width cells.
+func (ms *MetricsScreen) gauge(value float64, width int) string {
+	filled := int(math.Round(math.Max(0, math.Min(100, value)) / 100 * float64(width)))
+	color := ms.theme.Highlight()
+	if value >= 90 {
+		color = ms.theme.Error()
+	}
+	return ms.theme.Base().Foreground(color).Render(strings.Repeat("█", filled)) +
+		ms.theme.Base().Foreground(ms.theme.Border()).Render(strings.Repeat("░", width-filled))
+}
+
+// sparkline renders the last width samples, scaled to ceiling. A ceiling of
+// 0 scales to the largest sample shown.
+func sparkline(samples []float64, width int, ceiling float64) string {
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+	if ceiling <= 0 {
+		for _, s := range samples {
+			ceiling = math.Max(ceiling, s)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", width-len(samples)))
+	for _, s := range samples {
+		level := 0
+		if ceiling > 0 {
+			level = int(math.Round(s / ceiling * float64(len(sparkBlocks)-1)))
+		}
+		level = max(0, min(len(sparkBlocks)-1, level))
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// formatRate renders a KiB/s throughput with a fitting unit.
+func formatRate(kib float64) string {
+	switch {
+	case kib >= 1024*1024:
+		return fmt.Sprintf("%.1f GiB/s", kib/1024/1024)
+	case kib >= 1024:
+		return fmt.Sprintf("%.1f MiB/s", kib/1024)
+	default:
+		return fmt.Sprintf("%.1f KiB/s", kib)
+	}
+}
+
+func (ms *MetricsScreen) setTheme(t theme.Theme) {
+	ms.theme = t
+}
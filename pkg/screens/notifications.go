@@ -0,0 +1,94 @@
+package screens
+
+import (
+	"fmt"
+	"p1/pkg/keymap"
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+	"p1/pkg/tui/theme"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const MAX_NOTIFICATIONS = 200 // Notifications kept in the history, older ones are dropped
+
+var (
+	notificationsScope = keymap.AddScope("notifications", "Notifications", keymap.SCOPE_LIST)
+
+	clearNotificationsKey = keymap.Register("notifications.clear", notificationsScope.ID, "Clear", "x")
+)
+
+// Notifications Screen
+type NotificationsScreen struct {
+	theme   theme.Theme
+	history []models.Notification // Oldest first
+	servers []*models.Server
+}
+
+func NewNotificationsScreen(renderer *lipgloss.Renderer) *Screen {
+	screen := &NotificationsScreen{
+		theme:   theme.BasicTheme(renderer, nil),
+		history: []models.Notification{},
+		servers: []*models.Server{},
+	}
+	return New(renderer, screen)
+}
+
+func (ns *NotificationsScreen) Actions() []*keymap.Binding {
+	return []*keymap.Binding{clearNotificationsKey}
+}
+
+func (ns *NotificationsScreen) Update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case theme.ChangedMsg:
+		ns.theme = msg.Theme
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		ns.servers = state.Servers
+	case models.Notification:
+		ns.history = append(ns.history, msg)
+		if len(ns.history) > MAX_NOTIFICATIONS {
+			ns.history = ns.history[len(ns.history)-MAX_NOTIFICATIONS:]
+		}
+	case tea.KeyMsg:
+		if key.Matches(msg, clearNotificationsKey.Binding) {
+			ns.history = []models.Notification{}
+		}
+	}
+	return nil
+}
+
+func (ns *NotificationsScreen) View() string {
+	lines := []string{ns.theme.TextAccent().Bold(true).Render(fmt.Sprintf("Notifications (%d)", len(ns.history))), ""}
+	if len(ns.history) == 0 {
+		lines = append(lines, ns.theme.TextBody().Render("Nothing happened yet. Server events and errors show up here."))
+	}
+	for i := len(ns.history) - 1; i >= 0; i-- {
+		notification := ns.history[i]
+		at := ns.theme.TextBody().Width(10).Render(notification.At.Local().Format(time.TimeOnly))
+		icon := ns.theme.Base().Foreground(notification.Severity.Color(&ns.theme)).Bold(true).Render(notification.Severity.Icon() + " ")
+		line := at + icon + ns.theme.TextAccent().Render(notification.Message)
+		if notification.Origin != "" {
+			line += ns.theme.TextBody().Render("  " + ns.serverName(notification.Origin))
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (ns *NotificationsScreen) serverName(origin string) string {
+	for _, server := range ns.servers {
+		if server.ID == origin {
+			return server.Name
+		}
+	}
+	return origin
+}
+
+func (ns *NotificationsScreen) Display() string {
+	return fmt.Sprintf("Notifications (%d)", len(ns.history))
+}
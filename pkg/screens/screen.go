@@ -2,16 +2,24 @@ package screens
 
 import (
 	"fmt"
+	"maps"
+	"math"
 	"p1/pkg/dialog"
 	"p1/pkg/interfaces"
+	"p1/pkg/keymap"
+	"p1/pkg/messages"
 	"p1/pkg/models"
+	"p1/pkg/states"
 	"p1/pkg/tui/theme"
+	"p1/pkg/utils"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
-	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/google/uuid"
 )
@@ -25,8 +33,9 @@ type Screen struct {
 	height    int
 	theme     theme.Theme
 	focused   bool
-	Commands  []*interfaces.FooterCommand
 	footer    *models.Footer
+	dragging  bool // The scrollbar is held with the mouse
+	grab      int  // Line of the scrollbar the mouse holds it by
 }
 
 func (s *Screen) FooterHeight() int {
@@ -39,62 +48,61 @@ func (s *Screen) IsFocused() bool {
 
 func (s *Screen) SetFocused(focused bool) *Screen {
 	s.focused = focused
+	if handler, ok := s.Content.(interfaces.FocusHandler); ok {
+		handler.SetFocused(focused)
+	}
 	return s
 }
 
-func New(renderer *lipgloss.Renderer, content interfaces.ScreenContent, commands ...*interfaces.FooterCommand) *Screen {
-	cmds := []*interfaces.FooterCommand{}
-	cmds = append(cmds, commands...)
+func New(renderer *lipgloss.Renderer, content interfaces.ScreenContent) *Screen {
 	theme := theme.BasicTheme(renderer, nil)
-	footer := models.NewFooter(&theme, cmds)
-	return &Screen{
-		Content:  content,
-		ready:    false,
-		theme:    theme,
-		focused:  false,
-		Commands: cmds,
-		footer:   footer,
-	}
-}
-
-var modifiedKeyMap = viewport.KeyMap{
-	PageDown: key.NewBinding(
-		key.WithKeys("pgdown"),
-		key.WithHelp("pgdn", "page down"),
-	),
-	PageUp: key.NewBinding(
-		key.WithKeys("pgup"),
-		key.WithHelp("pgup", "page up"),
-	),
-	HalfPageUp: key.NewBinding(
-		key.WithKeys("ctrl+u"),
-		key.WithHelp("ctrl+u", "½ page up"),
-	),
-	HalfPageDown: key.NewBinding(
-		key.WithKeys("ctrl+d"),
-		key.WithHelp("ctrl+d", "½ page down"),
-	),
-	Up: key.NewBinding(
-		key.WithKeys("up", "k"),
-		key.WithHelp("↑", "up"),
-	),
-	Down: key.NewBinding(
-		key.WithKeys("down", "j"),
-		key.WithHelp("↓", "down"),
-	),
+	s := &Screen{
+		Content: content,
+		ready:   false,
+		theme:   theme,
+		focused: false,
+	}
+	s.footer = models.NewFooter(&theme, s.Commands())
+	return s
+}
+
+// Commands lists the content's actions for the footer. They are built from
+// the current bindings, so changes to the keymap show up right away.
+func (s *Screen) Commands() []*interfaces.FooterCommand {
+	cmds := []*interfaces.FooterCommand{}
+	for _, action := range s.Actions() {
+		if action.Enabled() {
+			cmds = append(cmds, &interfaces.FooterCommand{Key: action.Key(), Value: action.Title()})
+		}
+	}
+	return cmds
+}
+
+// viewportKeyMap scrolls the screen with the list bindings.
+func viewportKeyMap() viewport.KeyMap {
+	return viewport.KeyMap{
+		PageDown:     listPageDownKey.Binding,
+		PageUp:       listPageUpKey.Binding,
+		HalfPageUp:   listHalfPageUpKey.Binding,
+		HalfPageDown: listHalfPageDownKey.Binding,
+		Up:           listUpKey.Binding,
+		Down:         listDownKey.Binding,
+	}
 }
 
 func (s *Screen) Update(msg tea.Msg) tea.Cmd {
 	cmds := []tea.Cmd{}
 	parentMsg := msg
 
-	s.footer.SetCommands(s.Commands)
+	s.footer.SetCommands(s.Commands())
 
 	cmds = append(cmds, s.footer.Update(parentMsg))
 
 	footerHeight := lipgloss.Height(s.footer.View())
 
 	switch msg := msg.(type) {
+	case theme.ChangedMsg:
+		s.theme = msg.Theme
 	case models.InternalWindowSizeMsg:
 		s.height = msg.Height
 		s.menuWidth = msg.MenuWidth
@@ -102,7 +110,7 @@ func (s *Screen) Update(msg tea.Msg) tea.Cmd {
 		if !s.ready {
 			s.viewport = viewport.New(msg.Width-msg.MenuWidth-2, msg.Height-footerHeight)
 			s.viewport.HighPerformanceRendering = false
-			s.viewport.KeyMap = modifiedKeyMap
+			s.viewport.KeyMap = viewportKeyMap()
 			s.viewport.Style = s.viewport.Style.
 				PaddingLeft(2).
 				PaddingRight(2).
@@ -116,11 +124,15 @@ func (s *Screen) Update(msg tea.Msg) tea.Cmd {
 		}
 	}
 
-	s.viewport.KeyMap = modifiedKeyMap
+	s.viewport.KeyMap = viewportKeyMap()
 	s.viewport.Width = max(0, s.width-s.menuWidth-2)
 	s.viewport.Height = max(0, s.height-footerHeight)
 	s.viewport.Style = s.viewport.Style.MaxHeight(max(0, s.height-footerHeight))
-	cmds = append(cmds, s.Content.Update(parentMsg))
+	// key presses only reach the content while the screen has focus, so
+	// screens in the background do not react to sidebar navigation
+	if _, isKey := parentMsg.(tea.KeyMsg); !isKey || s.focused {
+		cmds = append(cmds, s.Content.Update(parentMsg))
+	}
 	s.viewport.SetContent(s.Content.View())
 
 	if s.focused {
@@ -135,6 +147,86 @@ func (s *Screen) Update(msg tea.Msg) tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// Mouse handles a mouse event at coordinates relative to the top left corner
+// of the screen. The wheel scrolls the viewport and the scrollbar can be
+// dragged; everything else within the viewport goes to the content, with the
+// coordinates moved to those of the content's view.
+func (s *Screen) Mouse(msg tea.MouseMsg) tea.Cmd {
+	if tea.MouseEvent(msg).IsWheel() {
+		vpm, cmd := s.viewport.Update(msg)
+		s.viewport = vpm
+		return cmd
+	}
+
+	switch msg.Action {
+	case tea.MouseActionRelease:
+		s.dragging = false
+	case tea.MouseActionMotion:
+		if s.dragging {
+			s.scrollTo(msg.Y - s.grab)
+			return nil
+		}
+	case tea.MouseActionPress:
+		if top, height := s.scrollbarBounds(); height > 0 && msg.Button == tea.MouseButtonLeft && msg.X == s.viewport.Width {
+			// the bar is held where it was clicked, or by its middle when
+			// the click went next to it
+			s.grab = height / 2
+			if msg.Y >= top && msg.Y < top+height {
+				s.grab = msg.Y - top
+			}
+			s.dragging = true
+			s.scrollTo(msg.Y - s.grab)
+			return nil
+		}
+	}
+
+	handler, ok := s.Content.(interfaces.MouseHandler)
+	if !ok {
+		return nil
+	}
+	top := s.viewport.Style.GetPaddingTop()
+	left := s.viewport.Style.GetPaddingLeft()
+	bottom := s.viewport.Height - s.viewport.Style.GetPaddingBottom()
+	if msg.Y < top || msg.Y >= bottom || msg.X < left || msg.X >= s.viewport.Width {
+		return nil
+	}
+	msg.X -= left
+	msg.Y += s.viewport.YOffset - top
+	cmd := handler.Mouse(msg)
+	s.viewport.SetContent(s.Content.View())
+	return cmd
+}
+
+// Dragging reports whether the scrollbar is held with the mouse.
+func (s *Screen) Dragging() bool {
+	return s.dragging
+}
+
+// scrollTo scrolls the viewport so the top of the scrollbar is on line y.
+func (s *Screen) scrollTo(y int) {
+	_, height := s.scrollbarBounds()
+	gap := s.viewport.Height - height
+	if height == 0 || gap <= 0 {
+		return
+	}
+	maxScroll := s.viewport.TotalLineCount() - s.viewport.Height
+	y = max(0, min(y, gap))
+	s.viewport.SetYOffset(int(math.Round(float64(y) * float64(maxScroll) / float64(gap))))
+}
+
+// scrollbarBounds returns the first line and the height of the bar drawn by
+// getScrollbar, the height is zero when the content fits and there is none.
+func (s *Screen) scrollbarBounds() (int, int) {
+	vh := s.viewport.Height
+	ch := s.viewport.TotalLineCount()
+	if vh >= ch {
+		return 0, 0
+	}
+	height := max(1, (vh*vh)/ch)
+	maxScroll := ch - vh
+	return int(math.Round(float64(vh-height) * float64(s.viewport.YOffset) / float64(maxScroll))), height
+}
+
 func (s *Screen) View() string {
 	viewportView := s.viewport.View()
 	content := s.Content.View()
@@ -175,6 +267,31 @@ func (s *Screen) getScrollbar(content string) string {
 	return style.Render(lipgloss.PlaceVertical(vh, lipgloss.Position(nYP), bar))
 }
 
+// Actions returns what the screen's content offers, if anything.
+func (s *Screen) Actions() []*keymap.Binding {
+	if provider, ok := s.Content.(interfaces.ActionProvider); ok {
+		return provider.Actions()
+	}
+	return nil
+}
+
+// Scope returns the keymap scope of the content's actions, or an empty
+// string for contents without actions.
+func (s *Screen) Scope() string {
+	for _, action := range s.Actions() {
+		return action.Scope
+	}
+	return ""
+}
+
+// DialogBindings returns the keys of the content's open dialog, if any.
+func (s *Screen) DialogBindings() []key.Binding {
+	if provider, ok := s.Content.(interfaces.DialogProvider); ok {
+		return provider.DialogBindings()
+	}
+	return nil
+}
+
 func (s *Screen) Display() string {
 	if s.Content != nil {
 		return s.Content.Display()
@@ -187,33 +304,63 @@ func (s *Screen) Display() string {
 type ProjectViewStatus string
 
 const (
-	ProjectViewStatusList ProjectViewStatus = "list"
-	ProjectViewStatusNew  ProjectViewStatus = "new"
-	ProjectViewStatusEdit ProjectViewStatus = "edit"
+	ProjectViewStatusList   ProjectViewStatus = "list"
+	ProjectViewStatusNew    ProjectViewStatus = "new"
+	ProjectViewStatusEdit   ProjectViewStatus = "edit"
+	ProjectViewStatusDelete ProjectViewStatus = "delete"
+	ProjectViewStatusDetail ProjectViewStatus = "detail"
 )
 
 type ProjectsScreen struct {
+	theme      theme.Theme
 	collection []*models.Project
+	services   []*models.Service
+	servers    []*models.Server
+	active     string
 	selected   int
 	dialog     *dialog.Dialog
-	pdb        *ProjectDialogBody
+	form       *dialog.FormBody
+	values     projectForm // Bound to the fields of form
+	confirm    *dialog.Dialog
+	editing    *models.Project
+	removing   *models.Project // Project the confirm dialog asks about
+	detail     string          // ID of the project shown in the detail view
 	viewstatus ProjectViewStatus
+	width      int
 }
 
 var (
-	newProjectKey = &interfaces.FooterCommand{Key: "ctrl+n", Value: "New Project"}
+	projectsScope = keymap.AddScope("projects", "Projects", keymap.SCOPE_LIST)
+
+	newProjectKey    = keymap.Register("projects.new", projectsScope.ID, "New Project", "ctrl+n")
+	editProjectKey   = keymap.Register("projects.edit", projectsScope.ID, "Edit", "e")
+	removeProjectKey = keymap.Register("projects.remove", projectsScope.ID, "Remove", "d")
+	detailProjectKey = keymap.Register("projects.details", projectsScope.ID, "Details", "enter")
+	backProjectKey   = keymap.Register("projects.back", projectsScope.ID, "Back", "esc", "backspace")
 )
 
 func NewProjectsScreen(renderer *lipgloss.Renderer) *Screen {
-	pdb := NewProjectDialogBody()
+	t := theme.BasicTheme(renderer, nil)
 	screen := &ProjectsScreen{
+		theme:      t,
 		collection: []*models.Project{},
+		services:   []*models.Service{},
+		servers:    []*models.Server{},
 		selected:   0,
-		dialog:     dialog.NewDialog("What is the name of your project?", pdb),
-		pdb:        pdb,
+		confirm:    dialog.NewDialog(t, "Delete this project?", dialog.NewConfirmBody("")),
 		viewstatus: ProjectViewStatusList,
 	}
-	return New(renderer, screen, newProjectKey)
+	screen.form = dialog.NewFormBody(t.Form(), screen.formFields)
+	screen.dialog = dialog.NewDialog(t, "Project", screen.form)
+	return New(renderer, screen)
+}
+
+func (ps *ProjectsScreen) Actions() []*keymap.Binding {
+	return []*keymap.Binding{newProjectKey, editProjectKey, removeProjectKey, detailProjectKey}
+}
+
+func (ps *ProjectsScreen) DialogBindings() []key.Binding {
+	return dialog.ActiveBindings(ps.dialog, ps.confirm)
 }
 
 func (ps *ProjectsScreen) Update(msg tea.Msg) tea.Cmd {
@@ -223,196 +370,579 @@ func (ps *ProjectsScreen) Update(msg tea.Msg) tea.Cmd {
 	}
 	switch msg := msg.(type) {
 	case models.InternalWindowSizeMsg:
+		ps.width = max(0, msg.Width-msg.MenuWidth-8)
 		ps.dialog.UpdateSize(msg.Width-msg.MenuWidth-8, msg.Height-msg.FooterHeight)
+		ps.confirm.UpdateSize(msg.Width-msg.MenuWidth-8, msg.Height-msg.FooterHeight)
+	case theme.ChangedMsg:
+		ps.setTheme(msg.Theme)
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		ps.servers = state.Servers
+		ps.active = state.Active
+		ps.services = state.Services
+		ps.refresh(state.Projects)
+		if ps.viewstatus == ProjectViewStatusDetail && ps.find(ps.detail) == nil {
+			ps.viewstatus = ProjectViewStatusList
+		}
 	case tea.KeyMsg:
-		switch msg.String() {
-		case newProjectKey.Key:
-			ps.viewstatus = ProjectViewStatusNew
-			ps.dialog.Show()
-			ps.dialog.Reset()
-			ps.pdb.Reset()
-			ps.pdb.Focus()
+		if ps.viewstatus == ProjectViewStatusDetail {
+			switch {
+			case key.Matches(msg, backProjectKey.Binding, detailProjectKey.Binding):
+				ps.viewstatus = ProjectViewStatusList
+			case key.Matches(msg, editProjectKey.Binding):
+				return ps.openEdit(ps.find(ps.detail))
+			}
+			return nil
+		}
+		if ps.viewstatus != ProjectViewStatusList {
+			break
+		}
+		switch {
+		case key.Matches(msg, listUpKey.Binding):
+			ps.selected = max(0, ps.selected-1)
+		case key.Matches(msg, listDownKey.Binding):
+			ps.selected = max(0, min(ps.selected+1, len(ps.collection)-1))
+		case key.Matches(msg, detailProjectKey.Binding):
+			if project := ps.current(); project != nil {
+				ps.detail = project.ID
+				ps.viewstatus = ProjectViewStatusDetail
+			}
+		case key.Matches(msg, newProjectKey.Binding):
+			ps.editing = nil
+			ps.values = projectForm{services: []string{}}
+			return ps.openDialog(ProjectViewStatusNew)
+		case key.Matches(msg, editProjectKey.Binding):
+			return ps.openEdit(ps.current())
+		case key.Matches(msg, removeProjectKey.Binding):
+			if project := ps.current(); project != nil {
+				ps.removing = project
+				ps.viewstatus = ProjectViewStatusDelete
+				ps.confirm.SetBody(dialog.NewConfirmBody(fmt.Sprintf("%s will be deleted for every client.", project.Name)))
+				ps.confirm.Show()
+				ps.confirm.Reset()
+			}
+			return nil
 		}
 	}
 
-	cmds = append(cmds, ps.dialog.Update(msg))
-	if ps.dialog.IsDone() && ps.dialog.IsVisible() {
-		if ps.dialog.GetConfirm() == "yes" {
-			value := ps.dialog.Value
-			if value != nil {
-				if pjName, ok := value.(string); ok && pjName != "" {
-					ps.AddProject(models.NewProject(uuid.NewString(), pjName))
-				}
+	switch ps.viewstatus {
+	case ProjectViewStatusNew, ProjectViewStatusEdit:
+		cmds = append(cmds, ps.dialog.Update(msg))
+		if ps.dialog.IsDone() && ps.dialog.IsVisible() {
+			if ps.dialog.GetConfirm() == "yes" {
+				cmds = append(cmds, ps.save())
 			}
+			ps.dialog.Hide()
+			ps.viewstatus = ProjectViewStatusList
+		}
+	case ProjectViewStatusDelete:
+		cmds = append(cmds, ps.confirm.Update(msg))
+		if ps.confirm.IsDone() && ps.confirm.IsVisible() {
+			if project := ps.removing; ps.confirm.GetConfirm() == "yes" && ps.confirm.Value != nil && project != nil {
+				cmds = append(cmds, messages.Send(project.Origin, &messages.Message{
+					Type:    messages.TypeRemoveProjects,
+					Payload: project.ID,
+				}))
+			}
+			ps.removing = nil
+			ps.confirm.Hide()
+			ps.viewstatus = ProjectViewStatusList
 		}
-		ps.dialog.Hide()
-		ps.viewstatus = ProjectViewStatusList
 	}
 
 	return tea.Batch(cmds...)
 }
 
-func (ps *ProjectsScreen) View() string {
-	var content string
-
-	if ps.viewstatus == ProjectViewStatusNew {
-		content += ps.dialog.View()
-		return content
+// Mouse selects the project under a left click and presses the buttons of
+// the open dialog.
+func (ps *ProjectsScreen) Mouse(msg tea.MouseMsg) tea.Cmd {
+	switch ps.viewstatus {
+	case ProjectViewStatusDelete:
+		return ps.confirm.Mouse(msg)
+	case ProjectViewStatusList:
+		// the rows follow the title and a blank line
+		if row := msg.Y - 2; isLeftClick(msg) && row >= 0 && row < len(ps.collection) {
+			ps.selected = row
+		}
 	}
+	return nil
+}
 
-	if ps.viewstatus == ProjectViewStatusList {
-		content = fmt.Sprintf("Projects (%d)\n\n", len(ps.collection))
+// projectForm holds the values of the project form.
+type projectForm struct {
+	name        string
+	description string
+	metadata    string
+	services    []string // IDs of the linked services
+}
+
+func (ps *ProjectsScreen) formFields() []huh.Field {
+	fields := []huh.Field{
+		huh.NewInput().Title("Name").Value(&ps.values.name).Validate(required("name")),
+		huh.NewText().Title("Description").Lines(3).Value(&ps.values.description),
+		huh.NewInput().Title("Metadata").Placeholder("key=value, ...").Value(&ps.values.metadata).Validate(validateMetadata),
+	}
 
-		for _, project := range ps.collection {
-			content += project.View() + "\n"
+	// services can only be linked to projects on the same server
+	origin := ps.target()
+	options := []huh.Option[string]{}
+	for _, svc := range ps.services {
+		if svc.Origin == origin {
+			options = append(options, huh.NewOption(fmt.Sprintf("%s (%s)", svc.Name, svc.Endpoint), svc.ID))
+		}
+	}
+	for _, id := range ps.values.services {
+		if ps.service(origin, id) == nil {
+			options = append(options, huh.NewOption(id+" (not registered)", id))
 		}
-		return lipgloss.JoinHorizontal(lipgloss.Top, content)
 	}
+	if len(options) > 0 {
+		fields = append(fields, huh.NewMultiSelect[string]().
+			Title("Linked services").
+			Options(options...).
+			Height(min(len(options)+2, 8)).
+			Value(&ps.values.services))
+	}
+	return fields
+}
 
-	return ""
+func (ps *ProjectsScreen) openDialog(status ProjectViewStatus) tea.Cmd {
+	ps.viewstatus = status
+	ps.form.Reset()
+	ps.dialog.Show()
+	ps.dialog.Reset()
+	return ps.form.Init()
 }
 
-func (s *ProjectsScreen) AddProject(project *models.Project) *ProjectsScreen {
-	s.collection = append(s.collection, project)
-	return s
+// openEdit opens the dialog prefilled with project.
+func (ps *ProjectsScreen) openEdit(project *models.Project) tea.Cmd {
+	if project == nil {
+		return nil
+	}
+	ps.editing = project
+	ps.values = projectForm{
+		name:        project.Name,
+		description: project.Description,
+		metadata:    formatMetadata(project.Metadata),
+		services:    slices.Clone(project.Services),
+	}
+	return ps.openDialog(ProjectViewStatusEdit)
 }
 
-func (s *ProjectsScreen) RemoveProject(project *models.Project) *ProjectsScreen {
-	for i, p := range s.collection {
-		if p.ID == project.ID {
-			s.collection = slices.Delete(s.collection, i, i+1)
-			break
-		}
+// target is the server the project in the dialog is sent to: the one an
+// edited project came from, otherwise the active server.
+func (ps *ProjectsScreen) target() string {
+	if ps.editing != nil {
+		return ps.editing.Origin
 	}
-	return s
+	if ps.active == "" && len(ps.servers) > 0 {
+		return ps.servers[0].ID
+	}
+	return ps.active
 }
 
-func (s *ProjectsScreen) Display() string {
-	count := len(s.collection)
-	return fmt.Sprintf("Projects (%d)", count)
+// save sends the project from the form to the server.
+func (ps *ProjectsScreen) save() tea.Cmd {
+	project := models.NewProject(uuid.NewString(), strings.TrimSpace(ps.values.name))
+	if ps.editing != nil {
+		project.ID = ps.editing.ID
+	}
+	project.Description = strings.TrimSpace(ps.values.description)
+	project.Metadata = parseMetadata(ps.values.metadata)
+	project.Services = slices.Clone(ps.values.services)
+	if project.Services == nil {
+		project.Services = []string{}
+	}
+
+	return messages.Send(ps.target(), &messages.Message{
+		Type:    messages.TypeRegisterProjects,
+		Payload: project,
+	})
 }
 
-type ProjectDialogBody struct {
-	name  string
-	input textinput.Model
-	done  bool
+// refresh replaces the collection, keeping the selection on the same project
+// if it is still there.
+func (ps *ProjectsScreen) refresh(projects []*models.Project) {
+	var selectedId string
+	if project := ps.current(); project != nil {
+		selectedId = project.ID
+	}
+	ps.collection = projects
+	ps.selected = max(0, min(ps.selected, len(ps.collection)-1))
+	if i := slices.IndexFunc(ps.collection, func(p *models.Project) bool { return p.ID == selectedId }); i >= 0 {
+		ps.selected = i
+	}
 }
 
-func (pdb *ProjectDialogBody) Update(msg tea.Msg) tea.Cmd {
-	cmds := []tea.Cmd{}
+// find returns the project with id, nil if there is none.
+func (ps *ProjectsScreen) find(id string) *models.Project {
+	if i := slices.IndexFunc(ps.collection, func(p *models.Project) bool { return p.ID == id }); i >= 0 {
+		return ps.collection[i]
+	}
+	return nil
+}
 
-	tiM, cmd := pdb.input.Update(msg)
-	pdb.input = tiM
-	if cmd != nil {
-		cmds = append(cmds, cmd)
+func (ps *ProjectsScreen) current() *models.Project {
+	if ps.selected < 0 || ps.selected >= len(ps.collection) {
+		return nil
 	}
+	return ps.collection[ps.selected]
+}
 
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "enter":
-			pdb.input.Blur()
-			pdb.name = pdb.input.Value()
-			pdb.done = true
-		case "tab":
-			pdb.input.Blur()
-			pdb.name = pdb.input.Value()
-		case "shift+tab":
-			if !pdb.input.Focused() {
-				pdb.input.Focus()
-			}
-		case "esc":
-			pdb.input.Blur()
-			pdb.name = ""
-			pdb.done = false
+// service finds a service known to the server origin by ID.
+func (ps *ProjectsScreen) service(origin string, id string) *models.Service {
+	for _, svc := range ps.services {
+		if svc.Origin == origin && svc.ID == id {
+			return svc
 		}
 	}
-
-	return tea.Batch(cmds...)
+	return nil
 }
 
-func (pdb *ProjectDialogBody) View() string {
-	return pdb.input.View()
+func (ps *ProjectsScreen) serverName(origin string) string {
+	for _, server := range ps.servers {
+		if server.ID == origin {
+			return server.Name
+		}
+	}
+	return origin
 }
 
-func (pdb *ProjectDialogBody) Value() any {
-	return pdb.name
-}
+func (ps *ProjectsScreen) View() string {
+	switch ps.viewstatus {
+	case ProjectViewStatusNew, ProjectViewStatusEdit:
+		return ps.dialog.View()
+	case ProjectViewStatusDelete:
+		return ps.confirm.View()
+	case ProjectViewStatusDetail:
+		return ps.viewDetail(ps.find(ps.detail))
+	}
 
-func (pdb *ProjectDialogBody) Reset() {
-	pdb.name = ""
-	pdb.done = false
-	pdb.input.Reset()
-	pdb.input.Blur()
+	lines := []string{ps.theme.TextAccent().Bold(true).Render(fmt.Sprintf("Projects (%d)", len(ps.collection))), ""}
+	if len(ps.collection) == 0 {
+		lines = append(lines, ps.theme.TextBody().Render(fmt.Sprintf("No projects yet. Press %s to create one.", newProjectKey.Key())))
+	}
+	for i, project := range ps.collection {
+		marker := "  "
+		name := ps.theme.TextBody().Width(24).Render(project.View())
+		if i == ps.selected {
+			marker = ps.theme.TextHighlight().Render("▶ ")
+			name = ps.theme.TextHighlight().Bold(true).Width(24).Render(project.View())
+		}
+		summary := ps.theme.TextBody().Render(fmt.Sprintf("%d services", len(project.Services)))
+		lines = append(lines, marker+name+summary)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
-func (pdb *ProjectDialogBody) Focus() {
-	pdb.input.Focus()
-}
+func (ps *ProjectsScreen) viewDetail(project *models.Project) string {
+	if project == nil {
+		return ""
+	}
+	label := ps.theme.TextBody().Width(12).Render
+	value := ps.theme.TextAccent().Render
+	heading := ps.theme.TextAccent().Bold(true).Render
+	none := ps.theme.TextBody().Render("-")
+
+	lines := []string{
+		ps.theme.TextHighlight().Bold(true).Render(project.Name),
+		"",
+		label("ID") + value(project.ID),
+		label("Server") + value(ps.serverName(project.Origin)),
+		label("Created") + value(project.CreatedAt.Local().Format(time.DateTime)),
+		label("Updated") + value(project.UpdatedAt.Local().Format(time.DateTime)),
+		"",
+		heading("Description"),
+	}
+	if project.Description != "" {
+		lines = append(lines, ps.theme.TextBody().Render(utils.WordWrap(project.Description, max(20, ps.width))))
+	} else {
+		lines = append(lines, none)
+	}
 
-func (pdb *ProjectDialogBody) Focused() bool {
-	return pdb.input.Focused()
-}
+	lines = append(lines, "", heading("Metadata"))
+	keys := slices.Sorted(maps.Keys(project.Metadata))
+	for _, k := range keys {
+		lines = append(lines, label(k)+value(project.Metadata[k]))
+	}
+	if len(keys) == 0 {
+		lines = append(lines, none)
+	}
 
-func NewProjectDialogBody() *ProjectDialogBody {
-	pi := textinput.New()
-	pi.Prompt = "▶ "
-	pi.Placeholder = "Project Name"
-	pi.Focus()
-	return &ProjectDialogBody{
-		name:  "",
-		input: pi,
-		done:  false,
+	lines = append(lines, "", heading("Services"))
+	for _, id := range project.Services {
+		if svc := ps.service(project.Origin, id); svc != nil {
+			lines = append(lines, label(svc.Name)+value(svc.Endpoint)+" "+ps.theme.TextBody().Render(svc.Health))
+		} else {
+			lines = append(lines, label(id)+ps.theme.TextError().Render("not registered"))
+		}
 	}
+	if len(project.Services) == 0 {
+		lines = append(lines, none)
+	}
+
+	lines = append(lines, "", heading("Recent events"))
+	for i := len(project.Events) - 1; i >= 0; i-- {
+		event := project.Events[i]
+		lines = append(lines, ps.theme.TextBody().Width(21).Render(event.At.Local().Format(time.DateTime))+value(event.Message))
+	}
+	if len(project.Events) == 0 {
+		lines = append(lines, none)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (s *ProjectsScreen) Display() string {
+	count := len(s.collection)
+	return fmt.Sprintf("Projects (%d)", count)
 }
 
 // Brokers Screen
+
+type BrokerViewStatus string
+
+const (
+	BrokerViewStatusList   BrokerViewStatus = "list"
+	BrokerViewStatusNew    BrokerViewStatus = "new"
+	BrokerViewStatusEdit   BrokerViewStatus = "edit"
+	BrokerViewStatusDelete BrokerViewStatus = "delete"
+)
+
+var (
+	brokersScope = keymap.AddScope("brokers", "Brokers", keymap.SCOPE_LIST)
+
+	newBrokerKey    = keymap.Register("brokers.new", brokersScope.ID, "New Broker", "n")
+	editBrokerKey   = keymap.Register("brokers.edit", brokersScope.ID, "Edit", "e")
+	removeBrokerKey = keymap.Register("brokers.remove", brokersScope.ID, "Remove", "d")
+)
+
 type BrokersScreen struct {
+	theme      theme.Theme
 	collection []*models.Broker
+	servers    []*models.Server
+	active     string
 	selected   int
+	dialog     *dialog.Dialog
+	form       *dialog.FormBody
+	values     brokerForm // Bound to the fields of form
+	confirm    *dialog.Dialog
+	editing    *models.Broker
+	removing   *models.Broker // Broker the confirm dialog asks about
+	viewstatus BrokerViewStatus
 }
 
 func NewBrokersScreen(renderer *lipgloss.Renderer) *Screen {
+	t := theme.BasicTheme(renderer, nil)
 	screen := &BrokersScreen{
+		theme:      t,
 		collection: []*models.Broker{},
+		servers:    []*models.Server{},
 		selected:   0,
+		confirm:    dialog.NewDialog(t, "Remove this broker?", dialog.NewConfirmBody("")),
+		viewstatus: BrokerViewStatusList,
 	}
-	return New(renderer, screen, &interfaces.FooterCommand{Key: "n", Value: "New Broker"})
+	screen.form = dialog.NewFormBody(t.Form(), screen.formFields)
+	screen.dialog = dialog.NewDialog(t, "Broker", screen.form)
+	return New(renderer, screen)
+}
+
+func (bs *BrokersScreen) Actions() []*keymap.Binding {
+	return []*keymap.Binding{newBrokerKey, editBrokerKey, removeBrokerKey}
+}
+
+func (bs *BrokersScreen) DialogBindings() []key.Binding {
+	return dialog.ActiveBindings(bs.dialog, bs.confirm)
 }
 
 func (bs *BrokersScreen) Update(msg tea.Msg) tea.Cmd {
 	var cmds []tea.Cmd
 
-	for _, broker := range bs.collection {
-		cmds = append(cmds, broker.Update(msg))
+	switch msg := msg.(type) {
+	case models.InternalWindowSizeMsg:
+		bs.dialog.UpdateSize(msg.Width-msg.MenuWidth-8, msg.Height-msg.FooterHeight)
+		bs.confirm.UpdateSize(msg.Width-msg.MenuWidth-8, msg.Height-msg.FooterHeight)
+	case theme.ChangedMsg:
+		bs.setTheme(msg.Theme)
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		bs.servers = state.Servers
+		bs.active = state.Active
+		bs.refresh(state.Brokers)
+	case tea.KeyMsg:
+		if bs.viewstatus != BrokerViewStatusList {
+			break
+		}
+		switch {
+		case key.Matches(msg, listUpKey.Binding):
+			bs.selected = max(0, bs.selected-1)
+		case key.Matches(msg, listDownKey.Binding):
+			bs.selected = max(0, min(bs.selected+1, len(bs.collection)-1))
+		case key.Matches(msg, newBrokerKey.Binding):
+			bs.editing = nil
+			bs.values = brokerForm{}
+			return bs.openDialog(BrokerViewStatusNew)
+		case key.Matches(msg, editBrokerKey.Binding):
+			if broker := bs.current(); broker != nil {
+				bs.editing = broker
+				bs.values = brokerForm{name: broker.Name, url: broker.URL}
+				return bs.openDialog(BrokerViewStatusEdit)
+			}
+			return nil
+		case key.Matches(msg, removeBrokerKey.Binding):
+			if broker := bs.current(); broker != nil {
+				bs.removing = broker
+				bs.viewstatus = BrokerViewStatusDelete
+				bs.confirm.SetBody(dialog.NewConfirmBody(fmt.Sprintf("%s (%s) will be removed for every client.", broker.Name, broker.URL)))
+				bs.confirm.Show()
+				bs.confirm.Reset()
+			}
+			return nil
+		}
 	}
+
+	switch bs.viewstatus {
+	case BrokerViewStatusNew, BrokerViewStatusEdit:
+		cmds = append(cmds, bs.dialog.Update(msg))
+		if bs.dialog.IsDone() && bs.dialog.IsVisible() {
+			if bs.dialog.GetConfirm() == "yes" {
+				cmds = append(cmds, bs.save())
+			}
+			bs.dialog.Hide()
+			bs.viewstatus = BrokerViewStatusList
+		}
+	case BrokerViewStatusDelete:
+		cmds = append(cmds, bs.confirm.Update(msg))
+		if bs.confirm.IsDone() && bs.confirm.IsVisible() {
+			if broker := bs.removing; bs.confirm.GetConfirm() == "yes" && bs.confirm.Value != nil && broker != nil {
+				cmds = append(cmds, messages.Send(broker.Origin, &messages.Message{
+					Type:    messages.TypeRemoveBroker,
+					Payload: broker.ID,
+				}))
+			}
+			bs.removing = nil
+			bs.confirm.Hide()
+			bs.viewstatus = BrokerViewStatusList
+		}
+	}
+
 	return tea.Batch(cmds...)
 }
 
-func (bs *BrokersScreen) View() string {
-	content := fmt.Sprintf("Brokers (%d)\n", len(bs.collection))
-	for _, broker := range bs.collection {
-		content += broker.View() + "\n"
+// Mouse selects the broker under a left click and presses the buttons of the
+// open dialog.
+func (bs *BrokersScreen) Mouse(msg tea.MouseMsg) tea.Cmd {
+	switch bs.viewstatus {
+	case BrokerViewStatusDelete:
+		return bs.confirm.Mouse(msg)
+	case BrokerViewStatusList:
+		// the rows follow the title and a blank line
+		if row := msg.Y - 2; isLeftClick(msg) && row >= 0 && row < len(bs.collection) {
+			bs.selected = row
+		}
 	}
-	return lipgloss.JoinHorizontal(lipgloss.Top, content)
+	return nil
 }
 
-func (s *BrokersScreen) AddBroker(broker *models.Broker) *BrokersScreen {
-	s.collection = append(s.collection, broker)
-	return s
+// brokerForm holds the values of the broker form.
+type brokerForm struct {
+	name string
+	url  string
 }
 
-func (s *BrokersScreen) RemoveBroker(broker *models.Broker) *BrokersScreen {
-	for i, p := range s.collection {
-		if p.ID == broker.ID {
-			s.collection = slices.Delete(s.collection, i, i+1)
-			break
+func (bs *BrokersScreen) formFields() []huh.Field {
+	return []huh.Field{
+		huh.NewInput().Title("Name").Value(&bs.values.name).Validate(required("name")),
+		huh.NewInput().Title("URL").Placeholder("mqtt://localhost:1883").Value(&bs.values.url).Validate(validateURL),
+	}
+}
+
+func (bs *BrokersScreen) openDialog(status BrokerViewStatus) tea.Cmd {
+	bs.viewstatus = status
+	bs.form.Reset()
+	bs.dialog.Show()
+	bs.dialog.Reset()
+	return bs.form.Init()
+}
+
+// save registers the broker from the form. Edits go to the
+// server the broker came from, new brokers to the active server.
+func (bs *BrokersScreen) save() tea.Cmd {
+	broker := models.NewBroker(strings.TrimSpace(bs.values.name), strings.TrimSpace(bs.values.url))
+
+	origin := bs.active
+	if origin == "" && len(bs.servers) > 0 {
+		origin = bs.servers[0].ID
+	}
+	if bs.editing != nil {
+		broker.ID = bs.editing.ID
+		origin = bs.editing.Origin
+	}
+	return messages.Send(origin, &messages.Message{
+		Type:    messages.TypeRegisterBroker,
+		Payload: broker,
+	})
+}
+
+// refresh replaces the collection, keeping the selection on the same broker
+// if it is still there.
+func (bs *BrokersScreen) refresh(brokers []*models.Broker) {
+	var selectedId string
+	if broker := bs.current(); broker != nil {
+		selectedId = broker.ID
+	}
+	bs.collection = brokers
+	bs.selected = max(0, min(bs.selected, len(bs.collection)-1))
+	if i := slices.IndexFunc(bs.collection, func(b *models.Broker) bool { return b.ID == selectedId }); i >= 0 {
+		bs.selected = i
+	}
+}
+
+func (bs *BrokersScreen) current() *models.Broker {
+	if bs.selected < 0 || bs.selected >= len(bs.collection) {
+		return nil
+	}
+	return bs.collection[bs.selected]
+}
+
+func (bs *BrokersScreen) View() string {
+	switch bs.viewstatus {
+	case BrokerViewStatusNew, BrokerViewStatusEdit:
+		return bs.dialog.View()
+	case BrokerViewStatusDelete:
+		return bs.confirm.View()
+	}
+
+	lines := []string{bs.theme.TextAccent().Bold(true).Render(fmt.Sprintf("Brokers (%d)", len(bs.collection))), ""}
+	if len(bs.collection) == 0 {
+		lines = append(lines, bs.theme.TextBody().Render(fmt.Sprintf("No brokers registered. Press %s to add one.", newBrokerKey.Key())))
+	}
+	for i, broker := range bs.collection {
+		name := bs.theme.TextBody().Width(24).Render(broker.Name)
+		link := bs.theme.TextBody().Render(broker.URL)
+		marker := "  "
+		if i == bs.selected {
+			marker = bs.theme.TextHighlight().Render("▶ ")
+			name = bs.theme.TextHighlight().Bold(true).Width(24).Render(broker.Name)
 		}
+		lines = append(lines, marker+name+link)
 	}
-	return s
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
 func (s *BrokersScreen) Display() string {
 	count := len(s.collection)
 	return fmt.Sprintf("Brokers (%d)", count)
 }
+
+func (ps *ProjectsScreen) setTheme(t theme.Theme) {
+	ps.theme = t
+	ps.dialog.SetTheme(t)
+	ps.confirm.SetTheme(t)
+}
+
+func (bs *BrokersScreen) setTheme(t theme.Theme) {
+	bs.theme = t
+	bs.dialog.SetTheme(t)
+	bs.confirm.SetTheme(t)
+}
@@ -0,0 +1,407 @@
+package screens
+
+import (
+	"fmt"
+	"p1/pkg/config"
+	"p1/pkg/dialog"
+	"p1/pkg/discovery"
+	"p1/pkg/keymap"
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+	"p1/pkg/tui/theme"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+)
+
+type ServerViewStatus string
+
+const (
+	ServerViewStatusList   ServerViewStatus = "list"
+	ServerViewStatusNew    ServerViewStatus = "new"
+	ServerViewStatusEdit   ServerViewStatus = "edit"
+	ServerViewStatusDelete ServerViewStatus = "delete"
+)
+
+var (
+	serversScope = keymap.AddScope("servers", "Servers", keymap.SCOPE_LIST)
+
+	newServerKey     = keymap.Register("servers.new", serversScope.ID, "New Server", "n")
+	editServerKey    = keymap.Register("servers.edit", serversScope.ID, "Edit", "e")
+	removeServerKey  = keymap.Register("servers.remove", serversScope.ID, "Remove", "d")
+	connectServerKey = keymap.Register("servers.connect", serversScope.ID, "Connect", "c")
+	selectServerKey  = keymap.Register("servers.select", serversScope.ID, "Show Only", "enter")
+	allServersKey    = keymap.Register("servers.all", serversScope.ID, "Show All", "a")
+)
+
+// serverEntry is a row of the servers table: a server that is connected,
+// saved, or both.
+type serverEntry struct {
+	server    *models.Server
+	saved     bool
+	connected bool
+}
+
+// Servers Screen
+type ServersScreen struct {
+	theme      theme.Theme
+	store      *config.ServerStore
+	connected  []*models.Server
+	entries    []serverEntry
+	status     map[string]models.ConnectionStatus
+	active     string
+	table      table.Model
+	dialog     *dialog.Dialog
+	form       *dialog.FormBody
+	values     serverForm // Bound to the fields of form
+	confirm    *dialog.Dialog
+	editing    *models.Server
+	viewstatus ServerViewStatus
+	width      int
+	height     int
+}
+
+func NewServersScreen(renderer *lipgloss.Renderer, store *config.ServerStore) *Screen {
+	t := theme.BasicTheme(renderer, nil)
+	screen := &ServersScreen{
+		theme:      t,
+		store:      store,
+		connected:  []*models.Server{},
+		status:     map[string]models.ConnectionStatus{},
+		table:      newTable(t, serverColumns(60)),
+		confirm:    dialog.NewDialog(t, "Remove this server?", dialog.NewConfirmBody("")),
+		viewstatus: ServerViewStatusList,
+	}
+	screen.form = dialog.NewFormBody(t.Form(), screen.formFields)
+	screen.dialog = dialog.NewDialog(t, "Server connection", screen.form)
+	screen.refresh()
+	return New(renderer, screen)
+}
+
+func (ss *ServersScreen) Actions() []*keymap.Binding {
+	return []*keymap.Binding{newServerKey, editServerKey, removeServerKey, connectServerKey, selectServerKey, allServersKey}
+}
+
+func (ss *ServersScreen) DialogBindings() []key.Binding {
+	return dialog.ActiveBindings(ss.dialog, ss.confirm)
+}
+
+// serverColumns sizes the table columns to fit width.
+func serverColumns(width int) []table.Column {
+	statusWidth := 12
+	latencyWidth := 9
+	versionWidth := 10
+	savedWidth := 6
+	rest := max(20, width-statusWidth-latencyWidth-versionWidth-savedWidth-12)
+	return []table.Column{
+		{Title: "Name", Width: rest * 2 / 5},
+		{Title: "URL", Width: rest - rest*2/5},
+		{Title: "Status", Width: statusWidth},
+		{Title: "Latency", Width: latencyWidth},
+		{Title: "Version", Width: versionWidth},
+		{Title: "Saved", Width: savedWidth},
+	}
+}
+
+func (ss *ServersScreen) Update(msg tea.Msg) tea.Cmd {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case models.InternalWindowSizeMsg:
+		ss.width = max(0, msg.Width-msg.MenuWidth-8)
+		ss.height = max(0, msg.Height-msg.FooterHeight-4)
+		ss.dialog.UpdateSize(ss.width, ss.height)
+		ss.confirm.UpdateSize(ss.width, ss.height)
+		ss.table.SetColumns(serverColumns(ss.width))
+		ss.table.SetWidth(ss.width)
+		ss.table.SetHeight(max(5, ss.height-4))
+		ss.refresh()
+	case theme.ChangedMsg:
+		ss.setTheme(msg.Theme)
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		ss.connected = state.Servers
+		ss.active = state.Active
+		ss.refresh()
+	case models.ServerStatusMsg:
+		ss.status = msg
+		ss.refresh()
+	case tea.KeyMsg:
+		if ss.viewstatus != ServerViewStatusList {
+			break
+		}
+		entry := ss.selected()
+		switch {
+		case key.Matches(msg, newServerKey.Binding):
+			ss.editing = nil
+			ss.values = serverForm{connect: true}
+			return ss.openDialog(ServerViewStatusNew)
+		case key.Matches(msg, editServerKey.Binding):
+			if entry != nil {
+				ss.editing = entry.server
+				ss.values = serverForm{name: entry.server.Name, address: entry.server.URL, connect: true}
+				return ss.openDialog(ServerViewStatusEdit)
+			}
+			return nil
+		case key.Matches(msg, removeServerKey.Binding):
+			if entry != nil {
+				question := fmt.Sprintf("%s will be disconnected.", entry.server.Name)
+				if entry.saved {
+					question = fmt.Sprintf("%s will be disconnected and removed from the saved servers.", entry.server.Name)
+				}
+				ss.viewstatus = ServerViewStatusDelete
+				ss.confirm.SetBody(dialog.NewConfirmBody(question))
+				ss.confirm.Show()
+				ss.confirm.Reset()
+			}
+			return nil
+		case key.Matches(msg, connectServerKey.Binding):
+			if entry != nil {
+				return ss.connect(entry.server)
+			}
+			return nil
+		case key.Matches(msg, selectServerKey.Binding):
+			if entry != nil {
+				if !entry.connected {
+					return func() tea.Msg {
+						return models.NewVisibleError(fmt.Sprintf("%s is not connected", entry.server.Name))
+					}
+				}
+				return func() tea.Msg { return messages.SelectServerMsg{ID: entry.server.ID} }
+			}
+			return nil
+		case key.Matches(msg, allServersKey.Binding):
+			return func() tea.Msg { return messages.SelectServerMsg{ID: ""} }
+		default:
+			t, cmd := ss.table.Update(msg)
+			ss.table = t
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	switch ss.viewstatus {
+	case ServerViewStatusNew, ServerViewStatusEdit:
+		cmds = append(cmds, ss.dialog.Update(msg))
+		if ss.dialog.IsDone() && ss.dialog.IsVisible() {
+			if ss.dialog.GetConfirm() == "yes" {
+				cmds = append(cmds, ss.save())
+			}
+			ss.dialog.Hide()
+			ss.viewstatus = ServerViewStatusList
+		}
+	case ServerViewStatusDelete:
+		cmds = append(cmds, ss.confirm.Update(msg))
+		if ss.confirm.IsDone() && ss.confirm.IsVisible() {
+			if ss.confirm.GetConfirm() == "yes" && ss.confirm.Value != nil {
+				cmds = append(cmds, ss.remove())
+			}
+			ss.confirm.Hide()
+			ss.viewstatus = ServerViewStatusList
+		}
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// Mouse selects the server under a left click and presses the buttons of the
+// open dialog.
+func (ss *ServersScreen) Mouse(msg tea.MouseMsg) tea.Cmd {
+	switch ss.viewstatus {
+	case ServerViewStatusDelete:
+		return ss.confirm.Mouse(msg)
+	case ServerViewStatusList:
+		// the table follows the title, the filter line and a blank line
+		if !isLeftClick(msg) || msg.X >= ss.table.Width() {
+			return nil
+		}
+		if row, ok := tableRowAt(ss.table, tableStyles(ss.theme), msg.Y-3); ok {
+			ss.table.SetCursor(row)
+		}
+	}
+	return nil
+}
+
+// serverForm holds the values of the server form.
+type serverForm struct {
+	name    string
+	address string
+	connect bool
+}
+
+func (ss *ServersScreen) formFields() []huh.Field {
+	return []huh.Field{
+		huh.NewInput().Title("Name").Placeholder("defaults to the address").Value(&ss.values.name),
+		huh.NewInput().Title("Address").Placeholder("host:port or ws://host:port/ws").Value(&ss.values.address).Validate(validateServerAddress),
+		huh.NewConfirm().Title("Connect now?").Affirmative("Yes").Negative("No").Value(&ss.values.connect),
+	}
+}
+
+// validateServerAddress accepts everything discovery.NormalizeLink can turn into a websocket link.
+func validateServerAddress(value string) error {
+	if err := required("address")(value); err != nil {
+		return err
+	}
+	_, err := discovery.NormalizeLink(strings.TrimSpace(value))
+	return err
+}
+
+func (ss *ServersScreen) openDialog(status ServerViewStatus) tea.Cmd {
+	ss.viewstatus = status
+	ss.form.Reset()
+	ss.dialog.Show()
+	ss.dialog.Reset()
+	return ss.form.Init()
+}
+
+// save stores the server entered in the dialog and, if asked to, (re)connects to it.
+func (ss *ServersScreen) save() tea.Cmd {
+	name, address := strings.TrimSpace(ss.values.name), strings.TrimSpace(ss.values.address)
+	link, err := discovery.NormalizeLink(address)
+	if err != nil {
+		return func() tea.Msg { return models.NewVisibleError(err.Error()) }
+	}
+	if name == "" {
+		name = address
+	}
+
+	id := uuid.NewString()
+	if ss.editing != nil {
+		id = ss.editing.ID
+	}
+	server := &models.Server{ID: id, Name: name, URL: link}
+	if err := ss.store.Save(server); err != nil {
+		return func() tea.Msg { return models.NewVisibleError(fmt.Sprintf("failed to save server: %s", err)) }
+	}
+	ss.refresh()
+	if !ss.values.connect {
+		return nil
+	}
+	return ss.connect(server)
+}
+
+// remove disconnects from the selected server and forgets it.
+func (ss *ServersScreen) remove() tea.Cmd {
+	entry := ss.selected()
+	if entry == nil {
+		return nil
+	}
+	if entry.saved {
+		if err := ss.store.Delete(entry.server.ID); err != nil {
+			return func() tea.Msg { return models.NewVisibleError(fmt.Sprintf("failed to remove server: %s", err)) }
+		}
+	}
+	ss.refresh()
+	id := entry.server.ID
+	return func() tea.Msg { return messages.DisconnectServerMsg{ID: id} }
+}
+
+func (ss *ServersScreen) connect(server *models.Server) tea.Cmd {
+	return func() tea.Msg { return messages.ConnectServerMsg{Server: server} }
+}
+
+// refresh merges the connected and saved servers into the table rows,
+// keeping the cursor on the same server if it is still there.
+func (ss *ServersScreen) refresh() {
+	var selectedId string
+	if entry := ss.selected(); entry != nil {
+		selectedId = entry.server.ID
+	}
+
+	saved := ss.store.Servers()
+	entries := []serverEntry{}
+	for _, server := range ss.connected {
+		entries = append(entries, serverEntry{
+			server:    server,
+			connected: true,
+			saved: slices.ContainsFunc(saved, func(s *models.Server) bool {
+				return s.ID == server.ID
+			}),
+		})
+	}
+	for _, server := range saved {
+		if !slices.ContainsFunc(ss.connected, func(s *models.Server) bool { return s.ID == server.ID }) {
+			entries = append(entries, serverEntry{server: server, saved: true})
+		}
+	}
+	ss.entries = entries
+
+	rows := make([]table.Row, 0, len(entries))
+	cursor := 0
+	for i, entry := range entries {
+		if entry.server.ID == selectedId {
+			cursor = i
+		}
+		name := entry.server.Name
+		if entry.server.ID == ss.active {
+			name = "▶ " + name
+		}
+		status, latency, version := "offline", "-", "-"
+		if entry.connected {
+			stats := ss.status[entry.server.ID]
+			status = "reconnecting"
+			if stats.Connected {
+				status = "connected"
+				latency = fmt.Sprintf("%dms", stats.Latency.Milliseconds())
+			}
+			if stats.Version != "" {
+				version = stats.Version
+			}
+		}
+		savedMark := ""
+		if entry.saved {
+			savedMark = "✓"
+		}
+		rows = append(rows, table.Row{name, entry.server.URL, status, latency, version, savedMark})
+	}
+	ss.table.SetRows(rows)
+	ss.table.SetCursor(cursor)
+}
+
+func (ss *ServersScreen) selected() *serverEntry {
+	cursor := ss.table.Cursor()
+	if cursor < 0 || cursor >= len(ss.entries) {
+		return nil
+	}
+	return &ss.entries[cursor]
+}
+
+func (ss *ServersScreen) View() string {
+	switch ss.viewstatus {
+	case ServerViewStatusNew, ServerViewStatusEdit:
+		return ss.dialog.View()
+	case ServerViewStatusDelete:
+		return ss.confirm.View()
+	}
+
+	title := ss.theme.TextAccent().Bold(true).Render(fmt.Sprintf("Servers (%d)", len(ss.entries)))
+	showing := "Showing all servers"
+	for _, server := range ss.connected {
+		if server.ID == ss.active {
+			showing = fmt.Sprintf("Showing only %s", server.Name)
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		ss.theme.TextBody().Render(showing),
+		"",
+		ss.table.View(),
+	)
+}
+
+func (ss *ServersScreen) Display() string {
+	return fmt.Sprintf("Servers (%d)", len(ss.entries))
+}
+
+func (ss *ServersScreen) setTheme(t theme.Theme) {
+	ss.theme = t
+	ss.table.SetStyles(tableStyles(t))
+	ss.dialog.SetTheme(t)
+	ss.confirm.SetTheme(t)
+}
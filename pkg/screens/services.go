@@ -0,0 +1,451 @@
+package screens
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"p1/pkg/dialog"
+	"p1/pkg/keymap"
+	"p1/pkg/messages"
+	"p1/pkg/models"
+	"p1/pkg/states"
+	"p1/pkg/tui/theme"
+	"p1/pkg/utils"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+)
+
+type ServiceViewStatus string
+
+const (
+	ServiceViewStatusList   ServiceViewStatus = "list"
+	ServiceViewStatusNew    ServiceViewStatus = "new"
+	ServiceViewStatusDelete ServiceViewStatus = "delete"
+)
+
+// serviceSortColumns are the columns the table can be sorted by, in the
+// order "s" cycles through them.
+var serviceSortColumns = []string{"Name", "Endpoint", "Health", "Metadata"}
+
+var (
+	servicesScope = keymap.AddScope("services", "Services", keymap.SCOPE_LIST)
+
+	newServiceKey    = keymap.Register("services.new", servicesScope.ID, "New Service", "n")
+	removeServiceKey = keymap.Register("services.remove", servicesScope.ID, "Remove", "d")
+	sortServiceKey   = keymap.Register("services.sort", servicesScope.ID, "Sort", "s")
+	reverseSortKey   = keymap.Register("services.reverse", servicesScope.ID, "Reverse", "r")
+)
+
+// Services Screen
+type ServicesScreen struct {
+	theme      theme.Theme
+	collection []*models.Service
+	servers    []*models.Server
+	active     string
+	table      table.Model
+	sortColumn int
+	sortDesc   bool
+	dialog     *dialog.Dialog
+	form       *dialog.FormBody
+	values     serviceForm // Bound to the fields of form
+	confirm    *dialog.Dialog
+	viewstatus ServiceViewStatus
+	width      int
+	height     int
+}
+
+func NewServicesScreen(renderer *lipgloss.Renderer) *Screen {
+	t := theme.BasicTheme(renderer, nil)
+
+	tbl := newTable(t, serviceColumns(60))
+
+	screen := &ServicesScreen{
+		theme:      t,
+		collection: []*models.Service{},
+		servers:    []*models.Server{},
+		table:      tbl,
+		confirm:    dialog.NewDialog(t, "Remove this service?", dialog.NewConfirmBody("")),
+		viewstatus: ServiceViewStatusList,
+	}
+	screen.form = dialog.NewFormBody(t.Form(), screen.formFields)
+	screen.dialog = dialog.NewDialog(t, "Register a new service", screen.form)
+	return New(renderer, screen)
+}
+
+func (ss *ServicesScreen) Actions() []*keymap.Binding {
+	return []*keymap.Binding{newServiceKey, removeServiceKey, sortServiceKey, reverseSortKey}
+}
+
+func (ss *ServicesScreen) DialogBindings() []key.Binding {
+	return dialog.ActiveBindings(ss.dialog, ss.confirm)
+}
+
+// serviceColumns sizes the table columns to fit width.
+func serviceColumns(width int) []table.Column {
+	healthWidth := 8
+	metaWidth := 8
+	rest := max(20, width-healthWidth-metaWidth-8)
+	return []table.Column{
+		{Title: "Name", Width: rest * 2 / 5},
+		{Title: "Endpoint", Width: rest - rest*2/5},
+		{Title: "Health", Width: healthWidth},
+		{Title: "Metadata", Width: metaWidth},
+	}
+}
+
+func (ss *ServicesScreen) Update(msg tea.Msg) tea.Cmd {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case models.InternalWindowSizeMsg:
+		ss.width = max(0, msg.Width-msg.MenuWidth-8)
+		ss.height = max(0, msg.Height-msg.FooterHeight-4)
+		ss.dialog.UpdateSize(ss.width, ss.height)
+		ss.confirm.UpdateSize(ss.width, ss.height)
+		ss.layout()
+	case theme.ChangedMsg:
+		ss.setTheme(msg.Theme)
+	case messages.SyncMsg:
+		state := (*states.ClientState)(msg)
+		ss.servers = state.Servers
+		ss.active = state.Active
+		ss.collection = state.Services
+		ss.refresh()
+	case tea.KeyMsg:
+		if ss.viewstatus != ServiceViewStatusList {
+			break
+		}
+		switch {
+		case key.Matches(msg, newServiceKey.Binding):
+			ss.viewstatus = ServiceViewStatusNew
+			ss.values = serviceForm{}
+			ss.form.Reset()
+			ss.dialog.Show()
+			ss.dialog.Reset()
+			return ss.form.Init()
+		case key.Matches(msg, removeServiceKey.Binding):
+			if svc := ss.selected(); svc != nil {
+				ss.viewstatus = ServiceViewStatusDelete
+				ss.confirm.SetBody(dialog.NewConfirmBody(fmt.Sprintf("%s (%s) will be removed from %s.", svc.Name, svc.Endpoint, ss.serverName(svc.Origin))))
+				ss.confirm.Show()
+				ss.confirm.Reset()
+			}
+			return nil
+		case key.Matches(msg, sortServiceKey.Binding):
+			ss.sortColumn = (ss.sortColumn + 1) % len(serviceSortColumns)
+			ss.refresh()
+		case key.Matches(msg, reverseSortKey.Binding):
+			ss.sortDesc = !ss.sortDesc
+			ss.refresh()
+		default:
+			t, cmd := ss.table.Update(msg)
+			ss.table = t
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	switch ss.viewstatus {
+	case ServiceViewStatusNew:
+		cmds = append(cmds, ss.dialog.Update(msg))
+		if ss.dialog.IsDone() && ss.dialog.IsVisible() {
+			if ss.dialog.GetConfirm() == "yes" {
+				cmds = append(cmds, ss.register())
+			}
+			ss.dialog.Hide()
+			ss.viewstatus = ServiceViewStatusList
+		}
+	case ServiceViewStatusDelete:
+		cmds = append(cmds, ss.confirm.Update(msg))
+		if ss.confirm.IsDone() && ss.confirm.IsVisible() {
+			if ss.confirm.GetConfirm() == "yes" && ss.confirm.Value != nil {
+				if svc := ss.selected(); svc != nil {
+					cmds = append(cmds, messages.Send(svc.Origin, &messages.Message{
+						Type:    messages.TypeRemoveService,
+						Payload: svc.ID,
+					}))
+				}
+			}
+			ss.confirm.Hide()
+			ss.viewstatus = ServiceViewStatusList
+		}
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// Mouse selects the service under a left click and presses the buttons of
+// the open dialog.
+func (ss *ServicesScreen) Mouse(msg tea.MouseMsg) tea.Cmd {
+	switch ss.viewstatus {
+	case ServiceViewStatusDelete:
+		return ss.confirm.Mouse(msg)
+	case ServiceViewStatusList:
+		// the table follows the title and a blank line
+		if !isLeftClick(msg) || msg.X >= ss.table.Width() {
+			return nil
+		}
+		if row, ok := tableRowAt(ss.table, tableStyles(ss.theme), msg.Y-2); ok {
+			ss.table.SetCursor(row)
+		}
+	}
+	return nil
+}
+
+// serviceForm holds the values of the register form.
+type serviceForm struct {
+	name        string
+	endpoint    string
+	description string
+	metadata    string
+}
+
+func (ss *ServicesScreen) formFields() []huh.Field {
+	return []huh.Field{
+		huh.NewInput().Title("Name").Value(&ss.values.name).Validate(required("name")),
+		huh.NewInput().Title("Endpoint").Placeholder("http://host:port or host:port").Value(&ss.values.endpoint).Validate(validateEndpoint),
+		huh.NewText().Title("Description").Lines(3).Value(&ss.values.description),
+		huh.NewInput().Title("Metadata").Placeholder("key=value, ...").Value(&ss.values.metadata).Validate(validateMetadata),
+	}
+}
+
+// register sends the service entered in the dialog to the active server, or
+// the first one if all servers are shown.
+func (ss *ServicesScreen) register() tea.Cmd {
+	svc := &models.Service{
+		ID:          uuid.NewString(),
+		Name:        strings.TrimSpace(ss.values.name),
+		Endpoint:    strings.TrimSpace(ss.values.endpoint),
+		Description: strings.TrimSpace(ss.values.description),
+		Metadata:    parseMetadata(ss.values.metadata),
+	}
+	origin := ss.active
+	if origin == "" && len(ss.servers) > 0 {
+		origin = ss.servers[0].ID
+	}
+	return messages.Send(origin, &messages.Message{
+		Type:    messages.TypeRegisterService,
+		Payload: svc,
+	})
+}
+
+// refresh sorts the collection and rebuilds the table rows, keeping the
+// cursor on the same service if it is still there.
+func (ss *ServicesScreen) refresh() {
+	var selectedId string
+	if svc := ss.selected(); svc != nil {
+		selectedId = svc.ID
+	}
+
+	slices.SortStableFunc(ss.collection, func(a, b *models.Service) int {
+		var c int
+		switch serviceSortColumns[ss.sortColumn] {
+		case "Endpoint":
+			c = cmp.Compare(a.Endpoint, b.Endpoint)
+		case "Health":
+			c = cmp.Compare(a.Health, b.Health)
+		case "Metadata":
+			c = cmp.Compare(len(a.Metadata), len(b.Metadata))
+		}
+		c = cmp.Or(c, cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)))
+		if ss.sortDesc {
+			return -c
+		}
+		return c
+	})
+
+	rows := make([]table.Row, 0, len(ss.collection))
+	cursor := 0
+	for i, svc := range ss.collection {
+		if svc.ID == selectedId {
+			cursor = i
+		}
+		health := svc.Health
+		if health == "" {
+			health = "unknown"
+		}
+		rows = append(rows, table.Row{svc.Name, svc.Endpoint, health, fmt.Sprintf("%d", len(svc.Metadata))})
+	}
+
+	columns := ss.table.Columns()
+	for i := range columns {
+		columns[i].Title = serviceSortColumns[i]
+		if i == ss.sortColumn {
+			arrow := "▲"
+			if ss.sortDesc {
+				arrow = "▼"
+			}
+			columns[i].Title += " " + arrow
+		}
+	}
+	ss.table.SetColumns(columns)
+	ss.table.SetRows(rows)
+	ss.table.SetCursor(cursor)
+}
+
+// layout sizes the table for the current window; the detail pane goes to
+// the right on wide terminals and below the table otherwise.
+func (ss *ServicesScreen) layout() {
+	tableWidth := ss.width
+	tableHeight := max(5, ss.height/2)
+	if ss.wide() {
+		tableWidth = ss.width * 3 / 5
+		tableHeight = max(5, ss.height-4)
+	}
+	ss.table.SetColumns(serviceColumns(tableWidth))
+	ss.table.SetWidth(tableWidth)
+	ss.table.SetHeight(tableHeight)
+	ss.refresh()
+}
+
+func (ss *ServicesScreen) wide() bool {
+	return ss.width >= 100
+}
+
+func (ss *ServicesScreen) selected() *models.Service {
+	cursor := ss.table.Cursor()
+	if cursor < 0 || cursor >= len(ss.collection) {
+		return nil
+	}
+	return ss.collection[cursor]
+}
+
+func (ss *ServicesScreen) serverName(origin string) string {
+	for _, server := range ss.servers {
+		if server.ID == origin {
+			return server.Name
+		}
+	}
+	return origin
+}
+
+func (ss *ServicesScreen) View() string {
+	switch ss.viewstatus {
+	case ServiceViewStatusNew:
+		return ss.dialog.View()
+	case ServiceViewStatusDelete:
+		return ss.confirm.View()
+	}
+
+	title := ss.theme.TextAccent().Bold(true).Render(fmt.Sprintf("Services (%d)", len(ss.collection)))
+	if len(ss.collection) == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, title, "", ss.theme.TextBody().Render(fmt.Sprintf("No services registered. Press %s to register one.", newServiceKey.Key())))
+	}
+
+	list := ss.table.View()
+	detail := ss.viewDetail()
+	if ss.wide() {
+		return lipgloss.JoinVertical(lipgloss.Left, title, "",
+			lipgloss.JoinHorizontal(lipgloss.Top, list, "  ", detail))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, title, "", list, "", detail)
+}
+
+func (ss *ServicesScreen) viewDetail() string {
+	svc := ss.selected()
+	if svc == nil {
+		return ""
+	}
+
+	width := ss.width
+	if ss.wide() {
+		width = ss.width - ss.width*3/5 - 2
+	}
+	width = max(20, width-4)
+
+	label := ss.theme.TextBody().Width(12).Render
+	value := ss.theme.TextAccent().Render
+
+	lines := []string{
+		ss.theme.TextHighlight().Bold(true).Render(svc.Name),
+		"",
+		label("ID") + value(svc.ID),
+		label("Endpoint") + value(svc.Endpoint),
+		label("Health") + ss.viewHealth(svc.Health),
+		label("Server") + value(ss.serverName(svc.Origin)),
+	}
+	if svc.Owner != "" {
+		lines = append(lines, label("Owner")+value(svc.Owner))
+	}
+	if len(svc.Conflicts) > 0 {
+		lines = append(lines, label("Conflicts")+ss.theme.TextError().Render(strings.Join(svc.Conflicts, ", ")))
+	}
+
+	lines = append(lines, "", ss.theme.TextAccent().Render("Description"))
+	if svc.Description != "" {
+		lines = append(lines, ss.theme.TextBody().Render(utils.WordWrap(svc.Description, width)))
+	} else {
+		lines = append(lines, ss.theme.TextBody().Render("-"))
+	}
+
+	lines = append(lines, "", ss.theme.TextAccent().Render("Metadata"))
+	keys := make([]string, 0, len(svc.Metadata))
+	for k := range svc.Metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		lines = append(lines, label(k)+value(svc.Metadata[k]))
+	}
+	if len(keys) == 0 {
+		lines = append(lines, ss.theme.TextBody().Render("-"))
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ss.theme.Border()).
+		Padding(0, 1).
+		Width(width).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (ss *ServicesScreen) viewHealth(health string) string {
+	switch health {
+	case "up":
+		return ss.theme.TextHighlight().Render("● up")
+	case "down":
+		return ss.theme.TextError().Render("● down")
+	default:
+		return ss.theme.TextBody().Render("● unknown")
+	}
+}
+
+func (ss *ServicesScreen) Display() string {
+	return fmt.Sprintf("Services (%d)", len(ss.collection))
+}
+
+// formatMetadata is the inverse of parseMetadata, with the keys sorted.
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for _, k := range slices.Sorted(maps.Keys(metadata)) {
+		pairs = append(pairs, k+"="+metadata[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// parseMetadata turns "key=value, other=value" into a map, ignoring entries without a key.
+func parseMetadata(input string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(input, ",") {
+		k, v, _ := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		metadata[k] = strings.TrimSpace(v)
+	}
+	return metadata
+}
+
+func (ss *ServicesScreen) setTheme(t theme.Theme) {
+	ss.theme = t
+	ss.table.SetStyles(tableStyles(t))
+	ss.dialog.SetTheme(t)
+	ss.confirm.SetTheme(t)
+}
@@ -0,0 +1,93 @@
+package screens
+
+import (
+	"strings"
+
+	"p1/pkg/keymap"
+	"p1/pkg/tui/theme"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Navigation shared by the lists, tables and the viewport of every screen.
+// The half page bindings use ctrl+d and ctrl+u because screens use "d" and
+// "u" for their own actions.
+var (
+	listUpKey           = keymap.Register("list.up", keymap.SCOPE_LIST, "Up", "up", "k")
+	listDownKey         = keymap.Register("list.down", keymap.SCOPE_LIST, "Down", "down", "j")
+	listPageUpKey       = keymap.Register("list.page_up", keymap.SCOPE_LIST, "Page Up", "pgup", "b")
+	listPageDownKey     = keymap.Register("list.page_down", keymap.SCOPE_LIST, "Page Down", "pgdown", "f")
+	listHalfPageUpKey   = keymap.Register("list.half_page_up", keymap.SCOPE_LIST, "½ Page Up", "ctrl+u")
+	listHalfPageDownKey = keymap.Register("list.half_page_down", keymap.SCOPE_LIST, "½ Page Down", "ctrl+d")
+	listTopKey          = keymap.Register("list.top", keymap.SCOPE_LIST, "Top", "home", "g")
+	listBottomKey       = keymap.Register("list.bottom", keymap.SCOPE_LIST, "Bottom", "end", "G")
+)
+
+// newTable creates a focused table styled with the theme colors and moved
+// with the list bindings.
+func newTable(t theme.Theme, columns []table.Column) table.Model {
+	keys := table.KeyMap{
+		LineUp:       listUpKey.Binding,
+		LineDown:     listDownKey.Binding,
+		PageUp:       listPageUpKey.Binding,
+		PageDown:     listPageDownKey.Binding,
+		HalfPageUp:   listHalfPageUpKey.Binding,
+		HalfPageDown: listHalfPageDownKey.Binding,
+		GotoTop:      listTopKey.Binding,
+		GotoBottom:   listBottomKey.Binding,
+	}
+
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithKeyMap(keys),
+		table.WithStyles(tableStyles(t)),
+		table.WithHeight(10),
+	)
+}
+
+func tableStyles(t theme.Theme) table.Styles {
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(t.Border()).
+		BorderBottom(true).
+		Foreground(t.Accent()).
+		Bold(true)
+	styles.Selected = styles.Selected.Foreground(t.Highlight()).Bold(true)
+	styles.Cell = styles.Cell.Foreground(t.Body())
+	return styles
+}
+
+// isLeftClick reports whether msg is a press of the left mouse button.
+func isLeftClick(msg tea.MouseMsg) bool {
+	return msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft
+}
+
+// rowMarker tags the selected row in the copy rendered by tableRowAt.
+const rowMarker = "\uE000"
+
+// tableRowAt returns the row shown on line y of the table's view. The table
+// keeps how far it scrolled to itself, so the line of the cursor is looked
+// up in a copy that marks the selected row and the rest is counted from it.
+func tableRowAt(t table.Model, styles table.Styles, y int) (int, bool) {
+	styles.Selected = styles.Selected.Transform(func(row string) string {
+		return rowMarker + row
+	})
+	t.SetStyles(styles)
+
+	lines := strings.Split(t.View(), "\n")
+	headerHeight := len(lines) - t.Height()
+	if y < headerHeight || y >= len(lines) {
+		return 0, false
+	}
+	for i, line := range lines {
+		if strings.Contains(line, rowMarker) {
+			row := t.Cursor() + y - i
+			return row, row >= 0 && row < len(t.Rows())
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// AUTH_SCHEME prefixes the token in the Authorization header of the handshake.
+const AUTH_SCHEME = "Bearer "
+
+// authenticate returns the token the handshake r carries and whether the
+// server accepts it.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), AUTH_SCHEME)
+	return token, s.accepts(token)
+}
+
+// accepts reports whether token is one of the auth tokens. Every token is
+// accepted while there are none.
+func (s *Server) accepts(token string) bool {
+	settings, _ := s.currentSettings()
+	if len(settings.AuthTokens) == 0 {
+		return true
+	}
+	for _, accepted := range settings.AuthTokens {
+		if subtle.ConstantTimeCompare([]byte(accepted), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// closeRevoked closes conn as soon as a reconfiguration no longer accepts the
+// token it was opened with, until done is closed.
+func (s *Server) closeRevoked(conn *websocket.Conn, token string, done <-chan struct{}) {
+	for {
+		_, reconfigured := s.currentSettings()
+		if !s.accepts(token) {
+			slog.Info("auth token revoked, closing the connection", "remote", conn.RemoteAddr())
+			conn.Close()
+			return
+		}
+		select {
+		case <-done:
+			return
+		case <-reconfigured:
+		}
+	}
+}
+
+// authenticatePeer adds the token this server presents to its peers to
+// headers. Federated servers share their tokens, so it is the first one.
+func (s *Server) authenticatePeer(headers http.Header) {
+	settings, _ := s.currentSettings()
+	if len(settings.AuthTokens) > 0 {
+		headers.Set("Authorization", AUTH_SCHEME+settings.AuthTokens[0])
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAuthTokens(t *testing.T) {
+	s := New(ServerOptions{AuthTokens: []string{"secret"}})
+	defer s.cancel()
+	srv := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	defer srv.Close()
+	link := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	dial := func(token string) (*websocket.Conn, int) {
+		headers := http.Header{}
+		if token != "" {
+			headers.Set("Authorization", AUTH_SCHEME+token)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(link, headers)
+		if resp == nil {
+			t.Fatalf("dial: %v", err)
+		}
+		if err != nil {
+			return nil, resp.StatusCode
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn, resp.StatusCode
+	}
+
+	for _, token := range []string{"", "guessed"} {
+		if _, status := dial(token); status != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want %d", token, status, http.StatusUnauthorized)
+		}
+	}
+	conn, status := dial("secret")
+	if conn == nil {
+		t.Fatalf("accepted token: status %d", status)
+	}
+	sessionInfo(t, readMessages(t, conn, 1)[0])
+
+	// revoking the token closes the connection made with it
+	s.Reconfigure(Settings{AuthTokens: []string{"rotated"}})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatal("connection with the revoked token is still open")
+		}
+		if err != nil {
+			break
+		}
+	}
+	if conn, status := dial("rotated"); conn == nil {
+		t.Errorf("new token: status %d", status)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"p1/pkg/messages"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+type Broker struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// validate checks that the broker has a name and an absolute URL.
+func (b *Broker) validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("broker name is required")
+	}
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return fmt.Errorf("invalid broker url %q: %w", b.URL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid broker url %q: scheme and host are required", b.URL)
+	}
+	return nil
+}
+
+// listBrokers returns the registered brokers sorted by name.
+func (s *Server) listBrokers() []*Broker {
+	s.mu.RLock()
+	brokers := make([]*Broker, 0, len(s.brokers))
+	for _, broker := range s.brokers {
+		brokers = append(brokers, broker)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(brokers, func(a, b *Broker) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return brokers
+}
+
+// registerBroker adds a broker, or updates the one with the same ID.
+func (s *Server) registerBroker(msg messages.Message) error {
+	var broker Broker
+	if err := msg.DecodePayload(&broker); err != nil {
+		return err
+	}
+	if err := broker.validate(); err != nil {
+		return err
+	}
+	if broker.ID == "" {
+		broker.ID = uuid.New().String()
+	}
+
+	s.mu.Lock()
+	s.brokers[broker.ID] = &broker
+	s.mu.Unlock()
+	slog.Info("broker registered", "broker", broker.ID, "name", broker.Name, "url", broker.URL)
+	s.brokersChanged()
+	return nil
+}
+
+// removeBroker deletes the broker whose ID is the payload of msg.
+func (s *Server) removeBroker(msg messages.Message) error {
+	id, ok := msg.Payload.(string)
+	if !ok {
+		return fmt.Errorf("invalid broker id %v", msg.Payload)
+	}
+
+	s.mu.Lock()
+	delete(s.brokers, id)
+	s.mu.Unlock()
+	slog.Info("broker removed", "broker", id)
+	s.brokersChanged()
+	return nil
+}
+
+// brokersChanged pushes the broker list to every client.
+func (s *Server) brokersChanged() {
+	s.broadcast(nil, messages.Message{
+		Type:    messages.TypeListBrokers,
+		Payload: s.listBrokers(),
+		Sender:  s.ID,
+	})
+}
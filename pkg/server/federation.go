@@ -0,0 +1,311 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"p1/pkg/messages"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	INITIAL_PEER_DELAY = 1 * time.Second
+	PEER_SYNC_INTERVAL = 5 * time.Second
+	PEER_TIMEOUT       = 3 * PEER_SYNC_INTERVAL // Peers not heard from for this long are dropped
+	PEER_MAX_BACKOFF   = 30 * time.Second
+)
+
+// PeerState is the payload of a TypePeerSync message. Servers only ever send
+// their own registry, so entries are never forwarded more than one hop.
+type PeerState struct {
+	ServerID string                  `json:"server_id"`
+	Address  string                  `json:"address"`
+	Services []*Service              `json:"services"`
+	Metrics  *messages.ServerMetrics `json:"metrics"`
+	Reply    bool                    `json:"reply"` // Set on answers, which are not answered again
+}
+
+// peer is what a server knows about another member of the federation.
+type peer struct {
+	state    PeerState
+	lastSeen time.Time
+}
+
+// localState builds the PeerState this server announces to its peers. The
+// services are copied, health checks update them in place.
+func (s *Server) localState(reply bool) PeerState {
+	s.mu.RLock()
+	services := make([]*Service, 0, len(s.services))
+	for _, svc := range s.services {
+		copied := *svc
+		services = append(services, &copied)
+	}
+	s.mu.RUnlock()
+
+	return PeerState{
+		ServerID: s.ID,
+		Address:  s.Address,
+		Services: services,
+		Metrics:  collectMetrics(),
+		Reply:    reply,
+	}
+}
+
+// updatePeer records the state a peer announced.
+func (s *Server) updatePeer(state PeerState) {
+	if state.ServerID == "" || state.ServerID == s.ID {
+		return
+	}
+
+	s.peersMu.Lock()
+	previous, known := s.peers[state.ServerID]
+	s.peers[state.ServerID] = &peer{state: state, lastSeen: time.Now()}
+	s.peersMu.Unlock()
+
+	if !known {
+		slog.Info("peer joined", "peer", state.ServerID, "address", state.Address, "services", len(state.Services))
+	}
+	if !known || !reflect.DeepEqual(previous.state.Services, state.Services) {
+		s.broadcast(nil, messages.Message{
+			Type:    messages.TypeListServices,
+			Payload: s.federatedServices(),
+			Sender:  s.ID,
+		})
+	}
+}
+
+// handlePeerSync answers a peer that pushed its state with our own.
+func (s *Server) handlePeerSync(sess *session, msg messages.Message) {
+	var state PeerState
+	if err := msg.DecodePayload(&state); err != nil {
+		slog.Error("invalid peer state", "error", err)
+		return
+	}
+	s.updatePeer(state)
+	if !state.Reply {
+		sess.send(messages.Message{
+			Type:    messages.TypePeerSync,
+			Payload: s.localState(true),
+			Sender:  s.ID,
+		})
+	}
+}
+
+// federatedServices merges the local registry with what the peers announced.
+// Every entry is marked with the server that owns it. When several servers
+// claim the same service ID, the local entry wins, otherwise the peer with the
+// lowest ID does; the other claimants are listed as conflicts.
+func (s *Server) federatedServices() []*Service {
+	claims := make(map[string][]*Service)
+
+	s.mu.RLock()
+	for _, svc := range s.services {
+		owned := *svc
+		owned.Owner = s.ID
+		owned.Conflicts = nil
+		claims[svc.ID] = append(claims[svc.ID], &owned)
+	}
+	s.mu.RUnlock()
+
+	s.peersMu.RLock()
+	peerIds := make([]string, 0, len(s.peers))
+	for id := range s.peers {
+		peerIds = append(peerIds, id)
+	}
+	sort.Strings(peerIds)
+	for _, id := range peerIds {
+		for _, svc := range s.peers[id].state.Services {
+			owned := *svc
+			owned.Owner = id
+			owned.Conflicts = nil
+			claims[svc.ID] = append(claims[svc.ID], &owned)
+		}
+	}
+	s.peersMu.RUnlock()
+
+	services := make([]*Service, 0, len(claims))
+	for _, claimants := range claims {
+		winner := claimants[0]
+		for _, other := range claimants[1:] {
+			winner.Conflicts = append(winner.Conflicts, other.Owner)
+		}
+		if len(winner.Conflicts) > 0 {
+			slog.Warn("service claimed by several servers", "service", winner.ID, "owner", winner.Owner, "conflicts", winner.Conflicts)
+		}
+		services = append(services, winner)
+	}
+	slices.SortFunc(services, func(a, b *Service) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return services
+}
+
+// connectPeer keeps a connection to the peer at link open until the server
+// shuts down, pushing the local state every PEER_SYNC_INTERVAL and whenever
+// kick fires because the registry changed.
+func (s *Server) connectPeer(link string, kick chan struct{}) {
+	delay := INITIAL_PEER_DELAY
+	for {
+		connected, err := s.syncWithPeer(link, kick)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = INITIAL_PEER_DELAY
+		}
+		slog.Warn("lost connection to peer", "peer", link, "error", err, "retry", delay)
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, PEER_MAX_BACKOFF)
+	}
+}
+
+// syncWithPeer runs one connection to the peer at link and reports whether
+// the connection had been established before it failed.
+func (s *Server) syncWithPeer(link string, kick chan struct{}) (bool, error) {
+	headers := http.Header{}
+	headers.Add("X-Peer-Id", s.ID)
+	s.authenticatePeer(headers)
+	dialer := websocket.Dialer{HandshakeTimeout: PEER_SYNC_INTERVAL}
+	conn, _, err := dialer.DialContext(s.ctx, link, headers)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	slog.Info("connected to peer", "peer", link)
+
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			var msg messages.Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				continue
+			}
+			if msg.Type != messages.TypePeerSync {
+				continue
+			}
+			var state PeerState
+			if err := msg.DecodePayload(&state); err != nil {
+				slog.Error("invalid peer state", "peer", link, "error", err)
+				continue
+			}
+			s.updatePeer(state)
+		}
+	}()
+
+	ticker := time.NewTicker(PEER_SYNC_INTERVAL)
+	defer ticker.Stop()
+	for {
+		conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+		err := conn.WriteJSON(messages.Message{
+			Type:    messages.TypePeerSync,
+			Payload: s.localState(false),
+			Sender:  s.ID,
+		})
+		if err != nil {
+			return true, err
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return true, nil
+		case err := <-errs:
+			return true, err
+		case <-ticker.C:
+		case <-kick:
+		}
+	}
+}
+
+// notifyPeers triggers an immediate sync after the local registry changed.
+// Peers this server dialed are woken up, peers that dialed in are pushed the
+// new state directly.
+func (s *Server) notifyPeers() {
+	for _, kick := range s.peerKicks {
+		select {
+		case kick <- struct{}{}:
+		default:
+		}
+	}
+
+	s.peersMu.RLock()
+	inbound := make([]*session, 0, len(s.inbound))
+	for _, sess := range s.inbound {
+		inbound = append(inbound, sess)
+	}
+	s.peersMu.RUnlock()
+
+	if len(inbound) == 0 {
+		return
+	}
+	state := s.localState(true)
+	for _, sess := range inbound {
+		sess.send(messages.Message{
+			Type:    messages.TypePeerSync,
+			Payload: state,
+			Sender:  s.ID,
+		})
+	}
+}
+
+func (s *Server) addInboundPeer(id string, sess *session) {
+	s.peersMu.Lock()
+	defer s.peersMu.Unlock()
+	s.inbound[id] = sess
+}
+
+func (s *Server) removeInboundPeer(id string, sess *session) {
+	s.peersMu.Lock()
+	defer s.peersMu.Unlock()
+	if s.inbound[id] == sess {
+		delete(s.inbound, id)
+	}
+}
+
+// reapPeers drops peers that have not synced within PEER_TIMEOUT, which
+// removes their services from the federated view.
+func (s *Server) reapPeers() {
+	ticker := time.NewTicker(PEER_SYNC_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			lost := false
+			s.peersMu.Lock()
+			for id, p := range s.peers {
+				if now.Sub(p.lastSeen) > PEER_TIMEOUT {
+					slog.Warn("peer lost", "peer", id, "address", p.state.Address, "services", len(p.state.Services))
+					delete(s.peers, id)
+					lost = true
+				}
+			}
+			s.peersMu.Unlock()
+
+			if lost {
+				s.broadcast(nil, messages.Message{
+					Type:    messages.TypeListServices,
+					Payload: s.federatedServices(),
+					Sender:  s.ID,
+				})
+			}
+		}
+	}
+}
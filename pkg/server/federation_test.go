@@ -0,0 +1,116 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"p1/pkg/messages"
+)
+
+func federationServer(local []*Service, peers map[string][]*Service) *Server {
+	s := &Server{
+		ID:       "local",
+		services: map[string]*Service{},
+		sessions: map[string]*session{},
+		peers:    map[string]*peer{},
+	}
+	for _, svc := range local {
+		s.services[svc.ID] = svc
+	}
+	for id, services := range peers {
+		s.peers[id] = &peer{state: PeerState{ServerID: id, Services: services}, lastSeen: time.Now()}
+	}
+	return s
+}
+
+func TestFederatedServices(t *testing.T) {
+	type entry struct {
+		owner     string
+		conflicts []string
+	}
+	tests := []struct {
+		name  string
+		local []*Service
+		peers map[string][]*Service
+		want  map[string]entry // By service ID
+	}{
+		{
+			name:  "local only",
+			local: []*Service{{ID: "a", Name: "a"}},
+			want:  map[string]entry{"a": {owner: "local"}},
+		},
+		{
+			name:  "distinct services",
+			local: []*Service{{ID: "a", Name: "a"}},
+			peers: map[string][]*Service{"p1": {{ID: "b", Name: "b"}}},
+			want:  map[string]entry{"a": {owner: "local"}, "b": {owner: "p1"}},
+		},
+		{
+			name:  "local entry wins",
+			local: []*Service{{ID: "a", Name: "a"}},
+			peers: map[string][]*Service{"p2": {{ID: "a", Name: "a"}}, "p1": {{ID: "a", Name: "a"}}},
+			want:  map[string]entry{"a": {owner: "local", conflicts: []string{"p1", "p2"}}},
+		},
+		{
+			name:  "lowest peer ID wins",
+			peers: map[string][]*Service{"p2": {{ID: "a", Name: "a"}}, "p1": {{ID: "a", Name: "a"}}},
+			want:  map[string]entry{"a": {owner: "p1", conflicts: []string{"p2"}}},
+		},
+		{
+			name:  "stale conflicts are dropped",
+			peers: map[string][]*Service{"p1": {{ID: "a", Name: "a", Owner: "x", Conflicts: []string{"y"}}}},
+			want:  map[string]entry{"a": {owner: "p1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := federationServer(tt.local, tt.peers)
+			got := map[string]entry{}
+			for _, svc := range s.federatedServices() {
+				got[svc.ID] = entry{owner: svc.Owner, conflicts: svc.Conflicts}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("federated services = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFederatedServicesLeaveRegistryAlone(t *testing.T) {
+	svc := &Service{ID: "a", Name: "a"}
+	s := federationServer([]*Service{svc}, map[string][]*Service{"p1": {{ID: "a"}}})
+	s.federatedServices()
+	if svc.Owner != "" || svc.Conflicts != nil {
+		t.Errorf("registry entry was changed to %+v", svc)
+	}
+}
+
+func TestLocalStateCopiesServices(t *testing.T) {
+	svc := &Service{ID: "a", Name: "a", Health: "healthy"}
+	s := federationServer([]*Service{svc}, nil)
+	state := s.localState(false)
+	svc.Health = "unhealthy"
+	if state.Services[0].Health != "healthy" {
+		t.Errorf("announced health = %q, want the one at the time of the sync", state.Services[0].Health)
+	}
+}
+
+func TestBroadcastSkipsPeers(t *testing.T) {
+	s := federationServer(nil, nil)
+	client, peer, sender := newSession("client"), newSession("peer"), newSession("sender")
+	peer.identify(messages.ClientInfo{}, true)
+	for _, sess := range []*session{client, peer, sender} {
+		s.sessions[sess.id] = sess
+	}
+
+	s.broadcast(sender, messages.Message{Type: messages.TypeBroadcast, Payload: "hi"})
+	for _, tt := range []struct {
+		sess *session
+		want int
+	}{{client, 1}, {peer, 0}, {sender, 0}} {
+		if got := len(tt.sess.buffer); got != tt.want {
+			t.Errorf("session %s got %d messages, want %d", tt.sess.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	HEALTH_INTERVAL   = 10 * time.Second // Default time between health checks
+	HEALTH_TIMEOUT    = 3 * time.Second  // Default time a health check waits for an answer
+	MAX_HEALTH_PROBES = 16               // Endpoints probed at the same time
+)
+
+const (
+	HealthUnknown = "unknown"
+	HealthUp      = "up"
+	HealthDown    = "down"
+)
+
+// monitorHealth checks every registered service every health interval and
+// whenever checkHealthNow asks for it. Checks run one at a time.
+func (s *Server) monitorHealth() {
+	settings, reconfigured := s.currentSettings()
+	ticker := time.NewTicker(settings.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-reconfigured:
+			settings, reconfigured = s.currentSettings()
+			ticker.Reset(settings.HealthInterval)
+		case <-ticker.C:
+			s.checkHealth()
+		case <-s.healthCheck:
+			s.checkHealth()
+		}
+	}
+}
+
+// checkHealthNow asks monitorHealth for a check without waiting for the next
+// tick. Requests made while a check is pending are served by that check.
+func (s *Server) checkHealthNow() {
+	select {
+	case s.healthCheck <- struct{}{}:
+	default:
+	}
+}
+
+// checkHealth probes all local services, at the address of their health
+// check if one is configured, and publishes the registry if any of them
+// changed state.
+func (s *Server) checkHealth() {
+	settings, _ := s.currentSettings()
+	s.mu.RLock()
+	endpoints := make(map[string]string, len(s.services))
+	for id, svc := range s.services {
+		endpoints[id] = svc.Endpoint
+		if check, ok := settings.HealthChecks[svc.Name]; ok {
+			endpoints[id] = check
+		}
+	}
+	s.mu.RUnlock()
+
+	// every probe is bounded by the timeout, so a pass takes about one
+	// timeout for every MAX_HEALTH_PROBES endpoints that do not answer
+	results := make(map[string]string, len(endpoints))
+	var resultsMu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, MAX_HEALTH_PROBES)
+	for id, endpoint := range endpoints {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			health := probe(endpoint, settings.HealthTimeout)
+			resultsMu.Lock()
+			results[id] = health
+			resultsMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	changed := false
+	s.mu.Lock()
+	for id, health := range results {
+		svc, ok := s.services[id]
+		if !ok || svc.Health == health {
+			continue
+		}
+		slog.Info("service health changed", "service", id, "name", svc.Name, "from", svc.Health, "to", health)
+		svc.Health = health
+		changed = true
+	}
+	s.mu.Unlock()
+
+	if changed {
+		s.servicesChanged()
+	}
+}
+
+// probe checks a service endpoint. HTTP endpoints must answer with a status
+// below 500, anything else with a host and port must accept a TCP connection.
+func probe(endpoint string, timeout time.Duration) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		u, err = url.Parse("tcp://" + endpoint)
+		if err != nil || u.Host == "" {
+			return HealthUnknown
+		}
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		client := http.Client{Timeout: timeout}
+		resp, err := client.Get(u.String())
+		if err != nil {
+			return HealthDown
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return HealthDown
+		}
+		return HealthUp
+	default:
+		host := u.Host
+		if u.Port() == "" {
+			return HealthUnknown
+		}
+		conn, err := net.DialTimeout("tcp", host, timeout)
+		if err != nil {
+			return HealthDown
+		}
+		conn.Close()
+		return HealthUp
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckHealthProbesConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	timeout := 200 * time.Millisecond
+	s := federationServer([]*Service{
+		{ID: "up", Endpoint: up.URL},
+		{ID: "a", Endpoint: hanging.URL},
+		{ID: "b", Endpoint: hanging.URL},
+		{ID: "c", Endpoint: hanging.URL},
+		{ID: "d", Endpoint: hanging.URL},
+	}, nil)
+	s.settings = Settings{HealthTimeout: timeout}
+
+	start := time.Now()
+	s.checkHealth()
+	if elapsed := time.Since(start); elapsed > 3*timeout {
+		t.Errorf("check took %v, want about one timeout of %v", elapsed, timeout)
+	}
+	for id, svc := range s.services {
+		want := HealthDown
+		if id == "up" {
+			want = HealthUp
+		}
+		if svc.Health != want {
+			t.Errorf("health of %s = %q, want %q", id, svc.Health, want)
+		}
+	}
+}
+
+func TestCheckHealthNowCoalesces(t *testing.T) {
+	s := &Server{healthCheck: make(chan struct{}, 1)}
+	for i := 0; i < 3; i++ {
+		s.checkHealthNow()
+	}
+	if n := len(s.healthCheck); n != 1 {
+		t.Errorf("%d checks pending, want 1", n)
+	}
+}
+
+func TestCheckHealthUsesConfiguredChecks(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	s := federationServer([]*Service{
+		{ID: "a", Name: "api", Endpoint: up.URL},
+		{ID: "b", Name: "db", Endpoint: up.URL},
+	}, nil)
+	s.settings = Settings{HealthTimeout: time.Second, HealthChecks: map[string]string{"api": down.URL}}
+
+	s.checkHealth()
+	if got := s.services["a"].Health; got != HealthDown {
+		t.Errorf("health of the service with a check = %q, want %q", got, HealthDown)
+	}
+	if got := s.services["b"].Health; got != HealthUp {
+		t.Errorf("health of the service without a check = %q, want %q", got, HealthUp)
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"maps"
+	"p1/pkg/messages"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const PROJECT_EVENT_HISTORY = 20 // Events kept per project
+
+type Project struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Metadata    map[string]string `json:"metadata"`
+	Services    []string          `json:"services"` // IDs of the linked services
+	Events      []ProjectEvent    `json:"events"`   // Most recent changes, oldest first
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type ProjectEvent struct {
+	At      time.Time `json:"at"`
+	Message string    `json:"message"`
+}
+
+// listProjects returns the projects sorted by name.
+func (s *Server) listProjects() []*Project {
+	s.mu.RLock()
+	projects := make([]*Project, 0, len(s.projects))
+	for _, project := range s.projects {
+		projects = append(projects, project)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(projects, func(a, b *Project) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return projects
+}
+
+// registerProject creates a project, or updates the one with the same ID.
+// The server keeps the timestamps and the event history, whatever the
+// client sent for them is ignored.
+func (s *Server) registerProject(msg messages.Message) error {
+	var project Project
+	if err := msg.DecodePayload(&project); err != nil {
+		return err
+	}
+	if project.Name == "" {
+		return fmt.Errorf("project name is required")
+	}
+	if project.ID == "" {
+		project.ID = uuid.New().String()
+	}
+	if project.Metadata == nil {
+		project.Metadata = make(map[string]string)
+	}
+	if project.Services == nil {
+		project.Services = []string{}
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	previous, exists := s.projects[project.ID]
+	if exists {
+		project.CreatedAt = previous.CreatedAt
+		project.Events = slices.Clone(previous.Events)
+		for _, change := range projectChanges(previous, &project, s.serviceName) {
+			project.Events = append(project.Events, ProjectEvent{At: now, Message: change})
+		}
+	} else {
+		project.CreatedAt = now
+		project.Events = []ProjectEvent{{At: now, Message: "created"}}
+	}
+	if len(project.Events) > PROJECT_EVENT_HISTORY {
+		project.Events = project.Events[len(project.Events)-PROJECT_EVENT_HISTORY:]
+	}
+	project.UpdatedAt = now
+	s.projects[project.ID] = &project
+	s.mu.Unlock()
+
+	slog.Info("project registered", "project", project.ID, "name", project.Name)
+	s.projectsChanged()
+	return nil
+}
+
+// projectChanges describes what differs between two versions of a project,
+// naming linked services with name.
+func projectChanges(before, after *Project, name func(id string) string) []string {
+	changes := []string{}
+	if before.Name != after.Name {
+		changes = append(changes, fmt.Sprintf("renamed from %s to %s", before.Name, after.Name))
+	}
+	if before.Description != after.Description {
+		changes = append(changes, "description updated")
+	}
+	if !maps.Equal(before.Metadata, after.Metadata) {
+		changes = append(changes, "metadata updated")
+	}
+	linked, unlinked := []string{}, []string{}
+	for _, id := range after.Services {
+		if !slices.Contains(before.Services, id) {
+			linked = append(linked, name(id))
+		}
+	}
+	for _, id := range before.Services {
+		if !slices.Contains(after.Services, id) {
+			unlinked = append(unlinked, name(id))
+		}
+	}
+	if len(linked) > 0 {
+		changes = append(changes, "linked "+strings.Join(linked, ", "))
+	}
+	if len(unlinked) > 0 {
+		changes = append(changes, "unlinked "+strings.Join(unlinked, ", "))
+	}
+	return changes
+}
+
+// serviceName returns the name of a local service, or id if it is not
+// registered here. The caller must hold s.mu.
+func (s *Server) serviceName(id string) string {
+	if svc, ok := s.services[id]; ok {
+		return svc.Name
+	}
+	return id
+}
+
+// removeProject deletes the project whose ID is the payload of msg.
+func (s *Server) removeProject(msg messages.Message) error {
+	id, ok := msg.Payload.(string)
+	if !ok {
+		return fmt.Errorf("invalid project id %v", msg.Payload)
+	}
+
+	s.mu.Lock()
+	delete(s.projects, id)
+	s.mu.Unlock()
+	slog.Info("project removed", "project", id)
+	s.projectsChanged()
+	return nil
+}
+
+// projectsChanged pushes the project list to every client.
+func (s *Server) projectsChanged() {
+	s.broadcast(nil, messages.Message{
+		Type:    messages.TypeListProjects,
+		Payload: s.listProjects(),
+		Sender:  s.ID,
+	})
+}
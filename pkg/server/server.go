@@ -2,12 +2,15 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"p1/pkg/discovery"
+	"p1/pkg/logs"
 	"p1/pkg/messages"
 	"strconv"
 	"strings"
@@ -24,74 +27,71 @@ type Service struct {
 	Endpoint    string            `json:"endpoint"`
 	Description string            `json:"description"`
 	Metadata    map[string]string `json:"metadata"`
+	Health      string            `json:"health,omitempty"`    // Result of the last health check
+	Owner       string            `json:"owner,omitempty"`     // ID of the server the service is registered on
+	Conflicts   []string          `json:"conflicts,omitempty"` // Other servers claiming the same service ID
 }
 
-type Memory struct {
-	MemTotal uint64 `json:"mem_total"` // Total memory in bytes
-	MemFree  uint64 `json:"mem_free"`  // Free memory in bytes
-}
-
-type Network struct {
-	ID           string `json:"id"`            // Interface identifier
-	RxBytes      uint64 `json:"rx_bytes"`      // Received bytes
-	RxPackets    uint64 `json:"rx_packets"`    // Received packets
-	RxErrors     uint64 `json:"rx_errors"`     // Received errors
-	RxDropped    uint64 `json:"rx_dropped"`    // Received dropped packets
-	RxFifo       uint64 `json:"rx_fifo"`       // Received FIFO errors
-	RxFrame      uint64 `json:"rx_frame"`      // Received frame errors
-	RxCompressed uint64 `json:"rx_compressed"` // Received compressed packets
-	RxMulticast  uint64 `json:"rx_multicast"`  // Received multicast packets
-	TxBytes      uint64 `json:"tx_bytes"`      // Transmitted bytes
-	TxPackets    uint64 `json:"tx_packets"`    // Transmitted packets
-	TxErrors     uint64 `json:"tx_errors"`     // Transmitted errors
-	TxDropped    uint64 `json:"tx_dropped"`    // Transmitted dropped packets
-	TxFifo       uint64 `json:"tx_fifo"`       // Transmitted FIFO errors
-	TxFrame      uint64 `json:"tx_frame"`      // Transmitted frame errors
-	TxCompressed uint64 `json:"tx_compressed"` // Transmitted compressed packets
-	TxMulticast  uint64 `json:"tx_multicast"`  // Transmitted multicast packets
-}
-
-type Storage struct {
-	Disks []Disk `json:"disks"` // List of disks
-}
-
-type Disk struct {
-	MountPoint string `json:"mount_point"` // Mount point of the disk
-	Total      uint64 `json:"total"`       // Total space in bytes
-	Used       uint64 `json:"used"`        // Used space in bytes
-}
-
-type CPU struct {
-	User   uint64 `json:"user"`   // User CPU time
-	System uint64 `json:"system"` // System CPU time
-	Idle   uint64 `json:"idle"`   // Idle CPU time
-	Total  uint64 `json:"total"`  // Total CPU time
-}
-
-type ServerMetrics struct {
-	CPU     *CPU     `json:"cpu"`     // CPU metrics
-	Memory  *Memory  `json:"memory"`  // Memory metrics
-	Storage *Storage `json:"storage"` // Storage metrics
-	Network *Network `json:"network"` // Network metrics
+// collectMetrics takes a snapshot of the host's resource usage.
+func collectMetrics() *messages.ServerMetrics {
+	networks := getNetworks()
+	return &messages.ServerMetrics{
+		CPU:        getCPU(),
+		Memory:     getMemory(),
+		Storage:    getStorage(),
+		Network:    getNetwork(networks),
+		Interfaces: networks,
+		At:         time.Now(),
+	}
 }
 
 type Server struct {
 	ID         string
 	services   map[string]*Service // Map of registered services
+	brokers    map[string]*Broker  // Map of registered brokers
+	projects   map[string]*Project // Map of projects
 	wsUpgrader *websocket.Upgrader // WebSocket upgrader
 	Address    string              // Server address
 	WSLink     string              // WebSocket link
-	mu         sync.RWMutex        // Mutex for protecting service map
+	mu         sync.RWMutex        // Mutex for protecting service and session maps
 	srv        *http.Server        // HTTP server
 	ctx        context.Context     // Context for server lifecycle management
 	cancel     context.CancelFunc  // Cancel function for context
-	clients    []*string           // List of connected client IDs
+	sessions   map[string]*session // Client sessions by session ID, kept across reconnects
+	dataDir    string              // Directory the discovery file is written to
+	dirLock    *discovery.DirLock  // Held on dataDir while running
+	pidFile    bool                // Whether a pidfile is written next to the discovery file
+	peerLinks  []string            // Peers this server dials
+	peerKicks  []chan struct{}     // Wake the peer connections after a registry change
+	peers      map[string]*peer    // Federated peers by server ID
+	inbound    map[string]*session // Sessions of peers that dialed this server, by server ID
+	peersMu    sync.RWMutex        // Mutex for protecting the peer map
+	logs       *logs.Hub           // Log entries streamed to clients, nil if not available
+
+	settings     Settings      // Settings that can be changed while running
+	settingsMu   sync.RWMutex  // Mutex for protecting the settings
+	reconfigured chan struct{} // Closed and replaced when the settings change
+	healthCheck  chan struct{} // Asks monitorHealth for a check before the next tick
+	listening    chan struct{} // Closed once Start listens on Address
 }
 
 type ServerOptions struct {
-	Port string // Port number to listen on
+	Host    string    // Host to listen on, defaults to localhost
+	Port    string    // Port number to listen on
+	DataDir string    // Directory to announce and lock the server in, empty disables discovery
+	PIDFile bool      // Also write a pidfile to DataDir, as daemons do
+	Peers   []string  // Websocket links of peer servers to federate with
+	Logs    *logs.Hub // Source of the log entries clients can follow
+
+	MetricsInterval time.Duration     // Time between the metrics sent to each client, defaults to DEFAULT_METRICS_INTERVAL
+	HealthInterval  time.Duration     // Time between health checks, defaults to HEALTH_INTERVAL
+	HealthTimeout   time.Duration     // Time a health check waits for an answer, defaults to HEALTH_TIMEOUT
+	HealthChecks    map[string]string // Addresses probed instead of the endpoints, by service name
+	AuthTokens      []string          // Tokens clients authenticate with, none to accept every client
 }
 
+const DEFAULT_METRICS_INTERVAL = 5 * time.Second
+
 func findOpenPort() string {
 	for port := 28080; port <= 38080; port++ {
 		if isPortOpen(strconv.Itoa(port)) {
@@ -112,30 +112,75 @@ func isPortOpen(port string) bool {
 
 func New(options ServerOptions) *Server {
 	var port string
-	if options.Port == "0" || isPortOpen(port) {
+	if options.Port == "0" || !isPortOpen(options.Port) {
 		port = findOpenPort()
 	} else {
 		port = options.Port
 	}
 
+	if options.MetricsInterval <= 0 {
+		options.MetricsInterval = DEFAULT_METRICS_INTERVAL
+	}
+	if options.HealthInterval <= 0 {
+		options.HealthInterval = HEALTH_INTERVAL
+	}
+	if options.HealthTimeout <= 0 {
+		options.HealthTimeout = HEALTH_TIMEOUT
+	}
+
+	host := options.Host
+	if host == "" {
+		host = "localhost"
+	}
+	// a wildcard address can be listened on, but not dialed
+	linkHost := host
+	if host == "0.0.0.0" || host == "::" {
+		linkHost = "localhost"
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Server{
 		ID:       uuid.New().String(),
 		services: make(map[string]*Service),
+		brokers:  make(map[string]*Broker),
+		projects: make(map[string]*Project),
 		wsUpgrader: &websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
 			},
 		},
-		Address: fmt.Sprintf("localhost:%s", port),
-		WSLink:  fmt.Sprintf("ws://localhost:%s/ws", port),
-		ctx:     ctx,
-		cancel:  cancel,
-		clients: []*string{},
+		Address:   net.JoinHostPort(host, port),
+		WSLink:    fmt.Sprintf("ws://%s/ws", net.JoinHostPort(linkHost, port)),
+		ctx:       ctx,
+		cancel:    cancel,
+		sessions:  make(map[string]*session),
+		dataDir:   options.DataDir,
+		pidFile:   options.PIDFile,
+		peerLinks: options.Peers,
+		peers:     make(map[string]*peer),
+		inbound:   make(map[string]*session),
+		logs:      options.Logs,
+
+		settings: Settings{
+			MetricsInterval: options.MetricsInterval,
+			HealthInterval:  options.HealthInterval,
+			HealthTimeout:   options.HealthTimeout,
+			HealthChecks:    options.HealthChecks,
+			AuthTokens:      options.AuthTokens,
+		},
+		reconfigured: make(chan struct{}),
+		healthCheck:  make(chan struct{}, 1),
+		listening:    make(chan struct{}),
 	}
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	authToken, ok := s.authenticate(r)
+	if !ok {
+		slog.Warn("rejected a connection without a valid auth token", "remote", r.RemoteAddr)
+		http.Error(w, "invalid auth token", http.StatusUnauthorized)
+		return
+	}
 	conn, err := s.wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		slog.Error("WebSocket upgrade failed: " + err.Error())
@@ -143,36 +188,55 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	// add the client-id to the list of clients, so we can track them.
-	// the client-id is being send with the websocket-handshake
-	clientId := r.Header.Get("CID")
+	// the session-id, its token and the last sequence number the client has
+	// seen are being send with the websocket-handshake, so a reconnecting
+	// client can pick up where it left off.
+	sessionId := r.Header.Get("X-Session-Id")
+	token := r.Header.Get("X-Session-Token")
+	lastSeq, _ := strconv.ParseUint(r.Header.Get("X-Last-Seq"), 10, 64)
 
-	if clientId != "" {
-		s.clients = append(s.clients, &clientId)
+	sess, created, err := s.attachSession(conn, sessionId, token, lastSeq)
+	if err != nil {
+		slog.Error("failed to attach session", "error", err)
+		return
 	}
+	peerId := r.Header.Get("X-Peer-Id")
+	client := messages.ClientInfo{
+		ClientID: r.Header.Get("X-Client-Id"),
+		Name:     r.Header.Get("X-Client-Name"),
+	}
+	sess.identify(client, peerId != "")
+	defer func() {
+		sess.detach(conn)
+		if peerId == "" {
+			s.presenceChanged()
+		}
+	}()
+	slog.Info("client attached", "session", sess.id, "client", client.ClientID, "name", client.Name)
 
-	// Send periodic health updates
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	go func() {
-		for range ticker.C {
-			metrics := ServerMetrics{
-				CPU:     getCPU(),
-				Memory:  getMemory(),
-				Storage: getStorage(),
-				Network: getNetwork(),
-			}
-			msg := messages.Message{
-				Type:    messages.TypeMetrics,
-				Payload: metrics,
+	if peerId != "" {
+		s.addInboundPeer(peerId, sess)
+		defer s.removeInboundPeer(peerId, sess)
+	} else {
+		if created {
+			// tell the others about the new client, reconnects are not news
+			client.SessionID = sess.id
+			s.broadcast(sess, messages.Message{
+				Type:    messages.TypeClientJoined,
+				Payload: client,
 				Sender:  s.ID,
-			}
-			if err := conn.WriteJSON(msg); err != nil {
-				return
-			}
+			})
 		}
-	}()
+		s.presenceChanged()
+	}
+
+	// Send periodic health updates, peers get the metrics with the peer sync
+	done := make(chan struct{})
+	defer close(done)
+	if peerId == "" {
+		go s.sendMetrics(sess, done)
+	}
+	go s.closeRevoked(conn, authToken, done)
 
 	for {
 		var msg messages.Message
@@ -183,41 +247,38 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 		switch msg.Type {
 		case messages.TypeListServices:
-			// List registered services and send them to the client.
-			s.mu.RLock()
-			services := make([]*Service, 0, len(s.services))
-			for _, svc := range s.services {
-				services = append(services, svc)
-			}
-			s.mu.RUnlock()
-
+			// List registered services, including the ones announced by
+			// peers, and send them to the client.
 			response := messages.Message{
 				Type:    messages.TypeListServices,
-				Payload: services,
+				Payload: s.federatedServices(),
+				Sender:  s.ID,
 			}
-			conn.WriteJSON(response)
+			sess.send(response)
 
 		case messages.TypeRegisterService:
-			// Register a new service.
-			if service, ok := msg.Payload.(map[string]interface{}); ok {
-				// Convert map to Service struct
-				svc := &Service{
-					ID:          service["id"].(string),
-					Name:        service["name"].(string),
-					Endpoint:    service["endpoint"].(string),
-					Description: service["description"].(string),
-					Metadata:    make(map[string]string),
-				}
-				if metadata, ok := service["metadata"].(map[string]interface{}); ok {
-					for k, v := range metadata {
-						svc.Metadata[k] = v.(string)
-					}
-				}
-
-				s.mu.Lock()
-				s.services[svc.ID] = svc
-				s.mu.Unlock()
+			// Register a new service, or update an existing one with the same ID.
+			var svc Service
+			if err := msg.DecodePayload(&svc); err != nil {
+				slog.Error("invalid service", "error", err)
+				continue
 			}
+			if svc.ID == "" {
+				svc.ID = uuid.New().String()
+			}
+			if svc.Metadata == nil {
+				svc.Metadata = make(map[string]string)
+			}
+			svc.Health = HealthUnknown
+			svc.Owner = ""
+			svc.Conflicts = nil
+
+			s.mu.Lock()
+			s.services[svc.ID] = &svc
+			s.mu.Unlock()
+			slog.Info("service registered", "service", svc.ID, "name", svc.Name)
+			s.checkHealthNow()
+			s.servicesChanged()
 
 		case messages.TypeRemoveService:
 			// Remove a service.
@@ -225,38 +286,191 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				s.mu.Lock()
 				delete(s.services, id)
 				s.mu.Unlock()
+				slog.Info("service removed", "service", id)
+				s.servicesChanged()
+			}
+		case messages.TypeListBrokers:
+			sess.send(messages.Message{
+				Type:    messages.TypeListBrokers,
+				Payload: s.listBrokers(),
+			})
+
+		case messages.TypeRegisterBroker:
+			if err := s.registerBroker(msg); err != nil {
+				slog.Error("invalid broker", "error", err)
+			}
+
+		case messages.TypeRemoveBroker:
+			if err := s.removeBroker(msg); err != nil {
+				slog.Error("invalid broker removal", "error", err)
+			}
+
+		case messages.TypeListProjects:
+			sess.send(messages.Message{
+				Type:    messages.TypeListProjects,
+				Payload: s.listProjects(),
+			})
+
+		case messages.TypeRegisterProjects:
+			if err := s.registerProject(msg); err != nil {
+				slog.Error("invalid project", "error", err)
+			}
+
+		case messages.TypeRemoveProjects:
+			if err := s.removeProject(msg); err != nil {
+				slog.Error("invalid project removal", "error", err)
+			}
+
+		case messages.TypePeerSync:
+			// Exchange registries with a federated peer.
+			s.handlePeerSync(sess, msg)
+		case messages.TypeListClients:
+			sess.send(messages.Message{
+				Type:    messages.TypeListClients,
+				Payload: s.clients(),
+			})
+
+		case messages.TypeFollowLogs:
+			var follow bool
+			if err := msg.DecodePayload(&follow); err != nil {
+				slog.Error("invalid log subscription", "error", err)
+				continue
+			}
+			if s.logs == nil {
+				continue
 			}
+			sess.followLogs(follow)
+			if follow {
+				sess.stream(messages.Message{
+					Type:    messages.TypeLogs,
+					Payload: s.logs.Recent(),
+					Sender:  s.ID,
+				})
+			}
+
 		case messages.TypeBroadcast:
-			// Broadcast a message to all connected clients.
-			if msg.Payload == nil {
-				slog.Error("msg.Payload is nil")
-				return
+			// Relay a chat message to all connected clients, the sender
+			// included so every client shows the same scrollback.
+			var chat messages.Chat
+			if text, ok := msg.Payload.(string); ok {
+				chat.Text = text
+			} else if err := msg.DecodePayload(&chat); err != nil {
+				slog.Error("invalid broadcast", "error", err)
+				continue
 			}
-			payload := msg.Payload.(string)
-			for _, clientId := range s.clients {
-				if clientId != nil {
-					// broadcast to all clients except the sender
-					if *clientId != msg.Sender {
-						msg := messages.Message{
-							Type:    messages.TypeBroadcast,
-							Payload: payload,
-							Sender:  *clientId,
-						}
-						// send the message to the client
-						if err := conn.WriteJSON(msg); err != nil {
-							return
-						}
-					}
-				}
+			if chat.ID == "" {
+				chat.ID = uuid.New().String()
 			}
+			chat.From, _ = sess.presence()
+			chat.At = time.Now()
+			s.broadcast(nil, messages.Message{
+				Type:    messages.TypeBroadcast,
+				Payload: chat,
+				Sender:  sess.id,
+			})
 		}
 	}
 }
 
+// servicesChanged pushes the federated service list to every client and the
+// local registry to every peer.
+func (s *Server) servicesChanged() {
+	s.broadcast(nil, messages.Message{
+		Type:    messages.TypeListServices,
+		Payload: s.federatedServices(),
+		Sender:  s.ID,
+	})
+	s.notifyPeers()
+}
+
+// sendMetrics sends the metrics to sess every metrics interval until done is
+// closed.
+func (s *Server) sendMetrics(sess *session, done <-chan struct{}) {
+	settings, reconfigured := s.currentSettings()
+	ticker := time.NewTicker(settings.MetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-reconfigured:
+			settings, reconfigured = s.currentSettings()
+			ticker.Reset(settings.MetricsInterval)
+			continue
+		case <-ticker.C:
+		}
+		msg := messages.Message{
+			Type:    messages.TypeMetrics,
+			Payload: collectMetrics(),
+			Sender:  s.ID,
+		}
+		if err := sess.send(msg); err != nil {
+			return
+		}
+	}
+}
+
+// broadcast sends msg to every client session except the sender. Detached
+// sessions receive it into their replay buffer. Peers only take part in the
+// peer sync and get nothing. The sessions are written to in parallel, so a
+// slow client holds up nobody but itself.
+func (s *Server) broadcast(sender *session, msg messages.Message) {
+	s.mu.RLock()
+	recipients := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		if sess != sender && !sess.isPeer() {
+			recipients = append(recipients, sess)
+		}
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, sess := range recipients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sess.send(msg); err != nil {
+				slog.Error("failed to broadcast", "session", sess.id, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func (s *Server) Start() error {
+	// only one server may announce itself in a data dir
+	if s.dataDir != "" {
+		lock, err := discovery.Lock(s.dataDir)
+		if errors.Is(err, discovery.ErrLocked) {
+			if info, readErr := discovery.Read(s.dataDir); readErr == nil {
+				return fmt.Errorf("%w in %s with pid %d at %s", err, s.dataDir, info.PID, info.Address)
+			}
+			return fmt.Errorf("%w in %s", err, s.dataDir)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock %s: %w", s.dataDir, err)
+		}
+		s.mu.Lock()
+		s.dirLock = lock
+		s.mu.Unlock()
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", s.handleWebSocket)
 
+	go s.reapSessions()
+	go s.reapPeers()
+	go s.monitorHealth()
+	if s.logs != nil {
+		go s.streamLogs()
+	}
+	for _, link := range s.peerLinks {
+		kick := make(chan struct{}, 1)
+		s.peerKicks = append(s.peerKicks, kick)
+		go s.connectPeer(link, kick)
+	}
+
 	s.srv = &http.Server{
 		Addr:    s.Address,
 		Handler: mux,
@@ -271,67 +485,136 @@ func (s *Server) Start() error {
 		}
 	}()
 
-	if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
+	ln, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	close(s.listening)
+
+	if s.dataDir != "" {
+		_, port, _ := net.SplitHostPort(s.Address)
+		portNumber, _ := strconv.Atoi(port)
+		info := discovery.ServerInfo{
+			ID:        s.ID,
+			Address:   s.Address,
+			Port:      portNumber,
+			WSLink:    s.WSLink,
+			PID:       os.Getpid(),
+			StartedAt: time.Now(),
+		}
+		if err := discovery.Write(s.dataDir, info); err != nil {
+			slog.Error("failed to write discovery file", "error", err)
+		}
+		if s.pidFile {
+			if err := discovery.WritePID(s.dataDir); err != nil {
+				slog.Error("failed to write pidfile", "error", err)
+			}
+		}
+	}
+
+	if err := s.srv.Serve(ln); err != http.ErrServerClosed {
 		return err
 	}
 	time.Sleep(500 * time.Millisecond)
 	return nil
 }
 
+// Listening returns a channel that is closed once the server accepts
+// connections, clients started next to it wait for it before dialing.
+func (s *Server) Listening() <-chan struct{} {
+	return s.listening
+}
+
 // Shutdown gracefully shuts down the server.
 func (s *Server) Shutdown() {
 	slog.Info("Shutting down server")
+	if s.dataDir != "" {
+		if err := discovery.Remove(s.dataDir, s.ID); err != nil {
+			slog.Error("failed to remove discovery file", "error", err)
+		}
+		if s.pidFile {
+			if err := discovery.RemovePID(s.dataDir); err != nil {
+				slog.Error("failed to remove pidfile", "error", err)
+			}
+		}
+	}
+	s.mu.Lock()
+	if s.dirLock != nil {
+		s.dirLock.Unlock()
+		s.dirLock = nil
+	}
+	s.mu.Unlock()
 	s.cancel()
 }
 
-// getCPU retrieves CPU usage statistics from /proc/stat.
-func getCPU() *CPU {
+// getCPU retrieves CPU usage statistics from /proc/stat. The aggregate line
+// is returned with the per-core lines attached as Cores.
+func getCPU() *messages.CPU {
 	contents, err := os.ReadFile("/proc/stat")
 	if err != nil {
 		slog.Error("Failed to read /proc/stat", "error", err)
 		return nil
 	}
 
+	var aggregate *messages.CPU
+	cores := []messages.CPU{}
 	lines := strings.Split(string(contents), "\n")
 	for _, line := range lines {
 		fields := strings.Fields(line)
-		if len(fields) < 5 || fields[0] != "cpu" {
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
 			continue
 		}
 
-		user, err := strconv.ParseUint(fields[1], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse user CPU time", "error", err)
+		cpu := parseCPU(fields)
+		if cpu == nil {
 			return nil
 		}
-
-		system, err := strconv.ParseUint(fields[3], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse system CPU time", "error", err)
-			return nil
+		if fields[0] == "cpu" {
+			aggregate = cpu
+		} else {
+			cores = append(cores, *cpu)
 		}
+	}
 
-		idle, err := strconv.ParseUint(fields[4], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse idle CPU time", "error", err)
-			return nil
-		}
+	if aggregate == nil {
+		slog.Error("No CPU stats found")
+		return nil
+	}
+	aggregate.Cores = cores
+	return aggregate
+}
 
-		total := user + system + idle
-		return &CPU{
-			User:   user,
-			System: system,
-			Idle:   idle,
-			Total:  total,
-		}
+// parseCPU parses one "cpu" line of /proc/stat.
+func parseCPU(fields []string) *messages.CPU {
+	user, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse user CPU time", "error", err)
+		return nil
 	}
 
-	slog.Error("No CPU stats found")
-	return nil
+	system, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse system CPU time", "error", err)
+		return nil
+	}
+
+	idle, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse idle CPU time", "error", err)
+		return nil
+	}
+
+	total := user + system + idle
+	return &messages.CPU{
+		User:   user,
+		System: system,
+		Idle:   idle,
+		Total:  total,
+	}
 }
 
 // getMemory retrieves memory usage statistics from /proc/meminfo.
-func getMemory() *Memory {
+func getMemory() *messages.Memory {
 	contents, err := os.ReadFile("/proc/meminfo")
 	if err != nil {
 		slog.Error("Failed to read /proc/meminfo", "error", err)
@@ -369,14 +652,14 @@ func getMemory() *Memory {
 		return nil
 	}
 
-	return &Memory{
+	return &messages.Memory{
 		MemTotal: memTotal,
 		MemFree:  memFree,
 	}
 }
 
 // getStorage retrieves storage usage statistics using the "df" command.
-func getStorage() *Storage {
+func getStorage() *messages.Storage {
 	cmd := exec.Command("df", "-BG") // Use "df -BG" to get sizes in GB
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -385,7 +668,7 @@ func getStorage() *Storage {
 	}
 
 	lines := strings.Split(string(output), "\n")
-	var disks []Disk
+	var disks []messages.Disk
 
 	// Skip header line
 	for i := 1; i < len(lines); i++ {
@@ -410,153 +693,177 @@ func getStorage() *Storage {
 			continue
 		}
 
-		disks = append(disks, Disk{
+		disks = append(disks, messages.Disk{
 			MountPoint: mountPoint,
 			Total:      total * 1024 * 1024 * 1024, // Convert GB to Bytes
 			Used:       used * 1024 * 1024 * 1024,  // Convert GB to Bytes
 		})
 	}
 
-	return &Storage{
+	return &messages.Storage{
 		Disks: disks,
 	}
 }
 
-// getNetwork retrieves network interface statistics from /proc/net/dev.
-func getNetwork() *Network {
+// getNetworks retrieves statistics for every network interface from /proc/net/dev.
+func getNetworks() []messages.Network {
 	contents, err := os.ReadFile("/proc/net/dev")
 	if err != nil {
 		slog.Error("Failed to read /proc/net/dev", "error", err)
 		return nil
 	}
 
+	networks := []messages.Network{}
 	lines := strings.Split(string(contents), "\n")
 	for _, line := range lines {
 		fields := strings.Fields(line)
 		if len(fields) < 17 {
 			continue
 		}
-		if !strings.Contains(fields[0], "lo") {
-			continue
-		}
-		//remove ":" from the interface name
-		networkInterface := strings.ReplaceAll(fields[0], ":", "")
-		rxBytes, err := strconv.ParseUint(fields[1], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxbytes", "error", err)
-			return nil
+		if network := parseNetwork(fields); network != nil {
+			networks = append(networks, *network)
 		}
+	}
 
-		rxPackets, err := strconv.ParseUint(fields[2], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxpackets", "error", err)
-			return nil
-		}
+	return networks
+}
 
-		rxErrors, err := strconv.ParseUint(fields[3], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxerrors", "error", err)
-			return nil
+// getNetwork sums up the statistics of all interfaces except loopback.
+func getNetwork(networks []messages.Network) *messages.Network {
+	total := &messages.Network{ID: "total"}
+	for _, n := range networks {
+		if n.ID == "lo" {
+			continue
 		}
+		total.RxBytes += n.RxBytes
+		total.RxPackets += n.RxPackets
+		total.RxErrors += n.RxErrors
+		total.RxDropped += n.RxDropped
+		total.TxBytes += n.TxBytes
+		total.TxPackets += n.TxPackets
+		total.TxErrors += n.TxErrors
+		total.TxDropped += n.TxDropped
+	}
+	return total
+}
 
-		rxDropped, err := strconv.ParseUint(fields[4], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxdropped", "error", err)
-			return nil
-		}
+// parseNetwork parses one interface line of /proc/net/dev.
+func parseNetwork(fields []string) *messages.Network {
+	//remove ":" from the interface name
+	networkInterface := strings.ReplaceAll(fields[0], ":", "")
+	rxBytes, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxbytes", "error", err)
+		return nil
+	}
 
-		rxFifo, err := strconv.ParseUint(fields[5], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxfifo", "error", err)
-			return nil
-		}
+	rxPackets, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxpackets", "error", err)
+		return nil
+	}
 
-		rxFrame, err := strconv.ParseUint(fields[6], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxframe", "error", err)
-			return nil
-		}
+	rxErrors, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxerrors", "error", err)
+		return nil
+	}
 
-		rxCompressed, err := strconv.ParseUint(fields[7], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxcompressed", "error", err)
-			return nil
-		}
+	rxDropped, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxdropped", "error", err)
+		return nil
+	}
 
-		rxMulticast, err := strconv.ParseUint(fields[8], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse rxmulticast", "error", err)
-			return nil
-		}
+	rxFifo, err := strconv.ParseUint(fields[5], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxfifo", "error", err)
+		return nil
+	}
 
-		txBytes, err := strconv.ParseUint(fields[9], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txbytes", "error", err)
-			return nil
-		}
+	rxFrame, err := strconv.ParseUint(fields[6], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxframe", "error", err)
+		return nil
+	}
 
-		txPackets, err := strconv.ParseUint(fields[10], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txpackets", "error", err)
-			return nil
-		}
+	rxCompressed, err := strconv.ParseUint(fields[7], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxcompressed", "error", err)
+		return nil
+	}
 
-		txErrors, err := strconv.ParseUint(fields[11], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txerrors", "error", err)
-			return nil
-		}
+	rxMulticast, err := strconv.ParseUint(fields[8], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse rxmulticast", "error", err)
+		return nil
+	}
 
-		txDropped, err := strconv.ParseUint(fields[12], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txdropped", "error", err)
-			return nil
-		}
+	txBytes, err := strconv.ParseUint(fields[9], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txbytes", "error", err)
+		return nil
+	}
 
-		txFifo, err := strconv.ParseUint(fields[13], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txfifo", "error", err)
-			return nil
-		}
+	txPackets, err := strconv.ParseUint(fields[10], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txpackets", "error", err)
+		return nil
+	}
 
-		txFrame, err := strconv.ParseUint(fields[14], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txframe", "error", err)
-			return nil
-		}
+	txErrors, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txerrors", "error", err)
+		return nil
+	}
 
-		txCompressed, err := strconv.ParseUint(fields[15], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txcompressed", "error", err)
-			return nil
-		}
+	txDropped, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txdropped", "error", err)
+		return nil
+	}
 
-		txMulticast, err := strconv.ParseUint(fields[16], 10, 64)
-		if err != nil {
-			slog.Error("Failed to parse txmulticast", "error", err)
-			return nil
-		}
+	txFifo, err := strconv.ParseUint(fields[13], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txfifo", "error", err)
+		return nil
+	}
 
-		return &Network{
-			ID:           networkInterface,
-			RxBytes:      rxBytes,
-			RxPackets:    rxPackets,
-			RxErrors:     rxErrors,
-			RxDropped:    rxDropped,
-			RxFifo:       rxFifo,
-			RxFrame:      rxFrame,
-			RxCompressed: rxCompressed,
-			RxMulticast:  rxMulticast,
-			TxBytes:      txBytes,
-			TxPackets:    txPackets,
-			TxErrors:     txErrors,
-			TxDropped:    txDropped,
-			TxFifo:       txFifo,
-			TxFrame:      txFrame,
-			TxCompressed: txCompressed,
-			TxMulticast:  txMulticast,
-		}
+	txFrame, err := strconv.ParseUint(fields[14], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txframe", "error", err)
+		return nil
 	}
 
-	return nil
+	txCompressed, err := strconv.ParseUint(fields[15], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txcompressed", "error", err)
+		return nil
+	}
+
+	txMulticast, err := strconv.ParseUint(fields[16], 10, 64)
+	if err != nil {
+		slog.Error("Failed to parse txmulticast", "error", err)
+		return nil
+	}
+
+	return &messages.Network{
+		ID:           networkInterface,
+		RxBytes:      rxBytes,
+		RxPackets:    rxPackets,
+		RxErrors:     rxErrors,
+		RxDropped:    rxDropped,
+		RxFifo:       rxFifo,
+		RxFrame:      rxFrame,
+		RxCompressed: rxCompressed,
+		RxMulticast:  rxMulticast,
+		TxBytes:      txBytes,
+		TxPackets:    txPackets,
+		TxErrors:     txErrors,
+		TxDropped:    txDropped,
+		TxFifo:       txFifo,
+		TxFrame:      txFrame,
+		TxCompressed: txCompressed,
+		TxMulticast:  txMulticast,
+	}
 }
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"p1/pkg/logs"
+	"p1/pkg/messages"
+	"p1/pkg/version"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	SESSION_BUFFER_SIZE = 256              // Outbound messages kept per session for replay
+	SESSION_TTL         = 2 * time.Minute  // How long a detached session survives
+	WRITE_TIMEOUT       = 10 * time.Second // How long a client may take to accept a message
+)
+
+// session tracks one logical client across reconnects. Every outbound
+// message gets a sequence number and is kept in a bounded buffer so that a
+// client reconnecting with its last-seen sequence can be caught up.
+//
+// Session IDs are shown to every client in the presence list, resuming a
+// session takes its token as well, which only the client it was issued to
+// knows.
+type session struct {
+	id       string
+	token    string
+	mu       sync.Mutex          // Serializes writes and protects the fields below
+	conn     *websocket.Conn     // Current connection, nil while detached
+	seq      uint64              // Last sequence number issued
+	buffer   []messages.Message  // Recently sent messages, oldest first
+	detached time.Time           // When the connection was lost
+	client   messages.ClientInfo // Who is on the other end, from the handshake
+	peer     bool                // The other end is a federated server, not a client
+	logs     bool                // The client follows the server's logs
+
+	writeTimeout time.Duration
+}
+
+func newSession(id string) *session {
+	return &session{
+		id:           id,
+		token:        newToken(),
+		buffer:       []messages.Message{},
+		writeTimeout: WRITE_TIMEOUT,
+	}
+}
+
+// newToken returns a random secret for resuming a session.
+func newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// authorize reports whether token is the one issued with the session.
+func (s *session) authorize(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(s.token), []byte(token)) == 1
+}
+
+// send assigns the next sequence number to msg, records it for replay and
+// writes it to the connection if one is attached. The payload is encoded
+// right away, so a replayed message shows the state it was sent with.
+func (s *session) send(msg messages.Message) error {
+	if _, ok := msg.Payload.(json.RawMessage); !ok {
+		payload, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = json.RawMessage(payload)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	msg.Seq = s.seq
+	s.buffer = append(s.buffer, msg)
+	if len(s.buffer) > SESSION_BUFFER_SIZE {
+		s.buffer = s.buffer[len(s.buffer)-SESSION_BUFFER_SIZE:]
+	}
+
+	if s.conn == nil {
+		return nil
+	}
+	return s.write(s.conn, msg)
+}
+
+// write sends msg on conn unless the client does not take it within
+// writeTimeout. A client that slow is dropped by closing its connection, the
+// read loop then detaches it and it catches up from the buffer on resume.
+func (s *session) write(conn *websocket.Conn, msg messages.Message) error {
+	conn.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	if err := conn.WriteJSON(msg); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// attach binds conn to the session and, when resuming, replays everything
+// after lastSeq. If the buffer no longer reaches back to lastSeq (or the
+// session was recreated after expiring) the client is told to resync instead.
+func (s *session) attach(conn *websocket.Conn, lastSeq uint64, resuming bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn = conn
+
+	info := messages.SessionInfo{
+		SessionID: s.id,
+		Token:     s.token,
+		Seq:       s.seq,
+		Version:   version.Version,
+	}
+
+	var replay []messages.Message
+	if resuming {
+		switch {
+		case lastSeq == s.seq:
+			info.Resumed = true
+		case lastSeq < s.seq && len(s.buffer) > 0 && s.buffer[0].Seq <= lastSeq+1:
+			info.Resumed = true
+			for _, msg := range s.buffer {
+				if msg.Seq > lastSeq {
+					replay = append(replay, msg)
+				}
+			}
+		default:
+			info.Resync = true
+		}
+	}
+
+	err := s.write(conn, messages.Message{
+		Type:    messages.TypeSession,
+		Payload: info,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(replay) > 0 {
+		slog.Info("replaying session messages", "session", s.id, "from", lastSeq+1, "count", len(replay))
+	}
+	for _, msg := range replay {
+		if err := s.write(conn, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detach releases conn if it is still the session's active connection.
+func (s *session) detach(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == conn {
+		s.conn = nil
+		s.detached = time.Now()
+	}
+}
+
+// identify records who attached, clients may change their name between
+// reconnects.
+func (s *session) identify(client messages.ClientInfo, peer bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client.SessionID = s.id
+	s.client = client
+	s.peer = peer
+}
+
+// presence returns who is attached to the session, false while it is
+// detached or used by a peer.
+func (s *session) presence() (messages.ClientInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client, s.conn != nil && !s.peer
+}
+
+// isPeer reports whether a federated server is on the other end.
+func (s *session) isPeer() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peer
+}
+
+// followLogs turns the stream of log entries to the session on or off. It
+// stays on across reconnects.
+func (s *session) followLogs(follow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = follow
+}
+
+// stream writes msg to the connection if the session follows the logs.
+// Log entries are neither numbered nor kept for replay, they would push the
+// messages that matter out of the buffer.
+func (s *session) stream(msg messages.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil || !s.logs {
+		return nil
+	}
+	return s.write(s.conn, msg)
+}
+
+// expired reports whether the session has been detached for longer than SESSION_TTL.
+func (s *session) expired(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn == nil && now.Sub(s.detached) > SESSION_TTL
+}
+
+// attachSession looks up the session requested in the handshake or creates a
+// new one when it is unknown, has already expired or the token does not
+// match, which created reports. New sessions always get a new ID, the client
+// notices and resyncs.
+func (s *Server) attachSession(conn *websocket.Conn, sessionId string, token string, lastSeq uint64) (sess *session, created bool, err error) {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionId]
+	if ok && !sess.authorize(token) {
+		slog.Warn("session token does not match, starting a new session", "session", sessionId)
+		ok = false
+	}
+	if !ok {
+		sess = newSession(uuid.New().String())
+		s.sessions[sess.id] = sess
+	}
+	s.mu.Unlock()
+
+	if err := sess.attach(conn, lastSeq, ok); err != nil {
+		sess.detach(conn)
+		return nil, false, err
+	}
+	return sess, !ok, nil
+}
+
+// clients lists the clients attached right now.
+func (s *Server) clients() []messages.ClientInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clients := []messages.ClientInfo{}
+	for _, sess := range s.sessions {
+		if client, ok := sess.presence(); ok {
+			clients = append(clients, client)
+		}
+	}
+	slices.SortFunc(clients, func(a, b messages.ClientInfo) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.SessionID, b.SessionID))
+	})
+	return clients
+}
+
+// presenceChanged pushes the attached clients to everyone.
+func (s *Server) presenceChanged() {
+	s.broadcast(nil, messages.Message{
+		Type:    messages.TypeListClients,
+		Payload: s.clients(),
+		Sender:  s.ID,
+	})
+}
+
+// reapSessions drops detached sessions once their TTL has passed.
+func (s *Server) reapSessions() {
+	ticker := time.NewTicker(SESSION_TTL / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, sess := range s.sessions {
+				if sess.expired(now) {
+					slog.Info("session expired", "session", id)
+					delete(s.sessions, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// streamLogs sends new log entries to the sessions following them. Entries
+// written in the meantime are sent together.
+func (s *Server) streamLogs() {
+	entries, unsubscribe := s.logs.Subscribe()
+	defer unsubscribe()
+
+	for {
+		var batch []logs.Entry
+		select {
+		case <-s.ctx.Done():
+			return
+		case entry := <-entries:
+			batch = append(batch, entry)
+		}
+	drain:
+		for len(batch) < logs.SUBSCRIBER_BUFFER {
+			select {
+			case entry := <-entries:
+				batch = append(batch, entry)
+			default:
+				break drain
+			}
+		}
+
+		msg := messages.Message{
+			Type:    messages.TypeLogs,
+			Payload: batch,
+			Sender:  s.ID,
+		}
+		s.mu.RLock()
+		recipients := make([]*session, 0, len(s.sessions))
+		for _, sess := range s.sessions {
+			recipients = append(recipients, sess)
+		}
+		s.mu.RUnlock()
+		// failures are not logged, that would feed the stream itself
+		for _, sess := range recipients {
+			sess.stream(msg)
+		}
+	}
+}
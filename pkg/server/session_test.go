@@ -0,0 +1,225 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"p1/pkg/messages"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsPair returns both ends of a websocket connection, the server end first.
+func wsPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server := <-conns
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+// readMessages reads n messages from conn.
+func readMessages(t *testing.T, conn *websocket.Conn, n int) []messages.Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	msgs := make([]messages.Message, n)
+	for i := range msgs {
+		if err := conn.ReadJSON(&msgs[i]); err != nil {
+			t.Fatalf("read message %d: %v", i, err)
+		}
+	}
+	return msgs
+}
+
+func sessionInfo(t *testing.T, msg messages.Message) messages.SessionInfo {
+	t.Helper()
+	if msg.Type != messages.TypeSession {
+		t.Fatalf("got %s, want %s first", msg.Type, messages.TypeSession)
+	}
+	var info messages.SessionInfo
+	if err := msg.DecodePayload(&info); err != nil {
+		t.Fatalf("decode session info: %v", err)
+	}
+	return info
+}
+
+func TestSessionSendNumbersAndBounds(t *testing.T) {
+	sess := newSession("s")
+	for i := 0; i < SESSION_BUFFER_SIZE+10; i++ {
+		if err := sess.send(messages.Message{Type: messages.TypeMetrics, Payload: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sess.seq != SESSION_BUFFER_SIZE+10 {
+		t.Errorf("seq = %d, want %d", sess.seq, SESSION_BUFFER_SIZE+10)
+	}
+	if len(sess.buffer) != SESSION_BUFFER_SIZE {
+		t.Fatalf("buffer holds %d messages, want %d", len(sess.buffer), SESSION_BUFFER_SIZE)
+	}
+	if first := sess.buffer[0].Seq; first != 11 {
+		t.Errorf("oldest buffered seq = %d, want 11", first)
+	}
+}
+
+func TestSessionBufferKeepsSentState(t *testing.T) {
+	sess := newSession("s")
+	svc := &Service{ID: "a", Name: "before"}
+	sess.send(messages.Message{Type: messages.TypeListServices, Payload: []*Service{svc}})
+	svc.Name = "after"
+
+	var replayed []Service
+	if err := sess.buffer[0].DecodePayload(&replayed); err != nil {
+		t.Fatal(err)
+	}
+	if replayed[0].Name != "before" {
+		t.Errorf("replayed name = %q, want the one sent", replayed[0].Name)
+	}
+}
+
+func TestSessionAttach(t *testing.T) {
+	tests := []struct {
+		name     string
+		sent     int
+		lastSeq  uint64
+		resuming bool
+		resumed  bool
+		resync   bool
+		replay   []uint64
+	}{
+		{name: "new session", sent: 3},
+		{name: "up to date", sent: 3, lastSeq: 3, resuming: true, resumed: true},
+		{name: "missed messages", sent: 5, lastSeq: 2, resuming: true, resumed: true, replay: []uint64{3, 4, 5}},
+		{name: "gap beyond the buffer", sent: SESSION_BUFFER_SIZE + 5, lastSeq: 2, resuming: true, resync: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := newSession("s")
+			for i := 0; i < tt.sent; i++ {
+				sess.send(messages.Message{Type: messages.TypeMetrics, Payload: i})
+			}
+			server, client := wsPair(t)
+			if err := sess.attach(server, tt.lastSeq, tt.resuming); err != nil {
+				t.Fatal(err)
+			}
+
+			msgs := readMessages(t, client, 1+len(tt.replay))
+			info := sessionInfo(t, msgs[0])
+			if info.Resumed != tt.resumed || info.Resync != tt.resync {
+				t.Errorf("resumed, resync = %v, %v, want %v, %v", info.Resumed, info.Resync, tt.resumed, tt.resync)
+			}
+			if info.Seq != uint64(tt.sent) {
+				t.Errorf("seq = %d, want %d", info.Seq, tt.sent)
+			}
+			for i, seq := range tt.replay {
+				if msgs[1+i].Seq != seq {
+					t.Errorf("replayed message %d has seq %d, want %d", i, msgs[1+i].Seq, seq)
+				}
+			}
+		})
+	}
+}
+
+func TestAttachSessionToken(t *testing.T) {
+	s := &Server{sessions: map[string]*session{}}
+
+	server, client := wsPair(t)
+	sess, created, err := s.attachSession(server, "", "", 0)
+	if err != nil || !created {
+		t.Fatalf("first attach: created %v, error %v", created, err)
+	}
+	info := sessionInfo(t, readMessages(t, client, 1)[0])
+	if info.SessionID != sess.id || info.Token == "" {
+		t.Fatalf("session info = %+v, want the ID %s and a token", info, sess.id)
+	}
+	sess.detach(server)
+
+	tests := []struct {
+		name    string
+		token   string
+		created bool
+	}{
+		{name: "wrong token", token: "guessed", created: true},
+		{name: "no token", token: "", created: true},
+		{name: "issued token", token: info.Token, created: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := wsPair(t)
+			got, created, err := s.attachSession(server, sess.id, tt.token, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer got.detach(server)
+			if created != tt.created {
+				t.Errorf("created = %v, want %v", created, tt.created)
+			}
+			resumed := sessionInfo(t, readMessages(t, client, 1)[0])
+			if tt.created && (got == sess || resumed.SessionID == sess.id) {
+				t.Errorf("took over session %s without its token", sess.id)
+			}
+			if !tt.created && (got != sess || !resumed.Resumed) {
+				t.Errorf("session %s was not resumed with its token", sess.id)
+			}
+		})
+	}
+}
+
+func TestSessionDropsSlowClient(t *testing.T) {
+	sess := newSession("s")
+	sess.writeTimeout = 50 * time.Millisecond
+	server, client := wsPair(t)
+	if err := sess.attach(server, 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client never reads, so the socket buffers fill up
+	payload := strings.Repeat("x", 64*1024)
+	start := time.Now()
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = sess.send(messages.Message{Type: messages.TypeMetrics, Payload: payload})
+	}
+	if err == nil {
+		t.Fatal("send never timed out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("send blocked for %v", elapsed)
+	}
+
+	// the connection is closed, the client notices once it reads again
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := client.ReadMessage()
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatal("connection of the slow client is still open")
+		}
+		if err != nil {
+			break
+		}
+	}
+	if last := sess.buffer[len(sess.buffer)-1].Seq; last != sess.seq {
+		t.Errorf("last buffered seq = %d, want the failed message %d kept for replay", last, sess.seq)
+	}
+}
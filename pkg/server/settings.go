@@ -0,0 +1,70 @@
+package server
+
+import (
+	"log/slog"
+	"maps"
+	"p1/pkg/messages"
+	"slices"
+	"time"
+)
+
+// Settings are the options of a running server that can be changed without
+// restarting it.
+type Settings struct {
+	MetricsInterval time.Duration     // Time between the metrics sent to each client
+	HealthInterval  time.Duration     // Time between health checks of the services
+	HealthTimeout   time.Duration     // Time a health check waits for an answer
+	HealthChecks    map[string]string // Addresses probed instead of the endpoints, by service name
+	AuthTokens      []string          // Tokens clients authenticate with, none to accept every client
+}
+
+// currentSettings returns the settings and a channel that is closed once
+// they change.
+func (s *Server) currentSettings() (Settings, <-chan struct{}) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings, s.reconfigured
+}
+
+// Reconfigure replaces the settings. The tickers of the metrics and the
+// health checks are reset to the new intervals right away, zero intervals
+// keep the current ones. The health checks and the auth tokens are always
+// replaced: changed checks are run right away and connections whose token is
+// no longer accepted are closed.
+func (s *Server) Reconfigure(settings Settings) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	if settings.MetricsInterval > 0 {
+		s.settings.MetricsInterval = settings.MetricsInterval
+	}
+	if settings.HealthInterval > 0 {
+		s.settings.HealthInterval = settings.HealthInterval
+	}
+	if settings.HealthTimeout > 0 {
+		s.settings.HealthTimeout = settings.HealthTimeout
+	}
+	checksChanged := !maps.Equal(s.settings.HealthChecks, settings.HealthChecks)
+	s.settings.HealthChecks = maps.Clone(settings.HealthChecks)
+	s.settings.AuthTokens = slices.Clone(settings.AuthTokens)
+	close(s.reconfigured)
+	s.reconfigured = make(chan struct{})
+	if checksChanged {
+		s.checkHealthNow()
+	}
+	slog.Info("server reconfigured", "metrics_interval", s.settings.MetricsInterval, "health_interval", s.settings.HealthInterval, "health_timeout", s.settings.HealthTimeout, "health_checks", len(s.settings.HealthChecks), "auth_tokens", len(s.settings.AuthTokens))
+}
+
+// ConfigReloaded tells every client which settings a reload of the
+// configuration applied and which of them wait for a restart.
+func (s *Server) ConfigReloaded(applied []string, restart []string) {
+	s.broadcast(nil, messages.Message{
+		Type: messages.TypeConfigReloaded,
+		Payload: messages.ConfigReload{
+			Applied: applied,
+			Restart: restart,
+			At:      time.Now(),
+		},
+		Sender: s.ID,
+	})
+}
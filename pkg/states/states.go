@@ -1,10 +1,18 @@
 package states
 
-import "p1/pkg/models"
+import (
+	"p1/pkg/logs"
+	"p1/pkg/models"
+)
 
 type ClientState struct {
 	Projects []*models.Project
 	Servers  []*models.Server
 	Brokers  []*models.Broker
-	Metrics  *models.Metrics
+	Services []*models.Service
+	Metrics  map[string]*models.Metrics // Latest sample per origin server
+	Clients  []*models.Participant      // Clients attached to the servers
+	Chat     []*models.ChatMessage      // Broadcasts received, oldest first
+	Logs     []*logs.Entry              // Log entries of the followed servers, oldest first
+	Active   string                     // Server the other fields are limited to, empty for all
 }
@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"p1/pkg/keymap"
+	"p1/pkg/tui/theme"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const HELP_COLUMN_ROWS = 6 // Bindings per column before a group wraps into the next one
+
+var closeHelpKey = keymap.Register("help.close", keymap.SCOPE_HELP, "Close", "esc", "q")
+
+// helpGroup is a titled set of bindings shown in the help overlay.
+type helpGroup struct {
+	title    string
+	focused  bool // The group's keys go to the focused part of the TUI
+	bindings []key.Binding
+}
+
+// helpOverlay lists every active key binding, grouped by the part of the TUI
+// they belong to. It takes every key while it is open.
+type helpOverlay struct {
+	theme   theme.Theme
+	help    help.Model
+	visible bool
+	width   int
+	height  int
+}
+
+func newHelpOverlay(t theme.Theme) *helpOverlay {
+	h := &helpOverlay{help: help.New()}
+	h.help.FullSeparator = "    "
+	h.SetTheme(t)
+	return h
+}
+
+func (h *helpOverlay) SetTheme(t theme.Theme) {
+	h.theme = t
+	h.help.Styles.FullKey = t.TextHighlight()
+	h.help.Styles.FullDesc = t.TextAccent()
+	h.help.Styles.FullSeparator = t.TextBody()
+}
+
+func (h *helpOverlay) Open() {
+	h.visible = true
+}
+
+func (h *helpOverlay) Close() {
+	h.visible = false
+}
+
+func (h *helpOverlay) Visible() bool {
+	return h.visible
+}
+
+func (h *helpOverlay) SetSize(width, height int) {
+	h.width = width
+	h.height = height
+	h.help.Width = max(0, width-8)
+}
+
+func (h *helpOverlay) Update(msg tea.Msg) {
+	if msg, ok := msg.(tea.KeyMsg); ok && key.Matches(msg, closeHelpKey.Binding, keymap.Help.Binding) {
+		h.Close()
+	}
+}
+
+func (h *helpOverlay) View(groups []helpGroup) string {
+	// Groups flow into another column once they would overflow the terminal
+	available := max(1, h.height-10)
+	columns := []string{}
+	column := []string{}
+	for _, group := range groups {
+		title := h.theme.TextBody().Render(group.title)
+		if group.focused {
+			title = h.theme.TextHighlight().Bold(true).Render("● " + group.title)
+		}
+		section := lipgloss.JoinVertical(lipgloss.Left, title, h.help.FullHelpView(helpColumns(group.bindings)), "")
+		if len(column) > 0 && lipgloss.Height(strings.Join(column, "\n"))+lipgloss.Height(section) > available {
+			columns = append(columns, lipgloss.JoinVertical(lipgloss.Left, column...))
+			column = []string{}
+		}
+		column = append(column, h.theme.Base().PaddingRight(4).Render(section))
+	}
+	columns = append(columns, lipgloss.JoinVertical(lipgloss.Left, column...))
+
+	box := h.theme.Base().
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(h.theme.Highlight()).
+		Render(lipgloss.JoinVertical(lipgloss.Left,
+			h.theme.TextAccent().Bold(true).Render("Keyboard Shortcuts"),
+			"",
+			lipgloss.JoinHorizontal(lipgloss.Top, columns...),
+			h.theme.TextBody().Render(closeHelpKey.Key()+" close"),
+		))
+
+	return lipgloss.Place(h.width, h.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+// helpColumns splits bindings into columns of HELP_COLUMN_ROWS, showing
+// every key of a binding instead of only the first.
+func helpColumns(bindings []key.Binding) [][]key.Binding {
+	enabled := []key.Binding{}
+	for _, b := range bindings {
+		if !b.Enabled() {
+			continue
+		}
+		keys := b.Keys()
+		if len(keys) > 1 {
+			b.SetHelp(strings.Join(keys, "/"), b.Help().Desc)
+		}
+		enabled = append(enabled, b)
+	}
+	return slices.Collect(slices.Chunk(enabled, HELP_COLUMN_ROWS))
+}
+
+// scopeBindings returns the bindings of a keymap scope.
+func scopeBindings(scope string) []key.Binding {
+	bindings := []key.Binding{}
+	for _, b := range keymap.Default.InScope(scope) {
+		bindings = append(bindings, b.Binding)
+	}
+	return bindings
+}
+
+// helpGroups collects the bindings that currently apply: global keys, the
+// sidebar, the selected screen with the scopes it inherits, and the open
+// dialog. The group receiving key presses is marked as focused.
+func (m model) helpGroups() []helpGroup {
+	groups := []helpGroup{
+		{title: "Global", bindings: scopeBindings(keymap.SCOPE_GLOBAL)},
+		{title: "Sidebar", focused: m.menu.IsFocused(), bindings: scopeBindings(keymap.SCOPE_SIDEBAR)},
+	}
+
+	item := m.menu.Selected()
+	if item == nil {
+		return groups
+	}
+	screen := item.Screen()
+	dialog := screen.DialogBindings()
+
+	scopes := keymap.Default.Lineage(screen.Scope())
+	if !slices.Contains(scopes, keymap.SCOPE_LIST) {
+		// every screen scrolls with the list keys
+		scopes = append(scopes, keymap.SCOPE_LIST)
+	}
+	for i, scope := range scopes {
+		if scope == keymap.SCOPE_GLOBAL {
+			continue
+		}
+		title := item.Name()
+		if s := keymap.Default.Scope(scope); s != nil && scope != screen.Scope() {
+			title = s.Title
+		}
+		groups = append(groups, helpGroup{
+			title:    title,
+			focused:  i == 0 && !m.menu.IsFocused() && dialog == nil,
+			bindings: scopeBindings(scope),
+		})
+	}
+
+	if dialog != nil {
+		groups = append(groups, helpGroup{title: "Dialog", focused: !m.menu.IsFocused(), bindings: dialog})
+	}
+	return groups
+}
@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"log/slog"
+	"slices"
+
+	"p1/pkg/keymap"
+	"p1/pkg/models"
+	"p1/pkg/tui/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ReloadMsg carries the TUI settings that changed in a reloaded
+// configuration, the others are nil. Send it to the program to apply them
+// without restarting. Settings left alone keep what was picked at runtime,
+// like a theme chosen from the palette.
+type ReloadMsg struct {
+	ConfigDir string       // Where the theme and keymap files are read from
+	Theme     *string      // Name of the theme to switch to
+	Mouse     *bool        // Whether clicks and the wheel are handled
+	Keymap    *keymap.File // Keymap settings of the config file
+}
+
+// reload applies msg: a changed theme re-reads the themes and switches to
+// it, a changed keymap re-reads the keymap file. Problems are shown as
+// notifications, the settings that could be applied still are.
+func (m *model) reload(msg ReloadMsg) tea.Cmd {
+	cmds := []tea.Cmd{}
+
+	if msg.Theme != nil {
+		themes, err := theme.Definitions(msg.ConfigDir)
+		if err != nil {
+			slog.Error("Error loading themes", "error", err.Error())
+			cmds = append(cmds, models.Notify(models.SeverityError, err.Error()))
+		}
+		m.themes = themes
+		index := slices.IndexFunc(m.themes, func(def theme.Definition) bool { return def.Name == *msg.Theme })
+		if index < 0 {
+			cmds = append(cmds, models.Notify(models.SeverityWarning, "Unknown theme "+*msg.Theme))
+		} else {
+			cmds = append(cmds, m.setTheme(theme.New(m.renderer, m.themes[index])))
+		}
+	}
+
+	if msg.Keymap != nil {
+		if err := keymap.LoadFile(msg.ConfigDir, *msg.Keymap); err != nil {
+			slog.Error("Error loading keymap", "error", err.Error())
+			cmds = append(cmds, models.Notify(models.SeverityError, err.Error()))
+		}
+		cmds = append(cmds, keymapConflicts())
+	}
+
+	if msg.Mouse != nil && *msg.Mouse != m.mouse {
+		m.mouse = *msg.Mouse
+		mouse := tea.DisableMouse
+		if m.mouse {
+			mouse = tea.EnableMouseCellMotion
+		}
+		cmds = append(cmds, func() tea.Msg { return mouse() })
+	}
+	return tea.Batch(cmds...)
+}
@@ -2,12 +2,21 @@ package tui
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"time"
 
+	"p1/pkg/client"
+	"p1/pkg/config"
+	"p1/pkg/keymap"
 	"p1/pkg/menu"
+	"p1/pkg/messages"
 	"p1/pkg/models"
+	"p1/pkg/palette"
 	"p1/pkg/screens"
 	"p1/pkg/tui/theme"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -16,42 +25,183 @@ type model struct {
 	renderer *lipgloss.Renderer
 	context  context.Context
 	theme    theme.Theme
+	themes   []theme.Definition
 	width    int
 	height   int
 	menu     *menu.Menu
+	client   *client.Pool
+	palette  *palette.Palette
+	help     *helpOverlay
+	toasts   *toastStack
+	mouse    bool
 }
 
-func NewModel(renderer *lipgloss.Renderer) tea.Model {
+// serverNotificationMsg carries a notification reported by the client pool.
+type serverNotificationMsg models.Notification
+
+// statusTickMsg triggers a refresh of the connection status shown in the footer.
+type statusTickMsg time.Time
+
+const STATUS_INTERVAL = 1 * time.Second
+
+// Options are the TUI settings that come from the configuration.
+type Options struct {
+	Themes  []theme.Definition // Themes that can be switched between, the built-in ones if empty
+	Theme   string             // Name of the theme to start with
+	Mouse   bool               // Whether clicks and the wheel are handled
+	LogFile string             // Log file shown in the logs screen
+}
+
+func NewModel(renderer *lipgloss.Renderer, cl *client.Pool, store *config.ServerStore, options Options) tea.Model {
+	themes := options.Themes
+	if len(themes) == 0 {
+		themes = theme.Builtin
+	}
 	basicTheme := theme.BasicTheme(renderer, nil)
+	for _, def := range themes {
+		if def.Name == options.Theme {
+			basicTheme = theme.New(renderer, def)
+		}
+	}
 
-	default_menu := menu.NewMenu().
+	default_menu := menu.NewMenu(basicTheme).
+		AddItem(menu.NewMenuItem("metrics", "Metrics", screens.NewMetricsScreen(renderer))).
+		AddItem(menu.NewMenuItem("services", "Services", screens.NewServicesScreen(renderer))).
 		AddItem(menu.NewMenuItem("projects", "Projects", screens.NewProjectsScreen(renderer))).
-		AddItem(menu.NewMenuItem("brokers", "Brokers", screens.NewBrokersScreen(renderer)))
+		AddItem(menu.NewMenuItem("brokers", "Brokers", screens.NewBrokersScreen(renderer))).
+		AddItem(menu.NewMenuItem("servers", "Servers", screens.NewServersScreen(renderer, store))).
+		AddItem(menu.NewMenuItem("notifications", "Notifications", screens.NewNotificationsScreen(renderer))).
+		AddItem(menu.NewMenuItem("broadcast", "Broadcast", screens.NewBroadcastScreen(renderer))).
+		AddItem(menu.NewMenuItem("logs", "Logs", screens.NewLogsScreen(renderer, options.LogFile)))
 
 	result := model{
 		renderer: renderer,
 		context:  context.Background(),
 		theme:    basicTheme,
+		themes:   themes,
 		width:    0,
 		height:   0,
 		menu:     default_menu,
+		client:   cl,
+		palette:  palette.New(basicTheme),
+		help:     newHelpOverlay(basicTheme),
+		toasts:   newToastStack(basicTheme),
+		mouse:    options.Mouse,
 	}
 
 	return result
 }
 
 func (m model) Init() tea.Cmd {
-	return func() tea.Msg { return tea.DisableMouse() }
+	mouse := tea.DisableMouse
+	if m.mouse {
+		mouse = tea.EnableMouseCellMotion
+	}
+	return tea.Batch(
+		func() tea.Msg { return mouse() },
+		statusTick(),
+		screens.PollLogs(),
+		m.waitForSync(),
+		m.waitForNotification(),
+		keymapConflicts(),
+		m.setTheme(m.theme),
+	)
+}
+
+// setTheme hands t to every component.
+func (m model) setTheme(t theme.Theme) tea.Cmd {
+	return func() tea.Msg { return theme.ChangedMsg{Theme: t} }
+}
+
+// nextTheme switches to the theme after the current one.
+func (m model) nextTheme() tea.Cmd {
+	next := 0
+	for i, def := range m.themes {
+		if def.Name == m.theme.Name() {
+			next = (i + 1) % len(m.themes)
+		}
+	}
+	return m.setTheme(theme.New(m.renderer, m.themes[next]))
+}
+
+// keymapConflicts shows the first conflicting key binding in the footer, the
+// full list is logged at startup.
+func keymapConflicts() tea.Cmd {
+	conflicts := keymap.Default.Conflicts()
+	if len(conflicts) == 0 {
+		return nil
+	}
+	message := "keymap: " + conflicts[0].String()
+	if len(conflicts) > 1 {
+		message += fmt.Sprintf(" (and %d more)", len(conflicts)-1)
+	}
+	return func() tea.Msg { return models.NewVisibleError(message) }
+}
+
+// waitForSync blocks until the client reports a state change and hands the
+// merged state to the screens.
+func (m model) waitForSync() tea.Cmd {
+	if m.client == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		<-m.client.Changes()
+		return messages.SyncMsg(m.client.Pull())
+	}
+}
+
+// waitForNotification blocks until the client reports a server event.
+func (m model) waitForNotification() tea.Cmd {
+	if m.client == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return serverNotificationMsg(<-m.client.Notifications())
+	}
+}
+
+func statusTick() tea.Cmd {
+	return tea.Tick(STATUS_INTERVAL, func(t time.Time) tea.Msg {
+		return statusTickMsg(t)
+	})
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	cmds := []tea.Cmd{}
 	parentMsg := msg
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		// The open palette and help take every key, the screens below stay untouched
+		if m.palette.Visible() {
+			return m, m.palette.Update(msg)
+		}
+		if m.help.Visible() {
+			m.help.Update(msg)
+			return m, nil
+		}
+		switch {
+		case key.Matches(msg, keymap.Palette.Binding):
+			return m, m.palette.Open(m.paletteEntries())
+		case key.Matches(msg, keymap.Help.Binding):
+			m.help.Open()
+			return m, nil
+		case key.Matches(msg, keymap.NextTheme.Binding):
+			return m, m.nextTheme()
+		}
+	} else if _, ok := parentMsg.(tea.MouseMsg); ok && (m.palette.Visible() || m.help.Visible()) {
+		// the overlays are used with the keyboard, the screens below them
+		// are hidden and must not be clicked
+		return m, nil
+	} else if m.palette.Visible() {
+		cmds = append(cmds, m.palette.Update(msg))
+	}
 	cmds = append(cmds, m.menu.Update(parentMsg))
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.palette.SetSize(msg.Width, msg.Height)
+		m.help.SetSize(msg.Width, msg.Height)
+		m.toasts.SetSize(msg.Width, msg.Height)
 		cmds = append(cmds, tea.Cmd(func() tea.Msg {
 			return tea.Msg(models.InternalWindowSizeMsg{
 				Width:        msg.Width,
@@ -60,12 +210,174 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				FooterHeight: m.menu.FooterHeight(),
 			})
 		}))
+	case theme.ChangedMsg:
+		m.theme = msg.Theme
+		m.palette.SetTheme(msg.Theme)
+		m.help.SetTheme(msg.Theme)
+		m.toasts.SetTheme(msg.Theme)
+	case ReloadMsg:
+		cmds = append(cmds, m.reload(msg))
+	case models.VisibleError:
+		cmds = append(cmds, models.Notify(models.SeverityError, msg.Message))
+	case serverNotificationMsg:
+		notification := models.Notification(msg)
+		cmds = append(cmds, func() tea.Msg { return notification }, m.waitForNotification())
+	case models.Notification:
+		cmds = append(cmds, m.toasts.Push(msg))
+	case toastExpiredMsg:
+		m.toasts.Update(msg)
+	case statusTickMsg:
+		if m.client != nil {
+			status := m.client.Stats()
+			servers := m.client.ServerStats()
+			cmds = append(cmds,
+				func() tea.Msg { return models.ConnectionStatusMsg(status) },
+				func() tea.Msg { return models.ServerStatusMsg(servers) },
+			)
+		}
+		cmds = append(cmds, statusTick())
+	case messages.SyncMsg:
+		cmds = append(cmds, m.waitForSync())
+	case messages.SendMsg:
+		if m.client != nil {
+			if err := m.client.SendMessage(msg.Origin, msg.Message); err != nil {
+				cmds = append(cmds, func() tea.Msg { return models.NewVisibleError(err.Error()) })
+			}
+		}
+	case messages.ConnectServerMsg:
+		if m.client != nil {
+			cmds = append(cmds, m.connectServer(msg.Server))
+		}
+	case messages.DisconnectServerMsg:
+		if m.client != nil && m.client.Client(msg.ID) != nil {
+			if err := m.client.Remove(msg.ID); err != nil {
+				slog.Warn("error disconnecting from server", "server", msg.ID, "error", err)
+			}
+		}
+	case messages.SelectServerMsg:
+		if m.client != nil {
+			if err := m.client.SetActive(msg.ID); err != nil {
+				cmds = append(cmds, func() tea.Msg { return models.NewVisibleError(err.Error()) })
+			}
+		}
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// connectServer dials server in the background, replacing an existing
+// connection with the same ID so edited settings take effect.
+func (m model) connectServer(server *models.Server) tea.Cmd {
+	return func() tea.Msg {
+		if m.client.Client(server.ID) != nil {
+			if err := m.client.Remove(server.ID); err != nil {
+				slog.Warn("error disconnecting from server", "server", server.Name, "error", err)
+			}
+		}
+		if err := m.client.Add(server); err != nil {
+			return models.NewVisibleError(err.Error())
+		}
+		return models.NewNotification(models.SeveritySuccess, "Connected to "+server.Name)
+	}
+}
+
+// paletteEntries lists everything the command palette offers: switching
+// screens, the actions each screen registers and the global keys.
+func (m model) paletteEntries() []*palette.Entry {
+	entries := []*palette.Entry{}
+	for _, item := range m.menu.Items() {
+		id := item.ID()
+		entries = append(entries, &palette.Entry{
+			ID:    "goto." + id,
+			Title: "Go to " + item.Name(),
+			Run: func() tea.Cmd {
+				m.menu.Select(id)
+				return nil
+			},
+		})
+	}
+	for _, item := range m.menu.Items() {
+		for _, action := range item.Screen().Actions() {
+			if !action.Enabled() {
+				continue
+			}
+			entries = append(entries, &palette.Entry{
+				ID:    action.ID,
+				Title: item.Name() + ": " + action.Title(),
+				Key:   action.Key(),
+				Run:   m.runAction(item.ID(), action),
+			})
+		}
+	}
+	entries = append(entries,
+		&palette.Entry{
+			ID:    keymap.FocusSidebar.ID,
+			Title: keymap.FocusSidebar.Title(),
+			Key:   keymap.FocusSidebar.Key(),
+			Run: func() tea.Cmd {
+				m.menu.FocusSidebar()
+				return nil
+			},
+		},
+		&palette.Entry{
+			ID:    keymap.Help.ID,
+			Title: keymap.Help.Title(),
+			Key:   keymap.Help.Key(),
+			Run: func() tea.Cmd {
+				m.help.Open()
+				return nil
+			},
+		},
+		&palette.Entry{
+			ID:    keymap.NextTheme.ID,
+			Title: keymap.NextTheme.Title(),
+			Key:   keymap.NextTheme.Key(),
+			Run:   m.nextTheme,
+		},
+		&palette.Entry{
+			ID:    keymap.Quit.ID,
+			Title: keymap.Quit.Title(),
+			Key:   keymap.Quit.Key(),
+			Run:   func() tea.Cmd { return tea.Quit },
+		},
+	)
+	for _, def := range m.themes {
+		entries = append(entries, &palette.Entry{
+			ID:    "theme." + def.Name,
+			Title: "Theme: " + def.Name,
+			Run:   func() tea.Cmd { return m.setTheme(theme.New(m.renderer, def)) },
+		})
+	}
+	return entries
+}
+
+// runAction switches to the screen with id and presses the action's key
+// there, so the screen handles it exactly like a key press.
+func (m model) runAction(id string, action *keymap.Binding) func() tea.Cmd {
+	return func() tea.Cmd {
+		if !m.menu.Select(id) {
+			return nil
+		}
+		press, ok := keymap.KeyMsg(action.Key())
+		if !ok {
+			slog.Warn("action has no usable key", "screen", id, "action", action.ID, "key", action.Key())
+			return nil
+		}
+		return func() tea.Msg { return press }
+	}
+}
+
 func (m model) View() string {
+	return m.toasts.Overlay(m.viewScreen())
+}
+
+func (m model) viewScreen() string {
+	if m.palette.Visible() {
+		return m.palette.View()
+	}
+	if m.help.Visible() {
+		return m.help.View(m.helpGroups())
+	}
 	menu := m.menu.View()
 	screen := m.menu.Screen()
 
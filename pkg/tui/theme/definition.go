@@ -0,0 +1,250 @@
+package theme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	DEFAULT_THEME = "default"
+	THEMES_DIR    = "themes" // Below the config dir, holds one JSON file per theme
+)
+
+// Color is a color of a theme definition. In theme files it is either a
+// single color or an object with one color for light and one for dark
+// terminal backgrounds, e.g. "#ff0000", "203" or {"light": "#000", "dark": "#fff"}.
+type Color struct {
+	Light string `json:"light"`
+	Dark  string `json:"dark"`
+}
+
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		c.Light, c.Dark = single, single
+		return nil
+	}
+	type adaptive Color
+	return json.Unmarshal(data, (*adaptive)(c))
+}
+
+func (c Color) empty() bool {
+	return c.Light == "" && c.Dark == ""
+}
+
+func (c Color) terminal() lipgloss.TerminalColor {
+	if c.Light == c.Dark {
+		return lipgloss.Color(c.Light)
+	}
+	return lipgloss.AdaptiveColor{Light: c.Light, Dark: c.Dark}
+}
+
+func solid(color string) Color {
+	return Color{Light: color, Dark: color}
+}
+
+// Definition holds the colors of a theme. Colors left out of a theme file
+// are taken from the theme named in Extends.
+type Definition struct {
+	Name       string `json:"name"`
+	Extends    string `json:"extends,omitempty"` // Theme the missing colors come from, the default theme if empty
+	Background Color  `json:"background"`
+	Border     Color  `json:"border"`
+	Body       Color  `json:"body"`      // Regular text
+	Accent     Color  `json:"accent"`    // Emphasized text
+	Highlight  Color  `json:"highlight"` // Selections, focus and key hints
+	Error      Color  `json:"error"`
+	Success    Color  `json:"success"`
+	Warning    Color  `json:"warning"`
+	Muted      Color  `json:"muted"`      // Secondary text and decorations
+	Surface    Color  `json:"surface"`    // Sidebar and dialog background
+	Selection  Color  `json:"selection"`  // Background of the selected sidebar item
+	Panel      Color  `json:"panel"`      // Footer background
+	Button     Color  `json:"button"`     // Background of inactive buttons
+	ButtonText Color  `json:"buttonText"` // Text on buttons
+}
+
+// fill takes every color missing from d from base.
+func (d Definition) fill(base Definition) Definition {
+	for _, pair := range [][2]*Color{
+		{&d.Background, &base.Background},
+		{&d.Border, &base.Border},
+		{&d.Body, &base.Body},
+		{&d.Accent, &base.Accent},
+		{&d.Highlight, &base.Highlight},
+		{&d.Error, &base.Error},
+		{&d.Success, &base.Success},
+		{&d.Warning, &base.Warning},
+		{&d.Muted, &base.Muted},
+		{&d.Surface, &base.Surface},
+		{&d.Selection, &base.Selection},
+		{&d.Panel, &base.Panel},
+		{&d.Button, &base.Button},
+		{&d.ButtonText, &base.ButtonText},
+	} {
+		if pair[0].empty() {
+			*pair[0] = *pair[1]
+		}
+	}
+	return d
+}
+
+// Default adapts to the terminal background and keeps the colors the TUI
+// always had.
+var Default = Definition{
+	Name:       DEFAULT_THEME,
+	Background: Color{Dark: "#000000", Light: "#FBFCFD"},
+	Border:     Color{Dark: "#3A3F42", Light: "#D7DBDF"},
+	Body:       solid("#889096"),
+	Accent:     Color{Dark: "#FFFFFF", Light: "#11181C"},
+	Highlight:  solid("#00a3ff"),
+	Error:      solid("203"),
+	Success:    solid("42"),
+	Warning:    solid("214"),
+	Muted:      Color{Dark: "#777777", Light: "#999999"},
+	Surface:    Color{Dark: "#222222", Light: "#DDDDDD"},
+	Selection:  Color{Dark: "#333333", Light: "#CCCCCC"},
+	Panel:      Color{Dark: "#000000", Light: "#FFFFFF"},
+	Button:     solid("#888B7E"),
+	ButtonText: solid("#FFF7DB"),
+}
+
+// Builtin lists the themes that need no file, in the order they are cycled.
+// Colors they leave out are those of Default.
+var Builtin = withDefaults([]Definition{
+	Default,
+	{
+		Name:       "dark",
+		Background: solid("#000000"),
+		Border:     solid("#3A3F42"),
+		Body:       solid("#889096"),
+		Accent:     solid("#FFFFFF"),
+		Muted:      solid("#777777"),
+		Surface:    solid("#222222"),
+		Selection:  solid("#333333"),
+		Panel:      solid("#000000"),
+	},
+	{
+		Name:       "light",
+		Background: solid("#FBFCFD"),
+		Border:     solid("#D7DBDF"),
+		Body:       solid("#687076"),
+		Accent:     solid("#11181C"),
+		Highlight:  solid("#0081CC"),
+		Error:      solid("#CD2B31"),
+		Success:    solid("#18794E"),
+		Warning:    solid("#AD5700"),
+		Muted:      solid("#999999"),
+		Surface:    solid("#EEEEEE"),
+		Selection:  solid("#DDDDDD"),
+		Panel:      solid("#FFFFFF"),
+		Button:     solid("#C1C8CD"),
+		ButtonText: solid("#11181C"),
+	},
+	{
+		Name:       "high-contrast",
+		Background: solid("#000000"),
+		Border:     solid("#FFFFFF"),
+		Body:       solid("#FFFFFF"),
+		Accent:     solid("#FFFFFF"),
+		Highlight:  solid("#FFFF00"),
+		Error:      solid("#FF5555"),
+		Success:    solid("#00FF00"),
+		Warning:    solid("#FFAA00"),
+		Muted:      solid("#CCCCCC"),
+		Surface:    solid("#000000"),
+		Selection:  solid("#0000AA"),
+		Panel:      solid("#000000"),
+		Button:     solid("#555555"),
+		ButtonText: solid("#FFFFFF"),
+	},
+	fromCatppuccin(catppuccin.Latte),
+	fromCatppuccin(catppuccin.Frappe),
+	fromCatppuccin(catppuccin.Macchiato),
+	fromCatppuccin(catppuccin.Mocha),
+})
+
+func withDefaults(definitions []Definition) []Definition {
+	for i := range definitions {
+		definitions[i] = definitions[i].fill(Default)
+	}
+	return definitions
+}
+
+func fromCatppuccin(f catppuccin.Flavour) Definition {
+	return Definition{
+		Name:       "catppuccin-" + f.Name(),
+		Background: solid(f.Base().Hex),
+		Border:     solid(f.Surface1().Hex),
+		Body:       solid(f.Subtext0().Hex),
+		Accent:     solid(f.Text().Hex),
+		Highlight:  solid(f.Mauve().Hex),
+		Error:      solid(f.Red().Hex),
+		Success:    solid(f.Green().Hex),
+		Warning:    solid(f.Peach().Hex),
+		Muted:      solid(f.Overlay0().Hex),
+		Surface:    solid(f.Mantle().Hex),
+		Selection:  solid(f.Surface0().Hex),
+		Panel:      solid(f.Crust().Hex),
+		Button:     solid(f.Surface2().Hex),
+		ButtonText: solid(f.Text().Hex),
+	}
+}
+
+// Definitions returns the built-in themes followed by those in the themes
+// directory below configDir. A file may replace a built-in theme by using
+// its name. Files that cannot be read are reported but do not hide the
+// others.
+func Definitions(configDir string) ([]Definition, error) {
+	definitions := slices.Clone(Builtin)
+
+	paths, _ := filepath.Glob(filepath.Join(configDir, THEMES_DIR, "*.json"))
+	errs := []error{}
+	for _, path := range paths {
+		def, err := loadDefinition(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		base := Default
+		if def.Extends != "" {
+			i := slices.IndexFunc(definitions, func(d Definition) bool { return d.Name == def.Extends })
+			if i < 0 {
+				errs = append(errs, fmt.Errorf("%s: unknown theme %q to extend", path, def.Extends))
+				continue
+			}
+			base = definitions[i]
+		}
+		def = def.fill(base)
+
+		if i := slices.IndexFunc(definitions, func(d Definition) bool { return d.Name == def.Name }); i >= 0 {
+			definitions[i] = def
+		} else {
+			definitions = append(definitions, def)
+		}
+	}
+	return definitions, errors.Join(errs...)
+}
+
+func loadDefinition(path string) (Definition, error) {
+	def := Definition{}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return def, err
+	}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return def, fmt.Errorf("%s: %w", path, err)
+	}
+	if def.Name == "" {
+		def.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return def, nil
+}
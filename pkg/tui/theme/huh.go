@@ -24,9 +24,9 @@ func copyFieldStyles(f huh.FieldStyles) huh.FieldStyles {
 		ErrorIndicator: f.ErrorIndicator,
 		ErrorMessage:   f.ErrorMessage,
 		SelectSelector: f.SelectSelector,
-		// NextIndicator:       f.NextIndicator,
-		// PrevIndicator:       f.PrevIndicator,
-		Option: f.Option,
+		NextIndicator:  f.NextIndicator,
+		PrevIndicator:  f.PrevIndicator,
+		Option:         f.Option,
 		// Directory:           f.Directory,
 		// File:                f.File,
 		MultiSelectSelector: f.MultiSelectSelector,
@@ -8,6 +8,7 @@ import (
 
 type Theme struct {
 	renderer *lipgloss.Renderer
+	name     string
 
 	border     lipgloss.TerminalColor
 	background lipgloss.TerminalColor
@@ -15,26 +16,53 @@ type Theme struct {
 	error      lipgloss.TerminalColor
 	body       lipgloss.TerminalColor
 	accent     lipgloss.TerminalColor
+	success    lipgloss.TerminalColor
+	warning    lipgloss.TerminalColor
+	muted      lipgloss.TerminalColor
+	surface    lipgloss.TerminalColor
+	selection  lipgloss.TerminalColor
+	panel      lipgloss.TerminalColor
+	button     lipgloss.TerminalColor
+	buttonText lipgloss.TerminalColor
 
 	base lipgloss.Style
 	form *huh.Theme
 }
 
+// ChangedMsg is broadcast to every component when the theme is switched.
+type ChangedMsg struct {
+	Theme Theme
+}
+
 func BasicTheme(renderer *lipgloss.Renderer, highlight *string) Theme {
+	def := Default
+	if highlight != nil {
+		def.Highlight = solid(*highlight)
+	}
+	return New(renderer, def)
+}
+
+// New creates a theme from a definition, which must have every color set.
+func New(renderer *lipgloss.Renderer, def Definition) Theme {
 	base := Theme{
 		renderer: renderer,
+		name:     def.Name,
 	}
 
-	base.background = lipgloss.AdaptiveColor{Dark: "#000000", Light: "#FBFCFD"}
-	base.border = lipgloss.AdaptiveColor{Dark: "#3A3F42", Light: "#D7DBDF"}
-	base.body = lipgloss.AdaptiveColor{Dark: "#889096", Light: "#889096"}
-	base.accent = lipgloss.AdaptiveColor{Dark: "#FFFFFF", Light: "#11181C"}
-	if highlight != nil {
-		base.highlight = lipgloss.Color(*highlight)
-	} else {
-		base.highlight = lipgloss.Color("#00a3ff")
-	}
-	base.error = lipgloss.Color("203")
+	base.background = def.Background.terminal()
+	base.border = def.Border.terminal()
+	base.body = def.Body.terminal()
+	base.accent = def.Accent.terminal()
+	base.highlight = def.Highlight.terminal()
+	base.error = def.Error.terminal()
+	base.success = def.Success.terminal()
+	base.warning = def.Warning.terminal()
+	base.muted = def.Muted.terminal()
+	base.surface = def.Surface.terminal()
+	base.selection = def.Selection.terminal()
+	base.panel = def.Panel.terminal()
+	base.button = def.Button.terminal()
+	base.buttonText = def.ButtonText.terminal()
 
 	base.base = renderer.NewStyle().Foreground(base.body)
 	base.form = HuhTheme(base)
@@ -61,17 +89,38 @@ func HuhTheme(theme Theme) *huh.Theme {
 	f.TextInput.Text = theme.renderer.NewStyle().Foreground(theme.accent)
 	f.ErrorIndicator = theme.renderer.NewStyle().Foreground(theme.error)
 	f.ErrorMessage = theme.renderer.NewStyle().Foreground(theme.error)
+	f.SelectSelector = theme.renderer.NewStyle().Foreground(theme.highlight).SetString("> ")
+	f.NextIndicator = theme.renderer.NewStyle().Foreground(theme.highlight).MarginLeft(1).SetString("→")
+	f.PrevIndicator = theme.renderer.NewStyle().Foreground(theme.highlight).MarginRight(1).SetString("←")
+	f.Option = theme.renderer.NewStyle().Foreground(theme.accent)
+	f.MultiSelectSelector = theme.renderer.NewStyle().Foreground(theme.highlight).SetString("> ")
+	f.SelectedOption = theme.renderer.NewStyle().Foreground(theme.highlight)
+	f.SelectedPrefix = theme.renderer.NewStyle().Foreground(theme.highlight).SetString("[•] ")
+	f.UnselectedOption = theme.renderer.NewStyle().Foreground(theme.accent)
+	f.UnselectedPrefix = theme.renderer.NewStyle().Foreground(theme.body).SetString("[ ] ")
+	f.FocusedButton = theme.renderer.NewStyle().Padding(0, 2).MarginRight(1).Foreground(theme.background).Background(theme.highlight)
+	f.BlurredButton = theme.renderer.NewStyle().Padding(0, 2).MarginRight(1).Foreground(theme.body).Background(theme.border)
 	t.Help = help.New().Styles
 
 	t.Blurred = copyFieldStyles(*f)
 	t.Blurred.Base = t.Blurred.Base.BorderStyle(lipgloss.HiddenBorder())
 	t.Blurred.Title.Foreground(theme.body)
-
-	// TODO: add other huh form/input styles as needed
+	t.Blurred.SelectSelector = t.Blurred.SelectSelector.SetString("  ")
+	t.Blurred.MultiSelectSelector = t.Blurred.MultiSelectSelector.SetString("  ")
+	t.Blurred.NextIndicator = theme.renderer.NewStyle()
+	t.Blurred.PrevIndicator = theme.renderer.NewStyle()
 
 	return &t
 }
 
+func (b Theme) Name() string {
+	return b.name
+}
+
+func (b Theme) Renderer() *lipgloss.Renderer {
+	return b.renderer
+}
+
 func (b Theme) Body() lipgloss.TerminalColor {
 	return b.body
 }
@@ -84,6 +133,10 @@ func (b Theme) Background() lipgloss.TerminalColor {
 	return b.background
 }
 
+func (b Theme) Error() lipgloss.TerminalColor {
+	return b.error
+}
+
 func (b Theme) Accent() lipgloss.TerminalColor {
 	return b.accent
 }
@@ -120,3 +173,35 @@ func (b Theme) Form() *huh.Theme {
 func (b Theme) Border() lipgloss.TerminalColor {
 	return b.border
 }
+
+func (b Theme) Success() lipgloss.TerminalColor {
+	return b.success
+}
+
+func (b Theme) Warning() lipgloss.TerminalColor {
+	return b.warning
+}
+
+func (b Theme) Muted() lipgloss.TerminalColor {
+	return b.muted
+}
+
+func (b Theme) Surface() lipgloss.TerminalColor {
+	return b.surface
+}
+
+func (b Theme) Selection() lipgloss.TerminalColor {
+	return b.selection
+}
+
+func (b Theme) Panel() lipgloss.TerminalColor {
+	return b.panel
+}
+
+func (b Theme) Button() lipgloss.TerminalColor {
+	return b.button
+}
+
+func (b Theme) ButtonText() lipgloss.TerminalColor {
+	return b.buttonText
+}
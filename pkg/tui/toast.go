@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"p1/pkg/models"
+	"p1/pkg/tui/theme"
+	"p1/pkg/utils"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const TOAST_WIDTH = 40 // Width of a toast including its border
+const MAX_TOASTS = 4   // Toasts shown at once, the oldest one goes first
+
+// toastDurations is how long toasts stay, problems stay longer than news.
+var toastDurations = map[models.Severity]time.Duration{
+	models.SeverityInfo:    4 * time.Second,
+	models.SeveritySuccess: 4 * time.Second,
+	models.SeverityWarning: 8 * time.Second,
+	models.SeverityError:   10 * time.Second,
+}
+
+type toast struct {
+	id           int
+	notification models.Notification
+}
+
+// toastExpiredMsg dismisses the toast with id once its time is up.
+type toastExpiredMsg struct {
+	id int
+}
+
+// toastStack shows notifications stacked in the top right corner of the
+// window, the newest on top, and dismisses each one after a while.
+type toastStack struct {
+	theme  theme.Theme
+	toasts []toast
+	next   int
+	width  int
+	height int
+}
+
+func newToastStack(t theme.Theme) *toastStack {
+	return &toastStack{theme: t, toasts: []toast{}}
+}
+
+func (ts *toastStack) SetTheme(t theme.Theme) {
+	ts.theme = t
+}
+
+func (ts *toastStack) SetSize(width, height int) {
+	ts.width = width
+	ts.height = height
+}
+
+// Push shows notification and returns the command that dismisses it.
+func (ts *toastStack) Push(notification models.Notification) tea.Cmd {
+	ts.next++
+	id := ts.next
+	ts.toasts = append(ts.toasts, toast{id: id, notification: notification})
+	if len(ts.toasts) > MAX_TOASTS {
+		ts.toasts = ts.toasts[len(ts.toasts)-MAX_TOASTS:]
+	}
+
+	duration, ok := toastDurations[notification.Severity]
+	if !ok {
+		duration = toastDurations[models.SeverityInfo]
+	}
+	return tea.Tick(duration, func(time.Time) tea.Msg {
+		return toastExpiredMsg{id: id}
+	})
+}
+
+func (ts *toastStack) Update(msg tea.Msg) {
+	if msg, ok := msg.(toastExpiredMsg); ok {
+		ts.toasts = slices.DeleteFunc(ts.toasts, func(t toast) bool { return t.id == msg.id })
+	}
+}
+
+// Overlay draws the toasts over view.
+func (ts *toastStack) Overlay(view string) string {
+	if len(ts.toasts) == 0 || ts.width < TOAST_WIDTH+2 {
+		return view
+	}
+
+	boxes := []string{}
+	for i := len(ts.toasts) - 1; i >= 0; i-- {
+		boxes = append(boxes, ts.viewToast(ts.toasts[i].notification))
+	}
+	stack := lipgloss.JoinVertical(lipgloss.Left, boxes...)
+	return overlay(view, stack, ts.width-TOAST_WIDTH-1, 1)
+}
+
+func (ts *toastStack) viewToast(notification models.Notification) string {
+	color := notification.Severity.Color(&ts.theme)
+	icon := ts.theme.Base().Background(ts.theme.Surface()).Foreground(color).Bold(true).Render(notification.Severity.Icon() + " ")
+	message := ts.theme.TextAccent().Background(ts.theme.Surface()).Render(utils.WordWrap(notification.Message, TOAST_WIDTH-6))
+
+	return ts.theme.Base().
+		Background(ts.theme.Surface()).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(color).
+		BorderBackground(ts.theme.Surface()).
+		Padding(0, 1).
+		Width(TOAST_WIDTH - 2).
+		Render(lipgloss.JoinHorizontal(lipgloss.Top, icon, message))
+}
+
+// overlay draws box over base with its top left corner at x, y. Lines of
+// box that fall below base are cut off.
+func overlay(base string, box string, x int, y int) string {
+	lines := strings.Split(base, "\n")
+	for i, line := range strings.Split(box, "\n") {
+		row := y + i
+		if row < 0 || row >= len(lines) {
+			continue
+		}
+		left := ansi.Truncate(lines[row], x, "")
+		if width := ansi.StringWidth(left); width < x {
+			left += strings.Repeat(" ", x-width)
+		}
+		right := ansi.TruncateLeft(lines[row], x+ansi.StringWidth(line), "")
+		lines[row] = left + ansi.ResetStyle + line + ansi.ResetStyle + right
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,5 @@
+package version
+
+// Version of p1, overridden at build time with
+// -ldflags "-X p1/pkg/version.Version=v1.2.3".
+var Version = "dev"